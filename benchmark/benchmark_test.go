@@ -46,9 +46,9 @@ func BenchmarkHookRunner_Execution(b *testing.B) {
 				{Name: "hook-A", Tool: "echo", Args: []string{"running A"}},
 				{Name: "hook-B", Tool: "echo", Args: []string{"running B"}},
 				{Name: "hook-C", Tool: "echo", Args: []string{"running C"}},
-				{Name: "hook-D", Tool: "echo", Args: []string{"running D"}, After: "hook-A"},
-				{Name: "hook-E", Tool: "echo", Args: []string{"running E"}, After: "hook-B"}, // simplified dep
-				{Name: "hook-F", Tool: "echo", Args: []string{"running F"}, After: "hook-D"},
+				{Name: "hook-D", Tool: "echo", Args: []string{"running D"}, After: []string{"hook-A"}},
+				{Name: "hook-E", Tool: "echo", Args: []string{"running E"}, After: []string{"hook-B"}}, // simplified dep
+				{Name: "hook-F", Tool: "echo", Args: []string{"running F"}, After: []string{"hook-D"}},
 			},
 		},
 	}
@@ -67,9 +67,9 @@ func BenchmarkHookRunner_Execution(b *testing.B) {
 		{Name: "hook-A", Run: "echo running A"},
 		{Name: "hook-B", Run: "echo running B"},
 		{Name: "hook-C", Run: "echo running C"},
-		{Name: "hook-D", Run: "echo running D", After: "hook-A"},
-		{Name: "hook-E", Run: "echo running E", After: "hook-B"},
-		{Name: "hook-F", Run: "echo running F", After: "hook-D"},
+		{Name: "hook-D", Run: "echo running D", After: []string{"hook-A"}},
+		{Name: "hook-E", Run: "echo running E", After: []string{"hook-B"}},
+		{Name: "hook-F", Run: "echo running F", After: []string{"hook-D"}},
 	}
 
 	exec := executor.New(cfg, toolMgr, workDir)
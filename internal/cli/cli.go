@@ -2,12 +2,15 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -32,8 +35,20 @@ var (
 	dryRun     bool
 	noColor    bool
 	cleanRoom  bool
+	assumeYes  bool
 	useCache   bool
+	jobs       int
 	language   string
+	fromRef    string
+	toRef      string
+	filesArgs  bool
+	filesFrom  string
+	lastCommit bool
+	hooksPath  string
+	chainHooks bool
+	force      bool
+	migrate    bool
+	profile    string
 )
 
 var rootCmd = &cobra.Command{
@@ -55,10 +70,13 @@ var uninstallCmd = &cobra.Command{
 }
 
 var runCmd = &cobra.Command{
-	Use:   "run [hook-type]",
+	Use:   "run <hook-type|stage|hook> [git-hook-args...]",
 	Short: "Run specified hook",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runHook,
+	Long: `Run the hooks of a git hook type, such as pre-commit, or of a stage, such
+as manual or a custom one like ci. Given the name of a hook instead, run
+only that hook and the hooks it needs; this is how manual hooks run.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runHook,
 }
 
 var runCmdCmd = &cobra.Command{
@@ -124,6 +142,20 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema for hooks.yaml",
+	Long:  "Print the JSON Schema for hooks.yaml and hooks.json, for editor validation and completion",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := config.Schema()
+		if err != nil {
+			return err
+		}
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	},
+}
+
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate configuration file",
@@ -138,7 +170,7 @@ var cacheCmd = &cobra.Command{
 
 var cacheClearCmd = &cobra.Command{
 	Use:   "clear",
-	Short: "Clear hook result cache",
+	Short: "Clear hook result cache and the clean-room",
 	RunE:  runCacheClear,
 }
 
@@ -151,19 +183,80 @@ func init() {
 	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would run without executing")
 	runCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 	runCmd.Flags().BoolVar(&cleanRoom, "clean-room", false, "Run hooks in an isolated directory with only staged files")
+	runCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Skip confirmation prompts")
 	runCmd.Flags().BoolVar(&useCache, "cached", false, "Skip hooks for unchanged files")
+	runCmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Maximum number of hooks to run at once (0 = no limit)")
+	runCmd.Flags().StringVar(&fromRef, "from-ref", "", "Run on files changed since the merge-base with this ref")
+	runCmd.Flags().StringVar(&toRef, "to-ref", "", "End of the --from-ref range (default HEAD)")
+	runCmd.Flags().BoolVar(&filesArgs, "files", false, "Run on the files given as arguments after the hook type")
+	runCmd.Flags().StringVar(&filesFrom, "files-from", "", "Read files from a path ('-' for stdin), NUL or newline separated")
+	runCmd.Flags().BoolVar(&lastCommit, "last-commit", false, "Run on files changed by the last commit")
+	runCmd.MarkFlagsMutuallyExclusive("all-files", "from-ref", "files", "files-from", "last-commit")
+	for _, cmd := range []*cobra.Command{runCmd, listCmd, validateCmd, graphCmd, installCmd, doctorCmd, policyListCmd, policyFetchCmd, configShowCmd, configDiffCmd} {
+		cmd.Flags().StringVar(&profile, "profile", "", "Apply a profile from the config (default $"+config.ProfileEnv+", or ci in CI)")
+	}
+
+	installCmd.Flags().StringVar(&hooksPath, "hooks-path", "", "Install into this directory and point core.hooksPath at it")
+	installCmd.Flags().BoolVar(&chainHooks, "chain", false, "When core.hooksPath points elsewhere, install locally and chain to those hooks")
+	installCmd.Flags().BoolVar(&force, "force", false, "Replace hooks written by other tools without backing them up")
+	installCmd.Flags().BoolVar(&migrate, "migrate", false, "Back up hooks written by other tools and keep running them first")
+	installCmd.MarkFlagsMutuallyExclusive("force", "migrate")
+	uninstallCmd.Flags().StringVar(&hooksPath, "hooks-path", "", "Remove hooks from this directory")
 
 	initCmd.Flags().StringVar(&language, "lang", "", "Language preset (go, nodejs, python, java, ruby, rust)")
 
 	policyCmd.AddCommand(policyListCmd, policyFetchCmd, policyClearCmd)
 	cacheCmd.AddCommand(cacheClearCmd)
-	rootCmd.AddCommand(installCmd, uninstallCmd, runCmd, runCmdCmd, listCmd, doctorCmd, initCmd, presetsCmd, policyCmd, versionCmd, validateCmd, cacheCmd)
+	rootCmd.AddCommand(installCmd, uninstallCmd, runCmd, runCmdCmd, listCmd, doctorCmd, initCmd, presetsCmd, policyCmd, versionCmd, schemaCmd, validateCmd, cacheCmd)
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
 
+// repoDir returns the root of the repository containing the current
+// directory and makes it the current directory, so config, git paths and
+// hooks agree wherever hookrunner is run from. Outside a repository it
+// returns the current directory.
+func repoDir() (string, error) {
+	root, err := git.FindRepoRoot()
+	if err != nil {
+		return os.Getwd()
+	}
+	if err := os.Chdir(root); err != nil {
+		return "", err
+	}
+	return root, nil
+}
+
+// loadConfig loads the config of workDir with the profile selected by
+// --profile, HOOKRUNNER_PROFILE or CI detection applied.
+func loadConfig(workDir string) (*config.Config, string, error) {
+	return loadConfigAt(workDir, "")
+}
+
+// loadConfigAt is loadConfig for the config committed at ref, or the
+// working tree when ref is empty.
+func loadConfigAt(workDir, ref string) (*config.Config, string, error) {
+	var cfg *config.Config
+	var path string
+	var err error
+	if ref == "" {
+		cfg, path, err = config.Load(workDir)
+	} else {
+		cfg, path, err = config.LoadAt(workDir, ref)
+	}
+	if err != nil {
+		return nil, path, err
+	}
+	if name, reason := cfg.SelectProfile(profile); name != "" {
+		if err := cfg.ApplyProfile(name); err != nil {
+			return nil, path, fmt.Errorf("%s (from %s)", err, reason)
+		}
+	}
+	return cfg, path, nil
+}
+
 func runInstall(cmd *cobra.Command, args []string) error {
 	workDir, err := os.Getwd()
 	if err != nil {
@@ -179,47 +272,143 @@ func runInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
-	cfg, _, err := config.Load(workDir)
+	cfg, _, err := loadConfig(workDir)
+	if err != nil {
+		return err
+	}
+
+	hooksDir, chainDir, err := resolveInstallDir()
 	if err != nil {
 		return err
 	}
 
-	hookTypes := []string{"pre-commit", "pre-push", "commit-msg"}
+	hookTypes := config.GitStages()
 	installed := 0
 
 	for _, hookType := range hookTypes {
 		if hooks := cfg.GetHooks(hookType); len(hooks) > 0 {
-			if err := git.InstallHook(hookType, executable); err != nil {
+			opts := git.InstallOptions{HooksDir: hooksDir, Force: force, Migrate: migrate}
+			if chainDir != "" {
+				opts.ChainTo = filepath.Join(chainDir, hookType)
+			}
+			result, err := git.InstallHook(hookType, executable, opts)
+			if err != nil {
 				return fmt.Errorf("failed to install %s hook: %w", hookType, err)
 			}
-			fmt.Printf("Installed %s hook\n", hookType)
+			if result.BackupPath != "" {
+				fmt.Printf("Backed up existing %s hook to %s\n", hookType, result.BackupPath)
+			}
+			if result.ChainTo != "" {
+				fmt.Printf("Installed %s hook (runs %s first)\n", hookType, result.ChainTo)
+			} else {
+				fmt.Printf("Installed %s hook\n", hookType)
+			}
 			installed++
 		}
 	}
 
 	if installed == 0 {
 		fmt.Println("No hooks to install")
+	} else {
+		fmt.Printf("Hooks directory: %s\n", hooksDir)
 	}
 
 	return nil
 }
 
+// resolveInstallDir picks the directory hooks are installed into. When
+// core.hooksPath points outside the repository (typically a global hook
+// manager), hooks are only installed locally with consent, chaining to the
+// hooks in that directory, which is returned as chainDir.
+func resolveInstallDir() (hooksDir, chainDir string, err error) {
+	repoRoot, err := git.FindRepoRoot()
+	if err != nil {
+		return "", "", err
+	}
+
+	if hooksPath != "" {
+		dir := hooksPath
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(repoRoot, dir)
+		}
+		if err := git.SetHooksPath(dir); err != nil {
+			return "", "", err
+		}
+		fmt.Printf("Set core.hooksPath to %s\n", dir)
+		return dir, "", nil
+	}
+
+	hooksDir, err = git.HooksDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	if git.ConfiguredHooksPath() == "" || isWithin(hooksDir, repoRoot) {
+		return hooksDir, "", nil
+	}
+
+	localDir, err := git.DefaultHooksDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	fmt.Println(yellow("Warning:"), "core.hooksPath is set to", hooksDir)
+	fmt.Println("Git runs hooks from there, so hooks in", localDir, "are ignored.")
+
+	if !chainHooks && !promptConfirm("Install into "+localDir+" for this repository and chain to the existing hooks?") {
+		return "", "", fmt.Errorf("hooks not installed: rerun with --chain, or --hooks-path <dir>")
+	}
+
+	if err := git.SetHooksPath(localDir); err != nil {
+		return "", "", err
+	}
+	return localDir, hooksDir, nil
+}
+
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func runUninstall(cmd *cobra.Command, args []string) error {
 	if !git.IsInsideWorkTree() {
 		return fmt.Errorf("not inside a git repository")
 	}
 
-	hookTypes := []string{"pre-commit", "pre-push", "commit-msg"}
 	removed := 0
 
-	for _, hookType := range hookTypes {
-		if err := git.UninstallHook(hookType); err != nil {
+	for _, hookType := range config.GitStages() {
+		result, err := git.UninstallHook(hookType, hooksPath)
+		if err != nil {
 			return fmt.Errorf("failed to uninstall %s hook: %w", hookType, err)
 		}
-		removed++
+		if result.Foreign {
+			fmt.Printf("Skipped %s hook: not installed by hookrunner\n", hookType)
+		}
+		if result.Removed {
+			removed++
+		}
+		if result.RestoredFrom != "" {
+			fmt.Printf("Restored %s hook from %s\n", hookType, result.RestoredFrom)
+		}
 	}
 
 	fmt.Printf("Removed %d hooks\n", removed)
+
+	reset, previous, err := git.ResetHooksPath()
+	if err != nil {
+		return err
+	}
+	switch {
+	case reset && previous != "":
+		fmt.Printf("Restored core.hooksPath to %s\n", previous)
+	case reset:
+		fmt.Println("Unset core.hooksPath")
+	}
 	return nil
 }
 
@@ -237,65 +426,141 @@ func promptConfirm(message string) bool {
 
 func runHook(cmd *cobra.Command, args []string) error {
 	hookType := args[0]
-	workDir, err := os.Getwd()
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
 
-	cfg, _, err := config.Load(workDir)
+	cfg, _, err := loadConfig(workDir)
+	if err != nil {
+		return err
+	}
+	locked, err := verifyLock(workDir)
 	if err != nil {
 		return err
 	}
 
 	hooks := cfg.GetHooks(hookType)
+	var selected []string
+	if len(hooks) == 0 {
+		if stage, _, ok := cfg.FindHook(hookType); ok {
+			selected = []string{hookType}
+			hookType = stage
+			hooks = cfg.GetHooks(stage)
+		}
+	}
 	if len(hooks) == 0 {
 		return fmt.Errorf("no hooks configured for %s", hookType)
 	}
 
+	if toRef != "" && fromRef == "" {
+		return fmt.Errorf("--to-ref requires --from-ref")
+	}
+
+	explicit := fromRef != "" || filesArgs || filesFrom != "" || lastCommit
+	// Git does not run manual and custom stages, so there are no staged
+	// files to wait for: they run on all files unless files are selected.
+	all := allFiles || (!config.IsGitStage(hookType) && !explicit)
+	prePush := hookType == "pre-push" && selected == nil && !all && !explicit && stdinIsPipe()
+
+	var hookInput []byte
+	if prePush {
+		hookInput, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read hook input: %w", err)
+		}
+	}
+
+	if err := runChainedHook(args, hookInput); err != nil {
+		return err
+	}
+
 	var files []string
-	if allFiles {
+	var changes git.ChangeSet
+	var commits []policy.Commit
+	var pushed *pushedSet
+	switch {
+	case all:
 		files, err = git.GetAllFiles()
-	} else {
-		files, err = git.GetStagedFiles()
+	case explicit:
+		changes, err = selectedChanges(workDir, cwd, args[1:])
+	case prePush:
+		remote := ""
+		if len(args) > 1 {
+			remote = args[1]
+		}
+		pushed, err = pushedChanges(bytes.NewReader(hookInput), remote)
+		if err == nil {
+			changes, commits = pushed.changes, pushed.commits
+		}
+	default:
+		changes, err = git.GetStagedChanges()
 	}
 	if err != nil {
 		return err
 	}
+	if !all {
+		files = changes.Files()
+	}
 
-	if len(files) == 0 && !allFiles {
-		fmt.Println("No staged files")
+	if len(changes) == 0 && len(commits) == 0 && !all {
+		switch {
+		case prePush:
+			fmt.Println("No pushed changes")
+		case explicit:
+			fmt.Println("No files selected")
+		default:
+			fmt.Println("No staged files")
+		}
 		return nil
 	}
 
 	executionDir := workDir
+	var room *git.CleanRoom
 	if cleanRoom {
-		yellow := color.New(color.FgYellow).SprintFunc()
-		fmt.Println(yellow("Clean-room mode:"), "Hooks will run in an isolated temporary directory")
-		fmt.Println(yellow("Warning:"), "This excludes all unstaged changes and untracked files.")
-		fmt.Println()
+		var links []string
+		yes := assumeYes
+		if cfg.CleanRoom != nil {
+			links = cfg.CleanRoom.Link
+			yes = yes || cfg.CleanRoom.Yes
+		}
 
-		if !promptConfirm("Proceed with clean-room execution?") {
-			fmt.Println("Aborted.")
-			return nil
+		if !yes {
+			yellow := color.New(color.FgYellow).SprintFunc()
+			fmt.Println(yellow("Clean-room mode:"), "Hooks will run in", git.CleanRoomDir, "with only staged files")
+			fmt.Println(yellow("Warning:"), "This excludes all unstaged changes and untracked files.")
+			fmt.Println()
+
+			if !promptConfirm("Proceed with clean-room execution?") {
+				fmt.Println("Aborted.")
+				return nil
+			}
 		}
 
-		cleanRoomDir, crErr := git.CreateCleanRoom()
-		if crErr != nil {
-			return fmt.Errorf("failed to create clean-room: %w", crErr)
+		room, err = git.SyncCleanRoom(links)
+		if err != nil {
+			return fmt.Errorf("failed to prepare clean-room: %w", err)
 		}
-		defer func() {
-			if cleanupErr := git.CleanupCleanRoom(cleanRoomDir); cleanupErr != nil {
-				fmt.Printf("Warning: failed to clean up temp directory: %v\n", cleanupErr)
-			}
-		}()
 
-		executionDir = cleanRoomDir
-		fmt.Printf("Running hooks in: %s\n\n", executionDir)
+		executionDir = room.Dir
+		if !quiet {
+			fmt.Printf("Running hooks in: %s (%d updated, %d removed)\n\n", executionDir, room.Updated, room.Removed)
+		}
 	}
 
 	cacheDir := filepath.Join(workDir, ".hooks", "cache")
 	toolMgr := tool.NewManager(cacheDir)
 	exec := executor.New(cfg, toolMgr, executionDir)
+	if locked != nil {
+		exec.SetLock(locked)
+	}
+	if room != nil {
+		exec.SetRealDir(room.RepoRoot)
+	}
 
 	if noColor {
 		color.NoColor = true
@@ -328,9 +593,17 @@ func runHook(cmd *cobra.Command, args []string) error {
 		NoColor:   noColor,
 		UseCache:  useCache,
 		SkipHooks: executor.ParseSkipEnv(),
+		Jobs:      jobs,
+		Hooks:     selected,
 	}
 	exec.SetOptions(opts)
 
+	if src := contentSource(pushed, all); src != nil {
+		defer src.Close()
+		exec.SetSource(policy.WithAddedLines(src, addedLines(pushed)))
+	}
+	exec.SetChanges(changes)
+
 	policyResult := exec.CheckPolicies(files, "")
 	if policyResult != nil && !policyResult.Passed {
 		executor.PrintPolicyResult(policyResult, quiet)
@@ -338,7 +611,14 @@ func runHook(cmd *cobra.Command, args []string) error {
 		os.Exit(1)
 	}
 
-	results := exec.Run(hookType, files, allFiles)
+	commitResult := exec.CheckCommitMessages(commits)
+	if commitResult != nil && !commitResult.Passed {
+		executor.PrintPolicyResult(commitResult, quiet)
+		fmt.Println()
+		os.Exit(1)
+	}
+
+	results := exec.Run(hookType, files, all)
 	executor.PrintResults(results, verbose, quiet)
 
 	if policyResult != nil && !quiet {
@@ -352,6 +632,222 @@ func runHook(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// selectedChanges resolves the explicit file selection flags of run. Ref
+// selections carry their diff status; paths given by the user, relative
+// to cwd, are made relative to workDir and treated as modified.
+func selectedChanges(workDir, cwd string, positional []string) (git.ChangeSet, error) {
+	switch {
+	case fromRef != "":
+		return git.GetRangeChanges(fromRef, toRef)
+	case lastCommit:
+		return git.GetCommitChanges("HEAD")
+	case filesFrom != "":
+		listPath := filesFrom
+		if listPath != "-" && !filepath.IsAbs(listPath) {
+			listPath = filepath.Join(cwd, listPath)
+		}
+		list, err := readFileList(listPath)
+		if err != nil {
+			return nil, err
+		}
+		return git.ChangesFromPaths(normalizePaths(workDir, cwd, list)), nil
+	default:
+		return git.ChangesFromPaths(normalizePaths(workDir, cwd, positional)), nil
+	}
+}
+
+// readFileList reads a NUL or newline separated list of paths from path,
+// or from stdin when path is "-".
+func readFileList(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file list: %w", err)
+	}
+
+	sep := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = "\x00"
+	}
+
+	var files []string
+	for _, entry := range strings.Split(string(data), sep) {
+		if sep == "\n" {
+			entry = strings.TrimSuffix(entry, "\r")
+		}
+		if entry != "" {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// normalizePaths makes paths, absolute or relative to cwd, relative to
+// workDir.
+func normalizePaths(workDir, cwd string, paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		if rel, err := filepath.Rel(workDir, p); err == nil {
+			p = rel
+		}
+		result = append(result, filepath.ToSlash(filepath.Clean(p)))
+	}
+	return result
+}
+
+// runChainedHook runs the hook named by HOOKRUNNER_CHAIN, which generated
+// hook scripts set when they replace or shadow another hook. It receives
+// the same arguments and input git gave us; a missing hook is ignored.
+func runChainedHook(args []string, input []byte) error {
+	chained := os.Getenv("HOOKRUNNER_CHAIN")
+	if chained == "" {
+		return nil
+	}
+	if info, err := os.Stat(chained); err != nil || info.IsDir() {
+		return nil
+	}
+
+	//nolint:gosec // G204: chained hook path is written by hookrunner install
+	chainCmd := exec.Command(chained, args[1:]...)
+	chainCmd.Stdout = os.Stdout
+	chainCmd.Stderr = os.Stderr
+	if input != nil {
+		chainCmd.Stdin = bytes.NewReader(input)
+	}
+	if err := chainCmd.Run(); err != nil {
+		return fmt.Errorf("chained hook %s failed: %w", chained, err)
+	}
+	return nil
+}
+
+// contentSource returns where policies read file contents from: the index
+// for staged runs and the commits for ref-range runs. Runs on all files and
+// selections that name working tree files return nil.
+func contentSource(pushed *pushedSet, all bool) *git.BlobSource {
+	repoRoot, err := git.FindRepoRoot()
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case all, filesArgs, filesFrom != "":
+		return nil
+	case fromRef != "":
+		rev := toRef
+		if rev == "" {
+			rev = "HEAD"
+		}
+		return git.NewBlobSource(repoRoot, rev)
+	case lastCommit:
+		return git.NewBlobSource(repoRoot, "HEAD")
+	case pushed != nil:
+		src := git.NewBlobSource(repoRoot, "HEAD")
+		src.Revs = pushed.revs
+		return src
+	default:
+		return git.NewBlobSource(repoRoot, "")
+	}
+}
+
+// addedLines returns a loader for the lines added by the change that
+// contentSource reads from, used by content rules scoped to added_lines.
+func addedLines(pushed *pushedSet) func() (map[string][]policy.AddedLine, error) {
+	return func() (map[string][]policy.AddedLine, error) {
+		var added map[string][]git.AddedLine
+		var err error
+		switch {
+		case fromRef != "":
+			added, err = git.GetRangeAddedLines(fromRef, toRef)
+		case lastCommit:
+			added, err = git.GetCommitAddedLines("HEAD")
+		case pushed != nil:
+			added, err = git.GetPushedAddedLines(pushed.refs, pushed.remote)
+		default:
+			added, err = git.GetStagedAddedLines()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string][]policy.AddedLine, len(added))
+		for path, lines := range added {
+			converted := make([]policy.AddedLine, len(lines))
+			for i, l := range lines {
+				converted[i] = policy.AddedLine{Number: l.Number, Text: l.Text}
+			}
+			result[path] = converted
+		}
+		return result, nil
+	}
+}
+
+// pushedSet is what a push would publish to the remote.
+type pushedSet struct {
+	changes git.ChangeSet
+	commits []policy.Commit
+	// revs maps each file to the pushed commit its content is read from.
+	revs   map[string]string
+	refs   []git.PushRef
+	remote string
+}
+
+// pushedChanges parses the refs git passes to pre-push and returns the
+// changes and commits they would publish to remote.
+func pushedChanges(input io.Reader, remote string) (*pushedSet, error) {
+	refs, err := git.ParsePushRefs(input)
+	if err != nil {
+		return nil, err
+	}
+
+	pushed := &pushedSet{revs: make(map[string]string), refs: refs, remote: remote}
+	index := make(map[string]int)
+	for _, ref := range refs {
+		changes, err := git.GetPushedChanges([]git.PushRef{ref}, remote)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			if i, seen := index[c.Path]; seen {
+				pushed.changes[i] = c
+			} else {
+				index[c.Path] = len(pushed.changes)
+				pushed.changes = append(pushed.changes, c)
+			}
+			if c.Status != git.StatusDeleted {
+				pushed.revs[c.Path] = ref.LocalSHA
+			}
+		}
+	}
+
+	commits, err := git.GetPushedCommits(refs, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range commits {
+		pushed.commits = append(pushed.commits, policy.Commit{SHA: c.SHA, Message: c.Message})
+	}
+	return pushed, nil
+}
+
+// stdinIsPipe reports whether stdin is redirected rather than a terminal,
+// which is how git invokes hooks that receive input.
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
 func runDirectCmd(cmd *cobra.Command, args []string) error {
 	workDir, err := os.Getwd()
 	if err != nil {
@@ -386,30 +882,37 @@ func runDirectCmd(cmd *cobra.Command, args []string) error {
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
 
-	cfg, cfgPath, err := config.Load(workDir)
+	cfg, cfgPath, err := loadConfig(workDir)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Config: %s\n\n", cfgPath)
+	fmt.Printf("Config: %s\n", cfgPath)
+	if cfg.Profile != "" {
+		_, reason := cfg.SelectProfile(profile)
+		fmt.Printf("Profile: %s (%s)\n", cfg.Profile, reason)
+	}
+	fmt.Println()
 
-	hookTypes := []string{"pre-commit", "pre-push", "commit-msg"}
-	for _, hookType := range hookTypes {
+	for _, hookType := range cfg.Stages() {
 		hooks := cfg.GetHooks(hookType)
-		if len(hooks) == 0 {
-			continue
-		}
 
 		fmt.Printf("%s:\n", hookType)
 		for _, h := range hooks {
 			extra := ""
-			if h.After != "" {
-				extra = fmt.Sprintf(" (after: %s)", h.After)
+			if len(h.Needs) > 0 {
+				extra += fmt.Sprintf(" (needs: %s)", strings.Join(h.Needs, ", "))
+			}
+			if len(h.After) > 0 {
+				extra += fmt.Sprintf(" (after: %s)", strings.Join(h.After, ", "))
+			}
+			if h.Disabled {
+				extra += " (disabled)"
 			}
 			fmt.Printf("  - %s (tool: %s)%s\n", h.Name, h.Tool, extra)
 		}
@@ -430,7 +933,7 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
 
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
@@ -441,21 +944,24 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 
 	if git.IsInsideWorkTree() {
 		fmt.Printf("%s Git repository detected\n", green("[OK]"))
+		if hooksDir, err := git.HooksDir(); err == nil {
+			fmt.Printf("%s Hooks directory: %s\n", green("[OK]"), hooksDir)
+			if configured := git.ConfiguredHooksPath(); configured != "" {
+				fmt.Printf("%s core.hooksPath is set to %s\n", green("[INFO]"), configured)
+			}
+			checkHookScripts(hooksDir)
+		}
 	} else {
 		fmt.Printf("%s Not a git repository\n", red("[FAIL]"))
 	}
 
-	cfg, cfgPath, err := config.Load(workDir)
+	cfg, cfgPath, err := loadConfig(workDir)
 	if err != nil {
 		fmt.Printf("%s Config file: %v\n", red("[FAIL]"), err)
 	} else {
 		fmt.Printf("%s Config file: %s\n", green("[OK]"), cfgPath)
 
-		hookCount := 0
-		for _, hooks := range cfg.Hooks {
-			hookCount += len(hooks)
-		}
-		fmt.Printf("%s Hooks configured: %d\n", green("[OK]"), hookCount)
+		fmt.Printf("%s Hooks configured: %d\n", green("[OK]"), cfg.HookCount())
 
 		toolCount := len(cfg.Tools)
 		fmt.Printf("%s Tools defined: %d\n", green("[OK]"), toolCount)
@@ -474,6 +980,40 @@ func runDoctor(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// checkHookScripts warns about hook scripts in hooksDir written by an older
+// hookrunner, which may lack fixes made to the script since.
+func checkHookScripts(hooksDir string) {
+	yellow := color.New(color.FgYellow).SprintFunc()
+	for _, hookType := range config.GitStages() {
+		content, err := os.ReadFile(filepath.Join(hooksDir, hookType))
+		if err != nil || !git.IsManagedHook(content) {
+			continue
+		}
+		installed := git.ManagedHookVersion(content)
+		if !hookScriptOutdated(installed) {
+			continue
+		}
+		if installed == "" {
+			installed = "an unknown version"
+		}
+		fmt.Printf("%s %s hook was installed by hookrunner %s (running %s); run 'hookrunner install' to update it\n",
+			yellow("[WARN]"), hookType, installed, version.String())
+	}
+}
+
+// hookScriptOutdated reports whether a hook script recording version
+// installed predates this hookrunner.
+func hookScriptOutdated(installed string) bool {
+	if installed == "" {
+		return true
+	}
+	cmp, err := version.Compare(installed, version.String())
+	if err != nil {
+		return installed != version.String()
+	}
+	return cmp < 0
+}
+
 func runInit(cmd *cobra.Command, args []string) error {
 	workDir, err := os.Getwd()
 	if err != nil {
@@ -525,7 +1065,7 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	red := color.New(color.FgRed).SprintFunc()
 	yellow := color.New(color.FgYellow).SprintFunc()
 
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
@@ -545,14 +1085,38 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	fmt.Printf("%s Config file: %s\n", green("[OK]"), cfgPath)
+	for _, note := range cfg.Upgrades {
+		fmt.Printf("%s Outdated config, upgraded when loaded: %s\n", yellow("[WARN]"), note)
+		warnings++
+	}
+	if len(cfg.Upgrades) > 0 {
+		fmt.Println(yellow("Suggestion:") + " Run 'hookrunner config upgrade' to rewrite it")
+	}
 
-	// Check 2: Hooks exist
-	hookTypes := []string{"pre-commit", "pre-push", "commit-msg"}
-	totalHooks := 0
-	for _, hookType := range hookTypes {
-		hooks := cfg.GetHooks(hookType)
-		totalHooks += len(hooks)
+	// Every profile must apply cleanly; the selected one is validated below.
+	for _, name := range cfg.ProfileNames() {
+		pcfg, _, err := config.Load(workDir)
+		if err == nil {
+			err = pcfg.ApplyProfile(name)
+		}
+		if err != nil {
+			fmt.Printf("%s Profile '%s': %v\n", red("[ERROR]"), name, err)
+			errors++
+		} else {
+			fmt.Printf("%s Profile '%s' applies\n", green("[OK]"), name)
+		}
 	}
+	if name, reason := cfg.SelectProfile(profile); name != "" {
+		if err := cfg.ApplyProfile(name); err != nil {
+			fmt.Printf("%s %v (from %s)\n", red("[ERROR]"), err, reason)
+			return nil
+		}
+		fmt.Printf("%s Validating with profile '%s' (%s)\n", green("[OK]"), name, reason)
+	}
+
+	// Check 2: Hooks exist
+	hookTypes := cfg.Stages()
+	totalHooks := cfg.HookCount()
 	if totalHooks == 0 {
 		fmt.Printf("%s No hooks configured\n", yellow("[WARN]"))
 		warnings++
@@ -568,9 +1132,9 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		}
 
 		graph := dag.BuildGraph(hooks)
-		if graph.HasCycle() {
-			fmt.Printf("%s %s hooks have circular dependency\n", red("[ERROR]"), hookType)
-			fmt.Println(yellow("Suggestion:") + " Check 'after' fields for cycles")
+		if cycle := graph.FindCycle(); cycle != nil {
+			fmt.Printf("%s %s hooks have circular dependency: %s\n", red("[ERROR]"), hookType, strings.Join(cycle, " -> "))
+			fmt.Println(yellow("Suggestion:") + " Check 'after' and 'needs' fields for cycles")
 			errors++
 		} else {
 			fmt.Printf("%s %s DAG is valid\n", green("[OK]"), hookType)
@@ -584,13 +1148,19 @@ func runValidate(cmd *cobra.Command, args []string) error {
 				errors++
 			}
 			names[h.Name] = true
+			if len(cfg.GetHooks(h.Name)) > 0 {
+				fmt.Printf("%s Hook '%s' in %s is named like a stage; 'run %s' runs the stage\n", yellow("[WARN]"), h.Name, hookType, h.Name)
+				warnings++
+			}
 		}
 
-		// Check 5: 'after' references exist
+		// Check 5: 'after' and 'needs' references exist
 		for _, h := range hooks {
-			if h.After != "" && !names[h.After] {
-				fmt.Printf("%s Hook '%s' references unknown hook '%s' in 'after'\n", red("[ERROR]"), h.Name, h.After)
-				errors++
+			for _, dep := range h.Dependencies() {
+				if !names[dep] {
+					fmt.Printf("%s Hook '%s' references unknown hook '%s' in 'after'/'needs'\n", red("[ERROR]"), h.Name, dep)
+					errors++
+				}
 			}
 		}
 
@@ -611,7 +1181,13 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Check 7: Tools are available
+	// Check 7: Variables are defined
+	for _, msg := range checkVariables(cfg, workDir) {
+		fmt.Printf("%s %s\n", red("[ERROR]"), msg)
+		errors++
+	}
+
+	// Check 8: Tools are available
 	cacheDir := filepath.Join(workDir, ".hooks", "cache")
 	toolMgr := tool.NewManager(cacheDir)
 	checkedTools := make(map[string]bool)
@@ -647,13 +1223,51 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// checkVariables expands the ${...} references in every hook and tool
+// install URL of cfg as a run would, and describes those that refer to
+// undefined variables.
+func checkVariables(cfg *config.Config, workDir string) []string {
+	scriptsDir := cfg.ScriptsDir
+	if scriptsDir == "" {
+		scriptsDir = ".hooks"
+	}
+	vars := config.Vars{
+		RepoRoot:   workDir,
+		ScriptsDir: filepath.Join(workDir, scriptsDir),
+		CacheDir:   filepath.Join(workDir, ".hooks", "cache"),
+	}
+
+	var problems []string
+	for _, hookType := range cfg.Stages() {
+		vars.HookType = hookType
+		for _, h := range cfg.GetHooks(hookType) {
+			if _, err := h.Interpolate(vars); err != nil {
+				problems = append(problems, fmt.Sprintf("Hook '%s' in %s: %v", h.Name, hookType, err))
+			}
+		}
+	}
+
+	vars.HookType = ""
+	names := make([]string, 0, len(cfg.Tools))
+	for name := range cfg.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := cfg.Tools[name].Interpolate(vars); err != nil {
+			problems = append(problems, fmt.Sprintf("Tool '%s': %v", name, err))
+		}
+	}
+	return problems
+}
+
 func runPolicyList(cmd *cobra.Command, args []string) error {
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
 
-	cfg, _, err := config.Load(workDir)
+	cfg, _, err := loadConfig(workDir)
 	if err != nil {
 		return err
 	}
@@ -705,12 +1319,12 @@ func runPolicyList(cmd *cobra.Command, args []string) error {
 }
 
 func runPolicyFetch(cmd *cobra.Command, args []string) error {
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
 
-	cfg, _, err := config.Load(workDir)
+	cfg, _, err := loadConfig(workDir)
 	if err != nil {
 		return err
 	}
@@ -735,7 +1349,7 @@ func runPolicyFetch(cmd *cobra.Command, args []string) error {
 }
 
 func runPolicyClearCache(cmd *cobra.Command, args []string) error {
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
@@ -752,7 +1366,7 @@ func runPolicyClearCache(cmd *cobra.Command, args []string) error {
 }
 
 func runCacheClear(cmd *cobra.Command, args []string) error {
-	workDir, err := os.Getwd()
+	workDir, err := repoDir()
 	if err != nil {
 		return err
 	}
@@ -771,6 +1385,13 @@ func runCacheClear(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println("Hook cache cleared")
+
+	if git.IsInsideWorkTree() {
+		if err := git.RemoveCleanRoom(); err != nil {
+			return fmt.Errorf("failed to remove clean-room: %w", err)
+		}
+		fmt.Println("Clean-room removed")
+	}
 	return nil
 }
 
@@ -803,11 +1424,17 @@ func convertConfigRules(r config.PolicyRules) policy.PolicyRules {
 		}
 	}
 
+	regexBlock := make([]policy.RegexBlockPattern, 0, len(r.RegexBlock))
+	for _, p := range r.RegexBlock {
+		regexBlock = append(regexBlock, policy.RegexBlockPattern{Pattern: p.Pattern, Scope: p.Scope})
+	}
+
 	patterns := make([]policy.ForbiddenContentPattern, 0, len(r.ForbidFileContent))
 	for _, p := range r.ForbidFileContent {
 		patterns = append(patterns, policy.ForbiddenContentPattern{
 			Pattern:     p.Pattern,
 			Description: p.Description,
+			Scope:       p.Scope,
 		})
 	}
 
@@ -816,9 +1443,13 @@ func convertConfigRules(r config.PolicyRules) policy.PolicyRules {
 		ForbidDirectories:    r.ForbidDirectories,
 		ForbidFileExtensions: r.ForbidFileExtensions,
 		RequiredFiles:        r.RequiredFiles,
+		ForbidDelete:         r.ForbidDelete,
+		ForbidRenameFrom:     r.ForbidRenameFrom,
 		MaxFileSizeKB:        r.MaxFileSizeKB,
 		MaxFilesChanged:      r.MaxFilesChanged,
 		ForbidFileContent:    patterns,
+		RegexBlock:           regexBlock,
+		ExcludeExtensions:    r.ExcludeExtensions,
 		CommitMessage:        cm,
 		EnforceHooks:         r.EnforceHooks,
 		HookTimeBudgetMs:     r.HookTimeBudgetMs,
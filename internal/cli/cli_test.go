@@ -1,7 +1,17 @@
 package cli
 
 import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/lock"
+	"github.com/ashavijit/hookrunner/internal/version"
+	"github.com/spf13/cobra"
 )
 
 func TestRootCmd(t *testing.T) {
@@ -67,6 +77,12 @@ func TestRunCmdFlags(t *testing.T) {
 	if flags.Lookup("clean-room") == nil {
 		t.Error("missing --clean-room flag")
 	}
+	if f := flags.Lookup("yes"); f == nil || f.Shorthand != "y" {
+		t.Error("missing --yes/-y flag")
+	}
+	if f := flags.Lookup("jobs"); f == nil || f.Shorthand != "j" {
+		t.Error("missing --jobs/-j flag")
+	}
 }
 
 func TestInitCmdFlags(t *testing.T) {
@@ -93,3 +109,420 @@ func TestExecute(t *testing.T) {
 	}
 	rootCmd.Args = oldArgs
 }
+
+func TestRunCmdFileSelectionFlags(t *testing.T) {
+	flags := runCmd.Flags()
+
+	for _, name := range []string{"from-ref", "to-ref", "files", "files-from", "last-commit"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("missing --%s flag", name)
+		}
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"newline", "a.go\nb c.go\r\n\n", []string{"a.go", "b c.go"}},
+		{"nul", "a.go\x00with\nnewline.go\x00", []string{"a.go", "with\nnewline.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := readFileList(path)
+			if err != nil {
+				t.Fatalf("readFileList failed: %v", err)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePaths(t *testing.T) {
+	workDir := filepath.Join(string(filepath.Separator), "repo")
+	got := normalizePaths(workDir, workDir, []string{
+		filepath.Join(workDir, "pkg", "a.go"),
+		"./b.go",
+	})
+
+	if len(got) != 2 || got[0] != "pkg/a.go" || got[1] != "b.go" {
+		t.Errorf("unexpected paths: %v", got)
+	}
+
+	got = normalizePaths(workDir, filepath.Join(workDir, "pkg"), []string{"a.go", "../b.go"})
+	if len(got) != 2 || got[0] != "pkg/a.go" || got[1] != "b.go" {
+		t.Errorf("expected paths relative to cwd to be made relative to the root, got %v", got)
+	}
+}
+
+func TestHookScriptOutdated(t *testing.T) {
+	tests := []struct {
+		installed string
+		want      bool
+	}{
+		{"", true},
+		{"0.1.0", true},
+		{version.String(), false},
+		{"999.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := hookScriptOutdated(tt.installed); got != tt.want {
+			t.Errorf("hookScriptOutdated(%q) = %v, want %v", tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	t.Setenv("UNDEFINED", "")
+	if err := os.Unsetenv("UNDEFINED"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`tools:
+  lint:
+    version: "1.0"
+    install:
+      linux: https://example.com/${UNDEFINED}/lint
+hooks:
+  pre-commit:
+    - name: build
+      run: make ${UNDEFINED}
+`)
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems := checkVariables(cfg, dir)
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %q", problems)
+	}
+	for i, want := range []string{"Hook 'build' in pre-commit: run: undefined variable ${UNDEFINED}", "Tool 'lint': install.linux: undefined variable ${UNDEFINED}"} {
+		if !strings.HasPrefix(problems[i], want) {
+			t.Errorf("expected %q, got %q", want, problems[i])
+		}
+	}
+
+	write(`hooks:
+  pre-commit:
+    - name: build
+      run: make ${UNDEFINED:-x} ${repo_root}
+`)
+	if cfg, _, err = config.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if problems := checkVariables(cfg, dir); len(problems) != 0 {
+		t.Errorf("expected defaults to pass, got %q", problems)
+	}
+}
+
+func TestGraphCmdFlags(t *testing.T) {
+	flags := graphCmd.Flags()
+	if f := flags.Lookup("format"); f == nil || f.DefValue != "dot" {
+		t.Error("missing --format flag defaulting to dot")
+	}
+	if flags.Lookup("history") == nil {
+		t.Error("missing --history flag")
+	}
+}
+
+func TestConfigSourcesCmd(t *testing.T) {
+	dir := t.TempDir()
+	shared := "templates:\n  lint:\n    run: echo lint\nhooks:\n  pre-commit:\n    - name: shared\n      run: echo shared\n"
+	main := "include: [shared.yaml]\nhooks:\n  pre-commit:\n    - name: lint\n      extends: lint\n"
+	if err := os.WriteFile(filepath.Join(dir, "shared.yaml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	configSourcesCmd.SetOut(&out)
+	defer configSourcesCmd.SetOut(nil)
+	if err := runConfigSources(configSourcesCmd, nil); err != nil {
+		t.Fatalf("config sources failed: %v", err)
+	}
+
+	for _, want := range []string{"  shared.yaml\n  hooks.yaml\n", "shared.yaml:6", "hooks.yaml:4", "(extends lint, shared.yaml:2)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestMigrateCmd(t *testing.T) {
+	flags := migrateCmd.Flags()
+	if f := flags.Lookup("output"); f == nil || f.DefValue != "hooks.yaml" {
+		t.Error("missing --output flag defaulting to hooks.yaml")
+	}
+
+	dir := t.TempDir()
+	lefthook := "pre-commit:\n  commands:\n    vet:\n      run: go vet ./...\n"
+	if err := os.WriteFile(filepath.Join(dir, "lefthook.yml"), []byte(lefthook), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte("hooks: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	migrateFrom, migrateOutput, migrateForce = "lefthook", "hooks.yaml", false
+	defer func() { migrateFrom, migrateOutput, migrateForce = "", "hooks.yaml", false }()
+	if err := runMigrate(migrateCmd, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an existing hooks.yaml to be kept, got %v", err)
+	}
+
+	var out bytes.Buffer
+	migrateCmd.SetOut(&out)
+	defer migrateCmd.SetOut(nil)
+	migrateForce = true
+	if err := runMigrate(migrateCmd, nil); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hooks.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "run: go vet ./...") {
+		t.Errorf("hooks.yaml not migrated:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lefthook.yml")); err != nil {
+		t.Error("lefthook.yml should be left in place")
+	}
+}
+
+func TestLockCmd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("hooks.yaml", "include: [shared.yaml]\n")
+	write("shared.yaml", "exclude_tags: [slow]\n")
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	lockCmd.SetOut(&out)
+	defer lockCmd.SetOut(nil)
+	defer func() { lockUpdate = false }()
+
+	if err := runLock(lockCmd, nil); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Wrote hooks.lock: 0 tools, 0 policies, 1 includes") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	write("hooks-local.yaml", "tools:\n  lint:\n    version: \"2.0\"\n    install:\n      linux: https://example.com/lint-${version}.tar.gz\n")
+	if _, err := verifyLock(dir); err != nil {
+		t.Errorf("expected hooks-local to be left out of the lock, got %v", err)
+	}
+
+	write("shared.yaml", "exclude_tags: [lint]\n")
+	if _, err := verifyLock(dir); err == nil || !strings.Contains(err.Error(), "include shared.yaml changed") {
+		t.Errorf("expected run to fail on drift, got %v", err)
+	}
+	out.Reset()
+	if err := runLock(lockCmd, nil); err == nil || !strings.Contains(out.String(), "changed include shared.yaml") {
+		t.Errorf("expected lock to report drift, got %v: %q", err, out.String())
+	}
+
+	lockUpdate = true
+	if err := runLock(lockCmd, nil); err != nil {
+		t.Fatalf("lock --update failed: %v", err)
+	}
+	if _, err := verifyLock(dir); err != nil {
+		t.Errorf("unexpected drift after --update: %v", err)
+	}
+	if l, err := lock.Read(dir); err != nil || len(l.Tools) != 0 {
+		t.Errorf("expected no hooks-local tools in hooks.lock, got %+v, %v", l, err)
+	}
+}
+
+func TestProfileFlag(t *testing.T) {
+	for _, cmd := range []*cobra.Command{runCmd, listCmd, validateCmd, graphCmd, installCmd, doctorCmd, policyListCmd, policyFetchCmd, configShowCmd, configDiffCmd} {
+		if cmd.Flags().Lookup("profile") == nil {
+			t.Errorf("%s: missing --profile flag", cmd.Name())
+		}
+	}
+}
+
+func TestConfigShowCmd(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("shared.yaml", "templates:\n  lint:\n    run: golangci-lint run\n")
+	write("hooks.yaml", `include: [shared.yaml]
+policies:
+  localPolicies:
+    - name: base
+      rules:
+        max_file_size_kb: 100
+  policies:
+    - url: http://policies.invalid/org.yaml
+hooks:
+  pre-commit:
+    - name: lint
+      extends: lint
+profiles:
+  ci:
+    jobs: 2
+`)
+	write("hooks-local.yaml", "hooks:\n  pre-commit:\n    - name: lint\n      args: [--fix]\n")
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	configShowCmd.SetOut(&out)
+	defer configShowCmd.SetOut(nil)
+	defer func() { showResolved, showExplain, showFormat, profile = false, false, "yaml", "" }()
+
+	showResolved, showExplain, profile = true, true, "ci"
+	if err := runConfigShow(configShowCmd, nil); err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	for _, want := range []string{
+		"profile: ci # --profile\n",
+		"run: golangci-lint run # shared.yaml:3\n",
+		"args: [--fix] # hooks-local.yaml:4\n",
+		"jobs: 2 # hooks.yaml:15\n",
+		"url: http://policies.invalid/org.yaml # hooks.yaml:8 (not loaded, no rules: HTTPS required: http://policies.invalid/org.yaml)\n",
+		"effective_policy:\n  max_file_size_kb: 100 # base (hooks.yaml:4)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "profiles:") || strings.Contains(out.String(), "templates:") {
+		t.Errorf("resolved output should not list profiles or templates:\n%s", out.String())
+	}
+
+	out.Reset()
+	showFormat = "json"
+	if err := runConfigShow(configShowCmd, nil); err != nil {
+		t.Fatalf("config show --format json failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `"hooks.pre-commit.lint.args": "hooks-local.yaml:4"`) {
+		t.Errorf("json output missing origins:\n%s", out.String())
+	}
+}
+
+func TestConfigDiffCmd(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("jobs: 2\nhooks:\n  pre-commit:\n    - name: vet\n      run: go vet ./...\n      args: [-v]\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	write("parallel: true\nhooks:\n  pre-commit:\n    - name: vet\n      run: go vet ./...\n      args: [-v, -x]\n")
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	configDiffCmd.SetOut(&out)
+	defer configDiffCmd.SetOut(nil)
+	if err := runConfigDiff(configDiffCmd, []string{"HEAD"}); err != nil {
+		t.Fatalf("config diff failed: %v", err)
+	}
+	for _, want := range []string{
+		"--- HEAD\n+++ working tree\n",
+		"~ hooks.pre-commit.vet.args: [-v] -> [-v, -x]\n",
+		"- jobs: 2\n",
+		"+ parallel: true\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := runConfigDiff(configDiffCmd, []string{"HEAD", "HEAD"}); err != nil {
+		t.Fatalf("config diff failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No differences") {
+		t.Errorf("expected no differences, got:\n%s", out.String())
+	}
+}
+
+func TestConfigUpgradeCmd(t *testing.T) {
+	dir := t.TempDir()
+	old := "# Policies\npolicies:\n  localPolicies:\n    size:\n      max_file_size_kb: 100\n"
+	if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	configUpgradeCmd.SetOut(&out)
+	defer configUpgradeCmd.SetOut(nil)
+	defer func() { upgradeCheck = false }()
+
+	upgradeCheck = true
+	if err := runConfigUpgrade(configUpgradeCmd, nil); err == nil || !strings.Contains(out.String(), "hooks.yaml needs upgrading") {
+		t.Errorf("expected --check to fail, got %v: %q", err, out.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "hooks.yaml")); string(data) != old {
+		t.Error("--check should not write")
+	}
+
+	upgradeCheck = false
+	out.Reset()
+	if err := runConfigUpgrade(configUpgradeCmd, nil); err != nil {
+		t.Fatalf("config upgrade failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hooks.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Policies\nversion: 2\n") || !strings.Contains(string(data), "- name: size\n") {
+		t.Errorf("hooks.yaml not upgraded:\n%s", data)
+	}
+
+	out.Reset()
+	if err := runConfigUpgrade(configUpgradeCmd, nil); err != nil || !strings.Contains(out.String(), "All config files are at version 2") {
+		t.Errorf("expected nothing left to upgrade, got %v: %q", err, out.String())
+	}
+}
@@ -0,0 +1,555 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/policy"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	upgradeCheck bool
+	showResolved bool
+	showFormat   string
+	showExplain  bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the loaded configuration",
+}
+
+var configSourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "Show the files the config was loaded from and where each hook came from",
+	Long: `List the config files that were merged, in merge order (later files
+override earlier ones), followed by the file and line each hook was defined
+at and the template it extends.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigSources,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the config, or with --resolved the config that will run",
+	Long: `Print the root config file. With --resolved, print the effective config
+instead: includes, templates, hooks-local, nested configs and the selected
+profile applied, followed by the effective policy rules of the local and
+remote policies.
+
+--explain annotates each resolved value with the file and line it was set
+at, and each policy rule with the policies that set it.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff <ref> [<ref>]",
+	Short: "Compare the effective config between two git refs",
+	Long: `Compare the effective config committed at the first ref with the one
+at the second, or with the working tree when only one ref is given. Each
+value that was added (+), removed (-) or changed (~) is listed by its
+path, such as hooks.pre-commit.lint.args.
+
+hooks-local is only read for the working tree, as it is not committed.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runConfigDiff,
+}
+
+var configUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Rewrite config files in the current config version",
+	Long: fmt.Sprintf(`Migrate each config file in the repository that was loaded, including
+hooks-local and local includes, to config version %d and set its version
+field. Comments in YAML files are kept.
+
+Older files are also upgraded in memory whenever they are loaded; this
+writes the result so the config matches what runs. With --check, nothing
+is written and the command fails if a file needs upgrading.`, config.CurrentVersion),
+	Args: cobra.NoArgs,
+	RunE: runConfigUpgrade,
+}
+
+func init() {
+	configUpgradeCmd.Flags().BoolVar(&upgradeCheck, "check", false, "Only report files that need upgrading")
+	configShowCmd.Flags().BoolVar(&showResolved, "resolved", false, "Print the effective config and policy rules")
+	configShowCmd.Flags().StringVar(&showFormat, "format", "yaml", "Output format: yaml or json")
+	configShowCmd.Flags().BoolVar(&showExplain, "explain", false, "Annotate each resolved value with where it was set")
+	configCmd.AddCommand(configSourcesCmd, configShowCmd, configDiffCmd, configUpgradeCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigSources(cmd *cobra.Command, args []string) error {
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := config.Load(workDir)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintln(out, "Sources:")
+	for _, src := range cfg.Sources {
+		fmt.Fprintf(out, "  %s\n", relToDir(workDir, src))
+	}
+
+	hookTypes := make([]string, 0, len(cfg.Hooks))
+	for hookType := range cfg.Hooks {
+		hookTypes = append(hookTypes, hookType)
+	}
+	sort.Strings(hookTypes)
+
+	for _, hookType := range hookTypes {
+		fmt.Fprintf(out, "\n%s:\n", hookType)
+		for _, h := range cfg.Hooks[hookType] {
+			line := fmt.Sprintf("  %-20s %s", h.Name, originString(workDir, h.Origin))
+			if h.Extends != "" {
+				line += fmt.Sprintf("  (extends %s, %s)", h.Extends, originString(workDir, cfg.Templates[h.Extends].Origin))
+			}
+			fmt.Fprintln(out, line)
+		}
+	}
+	return nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	if showFormat != "yaml" && showFormat != "json" {
+		return fmt.Errorf("unknown format %q (use yaml or json)", showFormat)
+	}
+	if showExplain && !showResolved {
+		return fmt.Errorf("--explain needs --resolved")
+	}
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+
+	if !showResolved {
+		_, path, err := config.Load(workDir)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if showFormat == "yaml" && filepath.Ext(path) != ".json" {
+			_, err = out.Write(data)
+			return err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		return printNode(out, &node, nil)
+	}
+
+	cfg, _, err := loadConfig(workDir)
+	if err != nil {
+		return err
+	}
+	node, origins, err := resolvedConfig(cfg, workDir)
+	if err != nil {
+		return err
+	}
+	if !showExplain {
+		origins = nil
+	}
+	return printNode(out, node, origins)
+}
+
+// printNode writes n in the --format. With origins, YAML values get a
+// comment with their origin and JSON output is wrapped with an origins
+// object keyed by path.
+func printNode(out io.Writer, n *yaml.Node, origins map[string]string) error {
+	if showFormat == "json" {
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		if origins != nil {
+			v = map[string]interface{}{"config": v, "origins": origins}
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	if origins != nil {
+		annotate(n, "", origins)
+	}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func runConfigDiff(cmd *cobra.Command, args []string) error {
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+	from, to := args[0], ""
+	if len(args) == 2 {
+		to = args[1]
+	}
+
+	before, err := resolvedValues(workDir, from)
+	if err != nil {
+		return fmt.Errorf("%s: %w", from, err)
+	}
+	after, err := resolvedValues(workDir, to)
+	if err != nil {
+		if to == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", to, err)
+	}
+
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	toName := to
+	if toName == "" {
+		toName = "working tree"
+	}
+	out := cmd.OutOrStdout()
+	red := color.New(color.FgRed).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", from, toName)
+	changes := 0
+	for _, p := range paths {
+		b, inBefore := before[p]
+		a, inAfter := after[p]
+		switch {
+		case !inAfter:
+			fmt.Fprintln(out, red(fmt.Sprintf("- %s: %s", p, b)))
+		case !inBefore:
+			fmt.Fprintln(out, green(fmt.Sprintf("+ %s: %s", p, a)))
+		case a != b:
+			fmt.Fprintln(out, yellow(fmt.Sprintf("~ %s: %s -> %s", p, b, a)))
+		default:
+			continue
+		}
+		changes++
+	}
+	if changes == 0 {
+		fmt.Fprintln(out, "No differences")
+	}
+	return nil
+}
+
+func runConfigUpgrade(cmd *cobra.Command, args []string) error {
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+	cfg, _, err := config.Load(workDir)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	seen := make(map[string]bool)
+	pending := 0
+	for _, src := range cfg.Sources {
+		// Files read from git or the include cache are not ours to edit.
+		rel, err := filepath.Rel(workDir, src)
+		if err != nil || !filepath.IsAbs(src) || strings.HasPrefix(rel, "..") || seen[src] {
+			continue
+		}
+		seen[src] = true
+		info, err := os.Stat(src)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		upgraded, notes, err := config.Upgrade(src, data)
+		if err != nil {
+			return err
+		}
+		if len(notes) == 0 {
+			continue
+		}
+
+		pending++
+		if upgradeCheck {
+			fmt.Fprintf(out, "%s needs upgrading:\n", rel)
+		} else {
+			if err := os.WriteFile(src, upgraded, info.Mode().Perm()); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Upgraded %s:\n", rel)
+		}
+		for _, note := range notes {
+			fmt.Fprintf(out, "  %s\n", note)
+		}
+	}
+
+	switch {
+	case pending == 0:
+		fmt.Fprintf(out, "All config files are at version %d\n", config.CurrentVersion)
+	case upgradeCheck:
+		return fmt.Errorf("%d config file(s) need upgrading (run 'hookrunner config upgrade')", pending)
+	}
+	return nil
+}
+
+// resolvedConfig renders the effective config and policy rules of cfg,
+// with unset fields left out, and returns it with the origin of each value
+// by path.
+func resolvedConfig(cfg *config.Config, workDir string) (*yaml.Node, map[string]string, error) {
+	view := *cfg
+	view.Include, view.Templates, view.Profiles = nil, nil, nil
+	var node yaml.Node
+	if err := node.Encode(&view); err != nil {
+		return nil, nil, err
+	}
+	prune(&node)
+
+	origins := make(map[string]string, len(cfg.Origins))
+	for p, o := range cfg.Origins {
+		origins[p] = originString(workDir, o)
+	}
+	if name, reason := cfg.SelectProfile(profile); name != "" && name == cfg.Profile {
+		node.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "profile"},
+			{Kind: yaml.ScalarNode, Value: name},
+		}, node.Content...)
+		origins["profile"] = reason
+	}
+
+	rules, ruleOrigins, unloaded := effectivePolicy(cfg, workDir)
+	for url, err := range unloaded {
+		path := config.OriginPath("policies", "policies", url, "url")
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		origins[path] = strings.TrimSpace(origins[path] + " (not loaded, no rules: " + msg + ")")
+	}
+	if rules != nil {
+		var rn yaml.Node
+		if err := rn.Encode(rules); err != nil {
+			return nil, nil, err
+		}
+		if !prune(&rn) {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "effective_policy"}, &rn)
+			for key, o := range ruleOrigins {
+				origins[config.OriginPath("effective_policy", key)] = o
+			}
+		}
+	}
+	return &node, origins, nil
+}
+
+// effectivePolicy loads the policies of cfg and returns the rules they add
+// and the policies each rule comes from. Remote policies that cannot be
+// loaded add no rules; their errors are returned by URL.
+func effectivePolicy(cfg *config.Config, workDir string) (*policy.PolicyRules, map[string]string, map[string]error) {
+	if cfg.Policies == nil {
+		return nil, nil, nil
+	}
+	userCfg := buildUserConfig(cfg.Policies)
+	fetcher := policy.NewFetcher(filepath.Join(workDir, ".hooks", "cache"))
+
+	type source struct {
+		label string
+		rules policy.PolicyRules
+	}
+	var sources []source
+	var unloaded map[string]error
+	for _, ref := range userCfg.Policies {
+		rp, err := fetcher.LoadPolicy(ref.URL)
+		if err != nil {
+			if unloaded == nil {
+				unloaded = make(map[string]error)
+			}
+			unloaded[ref.URL] = err
+			continue
+		}
+		sources = append(sources, source{fmt.Sprintf("%s (%s)", rp.Identifier(), ref.URL), rp.Rules})
+	}
+	for _, lp := range userCfg.LocalPolicies {
+		label := lp.Identifier()
+		if o, ok := cfg.Origins[config.OriginPath("policies", "localPolicies", lp.Name)]; ok {
+			label += " (" + originString(workDir, o) + ")"
+		}
+		sources = append(sources, source{label, lp.Rules})
+	}
+
+	var rules policy.PolicyRules
+	for _, s := range sources {
+		rules = rules.Merge(s.rules)
+	}
+
+	origins := make(map[string]string)
+	rt := reflect.TypeOf(policy.PolicyRules{})
+	for i := 0; i < rt.NumField(); i++ {
+		key, _, _ := strings.Cut(rt.Field(i).Tag.Get("yaml"), ",")
+		var labels []string
+		for _, s := range sources {
+			if !reflect.ValueOf(s.rules).Field(i).IsZero() {
+				labels = append(labels, s.label)
+			}
+		}
+		if len(labels) > 0 {
+			origins[key] = strings.Join(labels, ", ")
+		}
+	}
+	return &rules, origins, unloaded
+}
+
+// resolvedValues returns each value of the effective config at ref, or
+// in the working tree when ref is empty, by path. Lists of scalars are
+// one value.
+func resolvedValues(workDir, ref string) (map[string]string, error) {
+	cfg, _, err := loadConfigAt(workDir, ref)
+	if err != nil {
+		return nil, err
+	}
+	node, _, err := resolvedConfig(cfg, workDir)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	var walk func(path string, n *yaml.Node)
+	walk = func(path string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(childPath(path, n.Content[i].Value), n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			if n.Style == yaml.FlowStyle {
+				items := make([]string, len(n.Content))
+				for i, item := range n.Content {
+					items[i] = item.Value
+				}
+				values[path] = "[" + strings.Join(items, ", ") + "]"
+				return
+			}
+			for i, item := range n.Content {
+				walk(config.OriginPath(path, config.ItemKey(item, i)), item)
+			}
+		default:
+			values[path] = n.Value
+		}
+	}
+	walk("", node)
+	return values, nil
+}
+
+// prune removes empty values, such as unset hook fields, from n and
+// writes lists of scalars in flow style. It reports whether n is empty.
+func prune(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.MappingNode:
+		kept := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !prune(n.Content[i+1]) {
+				kept = append(kept, n.Content[i], n.Content[i+1])
+			}
+		}
+		n.Content = kept
+		return len(n.Content) == 0
+	case yaml.SequenceNode:
+		scalars := true
+		for _, item := range n.Content {
+			prune(item)
+			scalars = scalars && item.Kind == yaml.ScalarNode
+		}
+		if scalars {
+			n.Style = yaml.FlowStyle
+		}
+		return len(n.Content) == 0
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!null":
+			return true
+		case "!!str":
+			return n.Value == ""
+		case "!!int":
+			return n.Value == "0"
+		case "!!bool":
+			return n.Value == "false"
+		}
+	}
+	return false
+}
+
+// annotate adds the origin of each value under n, at path, as a comment.
+func annotate(n *yaml.Node, path string, origins map[string]string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := childPath(path, key.Value)
+			if o, ok := origins[p]; ok {
+				if value.Kind == yaml.ScalarNode || value.Style == yaml.FlowStyle {
+					value.LineComment = o
+				} else {
+					key.LineComment = o
+				}
+			}
+			annotate(value, p, origins)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			annotate(item, config.OriginPath(path, config.ItemKey(item, i)), origins)
+		}
+	}
+}
+
+func childPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return config.OriginPath(path, key)
+}
+
+func originString(dir string, o config.Origin) string {
+	o.File = relToDir(dir, o.File)
+	return o.String()
+}
+
+// relToDir shortens paths inside dir; git sources and paths outside dir
+// are returned as they are.
+func relToDir(dir, path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/dag"
+	"github.com/ashavijit/hookrunner/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphFormat  string
+	graphHistory bool
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [hook-type]",
+	Short: "Export the hook dependency graph",
+	Long: `Print the dependency graph of a hook type (default pre-commit) as
+Graphviz DOT, Mermaid or JSON. Solid edges are 'needs', dashed edges 'after'.
+
+With --history, each hook is annotated with the duration and status of its
+last run, as recorded in .hookrunner/history.json.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGraph,
+}
+
+func init() {
+	graphCmd.Flags().StringVar(&graphFormat, "format", "dot", "Output format (dot, mermaid, json)")
+	graphCmd.Flags().BoolVar(&graphHistory, "history", false, "Annotate hooks with their last run duration and status")
+	rootCmd.AddCommand(graphCmd)
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	hookType := "pre-commit"
+	if len(args) > 0 {
+		hookType = args[0]
+	}
+
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := loadConfig(workDir)
+	if err != nil {
+		return err
+	}
+
+	hooks := cfg.GetHooks(hookType)
+	if len(hooks) == 0 {
+		return fmt.Errorf("no hooks configured for %s", hookType)
+	}
+
+	graph := dag.BuildGraph(hooks)
+
+	var notes map[string]dag.Annotation
+	if graphHistory {
+		notes = lastRuns(history.Load(workDir), hookType, hooks)
+	}
+
+	out := cmd.OutOrStdout()
+	switch graphFormat {
+	case "dot":
+		return graph.WriteDOT(out, hookType, notes)
+	case "mermaid":
+		return graph.WriteMermaid(out, notes)
+	case "json":
+		return graph.WriteJSON(out, hookType, notes)
+	default:
+		return fmt.Errorf("unknown format %q (use dot, mermaid or json)", graphFormat)
+	}
+}
+
+func lastRuns(hist *history.History, hookType string, hooks []config.Hook) map[string]dag.Annotation {
+	notes := make(map[string]dag.Annotation)
+	for _, h := range hooks {
+		if e, ok := hist.Lookup(hookType, h.Name); ok {
+			notes[h.Name] = dag.Annotation{
+				Duration: time.Duration(e.LastMs) * time.Millisecond,
+				Status:   e.Status,
+			}
+		}
+	}
+	return notes
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+var lockUpdate bool
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Pin tools, remote policies and includes in hooks.lock",
+	Long: `Resolve every tool download for each OS and architecture, every remote
+policy and every included config file to a SHA-256, and write them to
+hooks.lock next to the root config. Commit it with the config.
+
+When hooks.lock exists, 'run' fails if the config no longer matches it or
+a download or policy has different content. Without --update, 'lock'
+checks an existing hooks.lock and lists what drifted; with --update it
+rewrites it.`,
+	Args: cobra.NoArgs,
+	RunE: runLock,
+}
+
+func init() {
+	lockCmd.Flags().BoolVar(&lockUpdate, "update", false, "Rewrite hooks.lock with the current content")
+	rootCmd.AddCommand(lockCmd)
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+	// hooks-local is left out, as it is not committed, and the profiles
+	// are not applied: their policies are locked along with the config's.
+	cfg, _, err := config.LoadShared(workDir)
+	if err != nil {
+		return err
+	}
+	old, err := lock.Read(workDir)
+	if err != nil {
+		return err
+	}
+
+	l, err := lock.Generate(cfg, workDir, lock.NewResolver(filepath.Join(workDir, ".hooks", "cache")))
+	if err != nil {
+		return err
+	}
+	changes := lock.Diff(old, l)
+
+	out := cmd.OutOrStdout()
+	switch {
+	case old != nil && len(changes) == 0:
+		fmt.Fprintf(out, "%s is up to date\n", lock.FileName)
+		return nil
+	case old != nil && !lockUpdate:
+		for _, c := range changes {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+		return fmt.Errorf("%s is out of date (run 'hookrunner lock --update' to refresh it)", lock.FileName)
+	}
+
+	if err := l.Write(workDir); err != nil {
+		return err
+	}
+	if old == nil {
+		fmt.Fprintf(out, "Wrote %s: %d tools, %d policies, %d includes\n", lock.FileName, len(l.Tools), len(l.Policies), len(l.Includes))
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	fmt.Fprintf(out, "Updated %s\n", lock.FileName)
+	return nil
+}
+
+// verifyLock reads hooks.lock, if there is one, and checks that the
+// committed config still matches it. Tools and policies hooks-local adds
+// or changes are not pinned.
+func verifyLock(workDir string) (*lock.Lock, error) {
+	l, err := lock.Read(workDir)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	cfg, _, err := config.LoadShared(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if drift := l.Verify(cfg, workDir); len(drift) > 0 {
+		return nil, fmt.Errorf("%s is out of date:\n  %s\nrun 'hookrunner lock --update' to refresh it", lock.FileName, strings.Join(drift, "\n  "))
+	}
+	return l, nil
+}
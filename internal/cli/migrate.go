@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	migratepkg "github.com/ashavijit/hookrunner/internal/migrate"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom   string
+	migrateOutput string
+	migrateForce  bool
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate --from <tool>",
+	Short: "Convert a pre-commit, lefthook or husky config to hooks.yaml",
+	Long: `Read the hook config of another tool from the repository and write an
+equivalent hooks.yaml. The original config is left untouched.
+
+Sources:
+  pre-commit  .pre-commit-config.yaml
+  lefthook    lefthook.yml
+  husky       .husky/ or the husky.hooks key of package.json
+
+Anything that cannot be translated is printed as a warning and listed in a
+comment at the top of the generated file. Review it before installing.`,
+	Args: cobra.NoArgs,
+	RunE: runMigrate,
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Tool to migrate from ("+strings.Join(migratepkg.Sources, ", ")+")")
+	migrateCmd.Flags().StringVarP(&migrateOutput, "output", "o", "hooks.yaml", "File to write ('-' for stdout)")
+	migrateCmd.Flags().BoolVar(&migrateForce, "force", false, "Overwrite the output file if it exists")
+	_ = migrateCmd.MarkFlagRequired("from")
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	output := migrateOutput
+	if output != "-" {
+		var err error
+		if output, err = filepath.Abs(output); err != nil {
+			return err
+		}
+	}
+
+	workDir, err := repoDir()
+	if err != nil {
+		return err
+	}
+
+	result, err := migratepkg.Migrate(workDir, migrateFrom)
+	if err != nil {
+		return err
+	}
+	data, err := result.YAML()
+	if err != nil {
+		return err
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	for _, w := range result.Warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s %s\n", yellow("[WARN]"), w)
+	}
+
+	if output == "-" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+
+	if _, err := os.Stat(output); err == nil && !migrateForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", output)
+	}
+	if err := os.WriteFile(output, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Migrated %s to %s", result.Source, relToDir(workDir, output))
+	if n := len(result.Warnings); n > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), " (%d warnings)", n)
+	}
+	fmt.Fprintln(cmd.OutOrStdout())
+	fmt.Fprintln(cmd.OutOrStdout(), "Review it, then run 'hookrunner install' to replace the existing hooks")
+	return nil
+}
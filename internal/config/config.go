@@ -1,11 +1,18 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"sort"
 
+	"github.com/ashavijit/hookrunner/internal/git"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +24,7 @@ type Tool struct {
 
 type Hook struct {
 	Name        string            `yaml:"name" json:"name"`
+	Stages      StringList        `yaml:"stages" json:"stages"`
 	Tool        string            `yaml:"tool" json:"tool"`
 	Run         string            `yaml:"run" json:"run"`
 	Script      string            `yaml:"script" json:"script"`
@@ -28,7 +36,12 @@ type Hook struct {
 	Exclude     string            `yaml:"exclude" json:"exclude"`
 	Root        string            `yaml:"root" json:"root"`
 	Timeout     string            `yaml:"timeout" json:"timeout"`
-	After       string            `yaml:"after" json:"after"`
+	After       StringList        `yaml:"after" json:"after"`
+	Needs       StringList        `yaml:"needs" json:"needs"`
+	Priority    int               `yaml:"priority" json:"priority"`
+	Outputs     []string          `yaml:"outputs" json:"outputs"`
+	Extends     string            `yaml:"extends" json:"extends"`
+	Artifacts   []string          `yaml:"artifacts" json:"artifacts"`
 	Skip        string            `yaml:"skip" json:"skip"`
 	Only        string            `yaml:"only" json:"only"`
 	Tags        []string          `yaml:"tags" json:"tags"`
@@ -38,6 +51,71 @@ type Hook struct {
 	Interactive bool              `yaml:"interactive" json:"interactive"`
 	StageFixed  bool              `yaml:"stage_fixed" json:"stage_fixed"`
 	Piped       bool              `yaml:"piped" json:"piped"`
+	Disabled    bool              `yaml:"disabled" json:"disabled"`
+	Origin      Origin            `yaml:"-" json:"-"`
+	// Scope is the directory, relative to the repository root, of the
+	// nested config that defined the hook. File patterns and root are
+	// relative to it, and only files under it are matched.
+	Scope string `yaml:"-" json:"-"`
+
+	listModes map[string]listMode
+	// keys are the fields written in the config, so that overriding a
+	// hook also applies fields set to their zero value, like
+	// "disabled: false". It is nil for hooks built in code.
+	keys map[string]bool
+}
+
+// StringList is a list of strings that may also be written as a single
+// string, so "after: gofmt" and "after: [gofmt, govet]" both work.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		if value.Value == "" {
+			*l = nil
+			return nil
+		}
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*l = nil
+		} else {
+			*l = StringList{single}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Dependencies returns the hooks that must run before h: its after and
+// needs entries, without duplicates.
+func (h Hook) Dependencies() []string {
+	seen := make(map[string]bool, len(h.After)+len(h.Needs))
+	var deps []string
+	for _, name := range append(append([]string{}, h.Needs...), h.After...) {
+		if !seen[name] {
+			seen[name] = true
+			deps = append(deps, name)
+		}
+	}
+	return deps
 }
 
 type PolicyRef struct {
@@ -47,6 +125,42 @@ type PolicyRef struct {
 type ForbiddenContentPattern struct {
 	Pattern     string `yaml:"pattern" json:"pattern"`
 	Description string `yaml:"description" json:"description"`
+	Scope       string `yaml:"scope,omitempty" json:"scope,omitempty"`
+}
+
+// RegexBlockPattern is a secret pattern of regex_block, written as the
+// pattern alone or as {pattern, scope}.
+type RegexBlockPattern struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Scope   string `yaml:"scope,omitempty" json:"scope,omitempty"`
+}
+
+func (p *RegexBlockPattern) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = RegexBlockPattern{Pattern: value.Value}
+		return nil
+	}
+	type plain RegexBlockPattern
+	return value.Decode((*plain)(p))
+}
+
+func (p *RegexBlockPattern) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = RegexBlockPattern{Pattern: s}
+		return nil
+	}
+	type plain RegexBlockPattern
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// MarshalYAML writes a pattern without a scope as the pattern alone.
+func (p RegexBlockPattern) MarshalYAML() (interface{}, error) {
+	if p.Scope == "" {
+		return p.Pattern, nil
+	}
+	type plain RegexBlockPattern
+	return plain(p), nil
 }
 
 type CommitMessageRule struct {
@@ -59,9 +173,12 @@ type PolicyRules struct {
 	ForbidDirectories    []string                  `yaml:"forbid_directories" json:"forbid_directories"`
 	ForbidFileExtensions []string                  `yaml:"forbid_file_extensions" json:"forbid_file_extensions"`
 	RequiredFiles        []string                  `yaml:"required_files" json:"required_files"`
+	ForbidDelete         []string                  `yaml:"forbid_delete,omitempty" json:"forbid_delete,omitempty"`
+	ForbidRenameFrom     []string                  `yaml:"forbid_rename_from,omitempty" json:"forbid_rename_from,omitempty"`
 	MaxFileSizeKB        int                       `yaml:"max_file_size_kb" json:"max_file_size_kb"`
 	MaxFilesChanged      int                       `yaml:"max_files_changed" json:"max_files_changed"`
 	ForbidFileContent    []ForbiddenContentPattern `yaml:"forbid_file_content" json:"forbid_file_content"`
+	RegexBlock           []RegexBlockPattern       `yaml:"regex_block" json:"regex_block"`
 	ExcludeExtensions    []string                  `yaml:"exclude_extensions" json:"exclude_extensions"`
 	CommitMessage        *CommitMessageRule        `yaml:"commit_message" json:"commit_message"`
 	EnforceHooks         []string                  `yaml:"enforce_hooks" json:"enforce_hooks"`
@@ -84,56 +201,262 @@ type Policies struct {
 	LuaScripts    []string      `yaml:"lua_scripts" json:"lua_scripts"`
 }
 
+// CleanRoom configures --clean-room runs. Link lists untracked paths,
+// such as node_modules or .venv, that are symlinked from the real tree
+// instead of being rebuilt. Yes skips the confirmation prompt.
+type CleanRoom struct {
+	Link []string `yaml:"link" json:"link"`
+	Yes  bool     `yaml:"yes" json:"yes"`
+}
+
 type Config struct {
-	Tools       map[string]Tool   `yaml:"tools" json:"tools"`
-	Hooks       map[string][]Hook `yaml:"hooks" json:"hooks"`
-	Policies    *Policies         `yaml:"policies" json:"policies"`
-	ExcludeTags []string          `yaml:"exclude_tags" json:"exclude_tags"`
-	Parallel    bool              `yaml:"parallel" json:"parallel"`
-	ScriptsDir  string            `yaml:"scripts_dir" json:"scripts_dir"`
+	Version              int    `yaml:"version" json:"version"`
+	MinHookrunnerVersion string `yaml:"min_hookrunner_version" json:"min_hookrunner_version"`
+
+	Tools       map[string]Tool    `yaml:"tools" json:"tools"`
+	Hooks       map[string][]Hook  `yaml:"hooks" json:"hooks"`
+	Policies    *Policies          `yaml:"policies" json:"policies"`
+	ExcludeTags []string           `yaml:"exclude_tags" json:"exclude_tags"`
+	Parallel    bool               `yaml:"parallel" json:"parallel"`
+	ScriptsDir  string             `yaml:"scripts_dir" json:"scripts_dir"`
+	CleanRoom   *CleanRoom         `yaml:"clean_room" json:"clean_room"`
+	Include     []Include          `yaml:"include" json:"include"`
+	Nested      []string           `yaml:"nested" json:"nested"`
+	Templates   map[string]Hook    `yaml:"templates" json:"templates"`
+	Jobs        int                `yaml:"jobs" json:"jobs"`
+	Profiles    map[string]Profile `yaml:"profiles" json:"profiles"`
+	// Profile is the name of the profile applied with ApplyProfile, if any.
+	Profile string `yaml:"-" json:"-"`
+	// Sources lists the files the config was loaded from, in the order
+	// they were merged.
+	Sources []string `yaml:"-" json:"-"`
+	// Digests identifies the content of each included file, by source.
+	Digests map[string]Digest `yaml:"-" json:"-"`
+	// Origins records where each value was set, by OriginPath, after
+	// includes, templates, hooks-local and profiles were applied.
+	Origins map[string]Origin `yaml:"-" json:"-"`
+	// Upgrades lists what was migrated, by file, to read config files
+	// older than CurrentVersion. 'hookrunner config upgrade' writes it.
+	Upgrades []string `yaml:"-" json:"-"`
+
+	excludeTagsMode listMode
 }
 
+// Load reads the config for the repository containing dir: the config in
+// the repository root, merged with hooks-local, and the configs of the
+// directories listed in its nested setting, whose hooks are scoped to
+// their directory. Outside a repository only dir is searched. The returned
+// path is the root config.
 func Load(dir string) (*Config, string, error) {
-	candidates := []string{"hooks.yaml", "hooks.yml", "hooks.json"}
-	for _, name := range candidates {
-		path := filepath.Join(dir, name)
-		if _, err := os.Stat(path); err == nil {
-			cfg, err := loadFile(path)
-			if err != nil {
-				return nil, path, err
+	return loadDir(dir, true)
+}
+
+// LoadShared is Load without hooks-local: the config as committed, which
+// hooks.lock pins.
+func LoadShared(dir string) (*Config, string, error) {
+	return loadDir(dir, false)
+}
+
+func loadDir(dir string, local bool) (*Config, string, error) {
+	root, inRepo := findRepoRoot(dir)
+	rootPath := findConfig(root)
+	if rootPath == "" && !inRepo {
+		rootPath = findConfig(dir)
+		root = dir
+	}
+	if rootPath == "" {
+		return nil, "", fmt.Errorf("no config file found (hooks.yaml, hooks.yml, or hooks.json)")
+	}
+	abs, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, rootPath, err
+	}
+
+	nestedAt := func(d string) (configSource, bool) {
+		p := findConfig(filepath.Join(root, filepath.FromSlash(d)))
+		if p == "" {
+			return configSource{}, false
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return configSource{}, false
+		}
+		return configSource{path: abs}, true
+	}
+	localDir := ""
+	if local {
+		localDir = root
+	}
+	cfg, err := load(configSource{path: abs}, localDir, nestedAt)
+	return cfg, rootPath, err
+}
+
+// LoadAt reads the config committed at ref in the repository containing
+// dir, like Load does for the working tree. hooks-local is not read, as
+// it is not committed.
+func LoadAt(dir, ref string) (*Config, string, error) {
+	root, inRepo := findRepoRoot(dir)
+	if !inRepo {
+		return nil, "", fmt.Errorf("not inside a git repository")
+	}
+	if _, err := git.ResolveCommit(root, ref); err != nil {
+		return nil, "", err
+	}
+	files, err := git.FilesNamedAt(root, ref, configNames...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// One config per directory, in order of preference like findConfig.
+	chosen := make(map[string]string)
+	for _, name := range configNames {
+		for _, f := range files {
+			if dir := path.Dir(f); path.Base(f) == name && chosen[dir] == "" {
+				chosen[dir] = f
 			}
-			cfg = mergeLocalConfig(cfg, dir)
-			return cfg, path, nil
 		}
 	}
-	return nil, "", fmt.Errorf("no config file found (hooks.yaml, hooks.yml, or hooks.json)")
+	if chosen["."] == "" {
+		return nil, "", fmt.Errorf("no config file found at %s", ref)
+	}
+
+	at := func(p string) configSource {
+		return configSource{path: p, repoDir: root, ref: ref, gitRef: ref}
+	}
+	nestedAt := func(d string) (configSource, bool) {
+		f, ok := chosen[d]
+		return at(f), ok
+	}
+	rootSrc := at(chosen["."])
+	cfg, err := load(rootSrc, "", nestedAt)
+	return cfg, rootSrc.String(), err
 }
 
-func mergeLocalConfig(cfg *Config, dir string) *Config {
+// load reads the root config, merged with hooks-local from localDir if it
+// is set, and the nested configs it lists, which nestedAt finds by their
+// directory relative to the root.
+func load(rootSrc configSource, localDir string, nestedAt func(dir string) (configSource, bool)) (*Config, error) {
+	cfg, err := loadSource(rootSrc, nil)
+	if err != nil {
+		return nil, err
+	}
+	if localDir != "" {
+		if cfg, err = mergeLocalConfig(cfg, localDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.resolveExtends(); err != nil {
+		return nil, err
+	}
+
+	dirs, err := nestedDirs(cfg.Nested)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rootSrc, err)
+	}
+	for _, d := range dirs {
+		src, ok := nestedAt(d)
+		if !ok {
+			return nil, fmt.Errorf("%s: nested: no config file in %s", rootSrc, d)
+		}
+		sub, err := loadSource(src, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.mergeNested(d, sub); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.validateDependencies(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateScopes(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func mergeLocalConfig(cfg *Config, dir string) (*Config, error) {
 	localFiles := []string{"hooks-local.yaml", "hooks-local.yml", "hooks-local.json"}
 	for _, name := range localFiles {
 		path := filepath.Join(dir, name)
 		if _, err := os.Stat(path); err == nil {
-			local, err := loadFile(path)
-			if err == nil {
-				cfg = mergeConfigs(cfg, local)
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, err
 			}
+			local, err := loadSource(configSource{path: abs}, nil)
+			if err != nil {
+				return nil, err
+			}
+			// hooks-local is not committed, so neither is a lock of what it
+			// includes.
+			local.Digests = nil
+			cfg = overlayLocal(cfg, local)
 			break
 		}
 	}
-	return cfg
+	return cfg, nil
 }
 
 func mergeConfigs(base, override *Config) *Config {
-	if override.ExcludeTags != nil {
+	for name, t := range override.Tools {
+		if base.Tools == nil {
+			base.Tools = make(map[string]Tool)
+		}
+		base.Tools[name] = t
+	}
+	for name, t := range override.Templates {
+		if base.Templates == nil {
+			base.Templates = make(map[string]Hook)
+		}
+		base.Templates[name] = t
+	}
+	base.Policies = mergePolicies(base.Policies, override.Policies)
+	base.Sources = append(base.Sources, override.Sources...)
+	base.Upgrades = append(base.Upgrades, override.Upgrades...)
+	base.Nested = append(base.Nested, override.Nested...)
+	if override.Version != 0 {
+		base.Version = override.Version
+	}
+	if override.MinHookrunnerVersion != "" {
+		base.MinHookrunnerVersion = override.MinHookrunnerVersion
+	}
+	for src, d := range override.Digests {
+		if base.Digests == nil {
+			base.Digests = make(map[string]Digest)
+		}
+		base.Digests[src] = d
+	}
+	for p, o := range override.Origins {
+		base.setOrigins()
+		base.Origins[p] = o
+	}
+	if override.excludeTagsMode == listReplace {
+		base.ExcludeTags = override.ExcludeTags
+	} else if override.ExcludeTags != nil {
 		base.ExcludeTags = append(base.ExcludeTags, override.ExcludeTags...)
 	}
+	// The merged config stands for override when it is merged in turn.
+	base.excludeTagsMode = override.excludeTagsMode
 	if override.Parallel {
 		base.Parallel = true
 	}
+	if override.Jobs != 0 {
+		base.Jobs = override.Jobs
+	}
+	for name, p := range override.Profiles {
+		if base.Profiles == nil {
+			base.Profiles = make(map[string]Profile)
+		}
+		base.Profiles[name] = p
+	}
 	if override.ScriptsDir != "" {
 		base.ScriptsDir = override.ScriptsDir
 	}
+	if override.CleanRoom != nil {
+		base.CleanRoom = override.CleanRoom
+	}
 	for hookType, hooks := range override.Hooks {
 		if base.Hooks == nil {
 			base.Hooks = make(map[string][]Hook)
@@ -155,30 +478,252 @@ func mergeConfigs(base, override *Config) *Config {
 	return base
 }
 
+// mergePolicies adds the policies of override to base. Policy lists are
+// combined, with local policies of the same name replaced; a policy type
+// set in override wins.
+func mergePolicies(base, override *Policies) *Policies {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		p := *override
+		return &p
+	}
+	if override.Type != "" {
+		base.Type = override.Type
+	}
+	for _, ref := range override.Policies {
+		found := false
+		for _, b := range base.Policies {
+			found = found || b.URL == ref.URL
+		}
+		if !found {
+			base.Policies = append(base.Policies, ref)
+		}
+	}
+	for _, lp := range override.LocalPolicies {
+		found := false
+		for i, b := range base.LocalPolicies {
+			if b.Name == lp.Name {
+				base.LocalPolicies[i] = lp
+				found = true
+				break
+			}
+		}
+		if !found {
+			base.LocalPolicies = append(base.LocalPolicies, lp)
+		}
+	}
+	for _, script := range override.LuaScripts {
+		found := false
+		for _, b := range base.LuaScripts {
+			found = found || b == script
+		}
+		if !found {
+			base.LuaScripts = append(base.LuaScripts, script)
+		}
+	}
+	return base
+}
+
 func loadFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
+	return parseConfig(path, data)
+}
 
+// parseConfig decodes a config file, upgrading it to CurrentVersion and
+// expanding hooks lists by stage. path picks the format, by its extension,
+// and is used in error messages and hook origins.
+func parseConfig(path string, data []byte) (*Config, error) {
 	var cfg Config
+	var notes []string
+	var expanded bool
+	var err error
 	ext := filepath.Ext(path)
 	switch ext {
 	case ".json":
-		if err := json.Unmarshal(data, &cfg); err != nil {
+		// JSON is YAML, so the node tree gives positions for unknown
+		// keys; DisallowUnknownFields catches anything it could not parse.
+		var node yaml.Node
+		if yaml.Unmarshal(data, &node) == nil {
+			if notes, err = upgrade(path, rootMapping(&node)); err != nil {
+				return nil, err
+			}
+			if expanded, err = expandStages(path, rootMapping(&node)); err != nil {
+				return nil, fmt.Errorf("invalid JSON config: %w", err)
+			}
+			if err := checkFields(path, &node, reflect.TypeOf(cfg)); err != nil {
+				return nil, fmt.Errorf("invalid JSON config:\n%w", err)
+			}
+		}
+		if len(notes) > 0 || expanded {
+			if err := node.Decode(&cfg); err != nil {
+				return nil, fmt.Errorf("invalid JSON config: %w", err)
+			}
+		} else {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&cfg); err != nil {
+				return nil, fmt.Errorf("invalid JSON config: %w", err)
+			}
+		}
+		if err := annotateOrigins(&cfg, path, &node); err != nil {
 			return nil, fmt.Errorf("invalid JSON config: %w", err)
 		}
+		cfg.Origins = recordOrigins(path, &node)
 	case ".yaml", ".yml":
-		if err := yaml.Unmarshal(data, &cfg); err != nil {
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("invalid YAML config: %w", err)
+		}
+		if notes, err = upgrade(path, rootMapping(&node)); err != nil {
+			return nil, err
+		}
+		if expanded, err = expandStages(path, rootMapping(&node)); err != nil {
+			return nil, fmt.Errorf("invalid YAML config: %w", err)
+		}
+		if err := checkFields(path, &node, reflect.TypeOf(cfg)); err != nil {
+			return nil, fmt.Errorf("invalid YAML config:\n%w", err)
+		}
+		if len(notes) > 0 || expanded {
+			// The rewritten tree no longer matches data.
+			if err := node.Decode(&cfg); err != nil {
+				return nil, fmt.Errorf("invalid YAML config: %w", err)
+			}
+		} else {
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			dec.KnownFields(true)
+			if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("invalid YAML config: %w", err)
+			}
+		}
+		if err := annotateOrigins(&cfg, path, &node); err != nil {
+			return nil, fmt.Errorf("invalid YAML config: %w", err)
+		}
+		cfg.Origins = recordOrigins(path, &node)
+		if err := recordListModes(&cfg, path, &node); err != nil {
 			return nil, fmt.Errorf("invalid YAML config: %w", err)
 		}
 	default:
 		return nil, fmt.Errorf("unsupported config format: %s", ext)
 	}
 
+	for _, note := range notes {
+		cfg.Upgrades = append(cfg.Upgrades, path+": "+note)
+	}
 	return &cfg, nil
 }
 
+// validateScopes checks the scopes of the content rules of the local
+// policies, including those of profiles. Remote policies are checked when
+// they are fetched.
+func (c *Config) validateScopes() error {
+	if err := c.Policies.validateScopes(); err != nil {
+		return err
+	}
+	for _, name := range c.ProfileNames() {
+		if err := c.Profiles[name].Policies.validateScopes(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Policies) validateScopes() error {
+	if p == nil {
+		return nil
+	}
+	for _, lp := range p.LocalPolicies {
+		for i, pattern := range lp.Rules.ForbidFileContent {
+			if err := checkScope(pattern.Scope); err != nil {
+				return fmt.Errorf("local policy %q: forbid_file_content[%d]: %w", lp.Name, i, err)
+			}
+		}
+		for i, pattern := range lp.Rules.RegexBlock {
+			if err := checkScope(pattern.Scope); err != nil {
+				return fmt.Errorf("local policy %q: regex_block[%d]: %w", lp.Name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkScope reports a content rule scope other than file, the default,
+// or added_lines.
+func checkScope(scope string) error {
+	switch scope {
+	case "", "file", "added_lines":
+		return nil
+	}
+	return fmt.Errorf("unknown scope %q (use file or added_lines)", scope)
+}
+
+// validateDependencies checks that every after and needs entry names a
+// hook of the same hook type.
+func (c *Config) validateDependencies() error {
+	hookTypes := make([]string, 0, len(c.Hooks))
+	for hookType := range c.Hooks {
+		hookTypes = append(hookTypes, hookType)
+	}
+	sort.Strings(hookTypes)
+
+	var errs []error
+	for _, hookType := range hookTypes {
+		hooks := c.Hooks[hookType]
+		byName := make(map[string]Hook, len(hooks))
+		for _, h := range hooks {
+			byName[h.Name] = h
+		}
+		for _, h := range hooks {
+			for _, field := range []struct {
+				key  string
+				refs []string
+			}{{"after", h.After}, {"needs", h.Needs}} {
+				for _, ref := range field.refs {
+					if _, ok := byName[ref]; !ok {
+						errs = append(errs, fmt.Errorf("%s hook %q: %s references unknown hook %q", hookType, h.Name, field.key, ref))
+					}
+				}
+			}
+			errs = append(errs, validateNeedsRefs(hookType, h, byName)...)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid hook dependencies: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateNeedsRefs checks that {{ needs.X... }} references name a hook
+// that h depends on, and an output that hook declares.
+func validateNeedsRefs(hookType string, h Hook, byName map[string]Hook) []error {
+	var errs []error
+	deps := make(map[string]bool)
+	for _, d := range h.Dependencies() {
+		deps[d] = true
+	}
+	for _, ref := range h.NeedsRefs() {
+		if !deps[ref.Hook] {
+			errs = append(errs, fmt.Errorf("%s hook %q: {{ needs.%s }} refers to a hook not listed in needs or after", hookType, h.Name, ref.Hook))
+			continue
+		}
+		if ref.Artifacts {
+			continue
+		}
+		declared := false
+		for _, o := range byName[ref.Hook].Outputs {
+			declared = declared || o == ref.Output
+		}
+		if !declared {
+			errs = append(errs, fmt.Errorf("%s hook %q: hook %q does not declare output %q", hookType, h.Name, ref.Hook, ref.Output))
+		}
+	}
+	return errs
+}
+
 func (c *Config) GetHooks(hookType string) []Hook {
 	if c.Hooks == nil {
 		return nil
@@ -215,7 +760,9 @@ func (c *Config) GetPolicyURLs() []string {
 }
 
 func DefaultConfig() string {
-	return `tools:
+	return `version: 2
+
+tools:
   golangci-lint:
     version: 1.55.2
     install:
@@ -228,10 +775,11 @@ policies:
   policies:
     - url: https://policies.example.dev/default.yaml
   localPolicies:
-    commit-style:
-      commit_message:
-        regex: "^(feat|fix|chore|docs|refactor|test):"
-        min_length: 10
+    - name: commit-style
+      rules:
+        commit_message:
+          regex: "^(feat|fix|chore|docs|refactor|test): .{3,}"
+          error: "start the message with a type, e.g. 'feat: add login'"
 
 hooks:
   pre-commit:
@@ -244,7 +792,7 @@ hooks:
       tool: go
       args: ["vet", "./..."]
       files: "\\.go$"
-      after: gofmt
+      needs: [gofmt]
 
   pre-push:
     - name: test
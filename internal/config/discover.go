@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configNames are the names of config files, in order of preference.
+var configNames = []string{"hooks.yaml", "hooks.yml", "hooks.json"}
+
+// findRepoRoot returns the closest directory at or above dir holding a
+// .git entry, or dir itself outside a repository.
+func findRepoRoot(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, false
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d, true
+		}
+		if filepath.Dir(d) == d {
+			return dir, false
+		}
+	}
+}
+
+// findConfig returns the config file in dir, if any.
+func findConfig(dir string) string {
+	for _, name := range configNames {
+		p := filepath.Join(dir, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// nestedDirs checks the directories of the nested setting, relative to
+// the repository root, and returns them cleaned and without duplicates.
+func nestedDirs(dirs []string) ([]string, error) {
+	seen := make(map[string]bool, len(dirs))
+	out := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		clean := path.Clean(filepath.ToSlash(d))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+			return nil, fmt.Errorf("nested: %q is not a subdirectory of the repository", d)
+		}
+		if !seen[clean] {
+			seen[clean] = true
+			out = append(out, clean)
+		}
+	}
+	return out, nil
+}
+
+// ScopedName is the name a hook from the nested config in dir gets in the
+// merged plan.
+func ScopedName(dir, name string) string {
+	return dir + ":" + name
+}
+
+// mergeNested adds the hooks of the nested config in dir, scoped to that
+// directory. Its templates extend the root config's, and tools the root
+// does not define are added. Settings that apply to the whole repository
+// may only be set in the root config.
+func (c *Config) mergeNested(dir string, nested *Config) error {
+	for _, setting := range []struct {
+		key string
+		set bool
+	}{
+		{"nested", nested.Nested != nil},
+		{"policies", nested.Policies != nil},
+		{"exclude_tags", nested.ExcludeTags != nil},
+		{"parallel", nested.Parallel},
+		{"jobs", nested.Jobs != 0},
+		{"profiles", nested.Profiles != nil},
+		{"scripts_dir", nested.ScriptsDir != ""},
+		{"clean_room", nested.CleanRoom != nil},
+	} {
+		if setting.set {
+			return fmt.Errorf("%s: %s can only be set in the root config", nested.Sources[len(nested.Sources)-1], setting.key)
+		}
+	}
+
+	templates := make(map[string]Hook, len(c.Templates)+len(nested.Templates))
+	for name, t := range c.Templates {
+		templates[name] = t
+	}
+	for name, t := range nested.Templates {
+		templates[name] = t
+	}
+	nested.Templates = templates
+	nested.setOrigins()
+	copyOrigins(nested.Origins, c.Origins, "templates", "templates", false)
+	if err := nested.resolveExtends(); err != nil {
+		return err
+	}
+
+	for name, t := range nested.Tools {
+		if _, ok := c.Tools[name]; !ok {
+			if c.Tools == nil {
+				c.Tools = make(map[string]Tool)
+			}
+			c.Tools[name] = t
+			c.setOrigins()
+			copyOrigins(c.Origins, nested.Origins, OriginPath("tools", name), OriginPath("tools", name), true)
+		}
+	}
+
+	hookTypes := make([]string, 0, len(nested.Hooks))
+	for hookType := range nested.Hooks {
+		hookTypes = append(hookTypes, hookType)
+	}
+	sort.Strings(hookTypes)
+
+	for _, hookType := range hookTypes {
+		hooks := nested.Hooks[hookType]
+		local := make(map[string]bool, len(hooks))
+		for _, h := range hooks {
+			local[h.Name] = true
+		}
+		rename := func(name string) string {
+			if local[name] {
+				return ScopedName(dir, name)
+			}
+			return name
+		}
+
+		if c.Hooks == nil {
+			c.Hooks = make(map[string][]Hook)
+		}
+		for _, h := range hooks {
+			c.Hooks[hookType] = append(c.Hooks[hookType], h.scoped(dir, rename))
+			c.setOrigins()
+			copyOrigins(c.Origins, nested.Origins, OriginPath("hooks", hookType, h.Name), OriginPath("hooks", hookType, rename(h.Name)), true)
+		}
+	}
+
+	c.Sources = append(c.Sources, nested.Sources...)
+	c.Upgrades = append(c.Upgrades, nested.Upgrades...)
+	for src, d := range nested.Digests {
+		if c.Digests == nil {
+			c.Digests = make(map[string]Digest)
+		}
+		c.Digests[src] = d
+	}
+	return nil
+}
+
+// scoped returns h limited to files under dir, with its name and the
+// hooks it refers to renamed.
+func (h Hook) scoped(dir string, rename func(string) string) Hook {
+	h.Name = rename(h.Name)
+	h.Scope = dir
+	h.After = renameAll(h.After, rename)
+	h.Needs = renameAll(h.Needs, rename)
+
+	h.Run = renameNeeds(h.Run, rename)
+	h.Args = renameNeedsAll(h.Args, rename)
+	h.FixArgs = renameNeedsAll(h.FixArgs, rename)
+	if len(h.Env) > 0 {
+		env := make(map[string]string, len(h.Env))
+		for k, v := range h.Env {
+			env[k] = renameNeeds(v, rename)
+		}
+		h.Env = env
+	}
+	return h
+}
+
+func renameAll(names StringList, rename func(string) string) StringList {
+	if len(names) == 0 {
+		return names
+	}
+	out := make(StringList, len(names))
+	for i, n := range names {
+		out[i] = rename(n)
+	}
+	return out
+}
+
+func renameNeedsAll(values []string, rename func(string) string) []string {
+	if len(values) == 0 {
+		return values
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = renameNeeds(v, rename)
+	}
+	return out
+}
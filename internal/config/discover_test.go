@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo makes dir a git repository, so configs are found from its root.
+func initRepo(t *testing.T, dir string) {
+	t.Helper()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+}
+
+func TestLoad_NestedConfigs(t *testing.T) {
+	root := t.TempDir()
+	initRepo(t, root)
+	if err := os.MkdirAll(filepath.Join(root, "services", "payments", "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, "hooks.yaml", `nested: [services/payments]
+templates:
+  go-lint:
+    run: golangci-lint run
+hooks:
+  pre-commit:
+    - name: fmt
+      run: gofmt -l .
+`)
+	writeConfig(t, root, "services/payments/hooks.yaml", `hooks:
+  pre-commit:
+    - name: gen
+      run: make gen
+      outputs: [version]
+    - name: lint
+      extends: go-lint
+      files: \.go$
+      needs: [gen]
+      after: [fmt]
+      env:
+        VERSION: "{{ needs.gen.outputs.version }}"
+`)
+
+	cfg, path, err := Load(filepath.Join(root, "services", "payments", "api"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if path != filepath.Join(root, "hooks.yaml") {
+		t.Errorf("expected the root config path, got %s", path)
+	}
+
+	hooks := cfg.GetHooks("pre-commit")
+	if len(hooks) != 3 {
+		t.Fatalf("expected root and nested hooks in one plan, got %+v", hooks)
+	}
+	if hooks[0].Name != "fmt" || hooks[0].Scope != "" {
+		t.Errorf("expected unscoped root hook first, got %+v", hooks[0])
+	}
+
+	lint := hooks[2]
+	if lint.Name != "services/payments:lint" || lint.Scope != "services/payments" {
+		t.Errorf("expected scoped name and scope, got %q %q", lint.Name, lint.Scope)
+	}
+	if lint.Run != "golangci-lint run" {
+		t.Errorf("expected root template to apply, got %q", lint.Run)
+	}
+	if lint.Needs[0] != "services/payments:gen" || lint.After[0] != "fmt" {
+		t.Errorf("expected local references renamed and root references kept, got needs %v after %v", lint.Needs, lint.After)
+	}
+	if lint.Env["VERSION"] != "{{ needs.services/payments:gen.outputs.version }}" {
+		t.Errorf("expected needs reference renamed, got %q", lint.Env["VERSION"])
+	}
+	if len(cfg.Sources) != 2 || !strings.HasSuffix(cfg.Sources[1], filepath.Join("payments", "hooks.yaml")) {
+		t.Errorf("unexpected sources %v", cfg.Sources)
+	}
+}
+
+func TestLoad_NestedOptIn(t *testing.T) {
+	root := t.TempDir()
+	initRepo(t, root)
+	for _, d := range []string{"web", "e2e-test"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(t, root, "hooks.yaml", "nested: [./web/, web]\n")
+	writeConfig(t, root, "web/hooks.yaml", "hooks:\n  pre-commit:\n    - name: lint\n      run: eslint .\n")
+	writeConfig(t, root, "e2e-test/hooks.yaml", "policies:\n  policies: []\nhooks:\n  pre-commit:\n    - name: e2e\n      run: make e2e\n")
+
+	cfg, _, err := Load(root)
+	if err != nil {
+		t.Fatalf("expected unlisted configs to be ignored, got %v", err)
+	}
+	if hooks := cfg.GetHooks("pre-commit"); len(hooks) != 1 || hooks[0].Name != "web:lint" {
+		t.Errorf("unexpected hooks %+v", hooks)
+	}
+
+	writeConfig(t, root, "hooks.yaml", "nested: [api]\n")
+	if _, _, err := Load(root); err == nil || !strings.Contains(err.Error(), "no config file in api") {
+		t.Errorf("expected error for nested directory without a config, got %v", err)
+	}
+	writeConfig(t, root, "hooks.yaml", "nested: [../other]\n")
+	if _, _, err := Load(root); err == nil || !strings.Contains(err.Error(), "not a subdirectory") {
+		t.Errorf("expected error for nested directory outside the repository, got %v", err)
+	}
+}
+
+func TestLoad_NestedRequiresRoot(t *testing.T) {
+	root := t.TempDir()
+	initRepo(t, root)
+	if err := os.Mkdir(filepath.Join(root, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, "web/hooks.yaml", "hooks:\n  pre-commit:\n    - name: lint\n      run: eslint .\n")
+
+	if _, _, err := Load(filepath.Join(root, "web")); err == nil {
+		t.Fatal("expected error without a root config")
+	}
+}
+
+func TestLoad_NestedRepositorySettings(t *testing.T) {
+	root := t.TempDir()
+	initRepo(t, root)
+	if err := os.Mkdir(filepath.Join(root, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, "hooks.yaml", "nested: [web]\nhooks: {}\n")
+	writeConfig(t, root, "web/hooks.yaml", "exclude_tags: [slow]\n")
+
+	_, _, err := Load(root)
+	if err == nil || !strings.Contains(err.Error(), "exclude_tags can only be set in the root config") {
+		t.Fatalf("expected error for repository setting in nested config, got %v", err)
+	}
+}
+
+func TestLoad_NestedProfiles(t *testing.T) {
+	root := t.TempDir()
+	initRepo(t, root)
+	if err := os.Mkdir(filepath.Join(root, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, root, "hooks.yaml", "nested: [web]\nhooks: {}\n")
+	writeConfig(t, root, "web/hooks.yaml", "profiles:\n  ci:\n    jobs: 2\n")
+
+	_, _, err := Load(root)
+	if err == nil || !strings.Contains(err.Error(), "profiles can only be set in the root config") {
+		t.Fatalf("expected error for profiles in nested config, got %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+// Include is an entry of include:. Path is a file or glob, relative to
+// the including file. With Repo set, Path is read at Ref from that
+// repository, either a local checkout or a remote URL.
+type Include struct {
+	Path string `yaml:"path" json:"path"`
+	Repo string `yaml:"repo" json:"repo"`
+	Ref  string `yaml:"ref" json:"ref"`
+}
+
+func (inc *Include) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*inc = Include{Path: value.Value}
+		return nil
+	}
+	type plain Include
+	return value.Decode((*plain)(inc))
+}
+
+func (inc *Include) UnmarshalJSON(data []byte) error {
+	var p string
+	if err := json.Unmarshal(data, &p); err == nil {
+		*inc = Include{Path: p}
+		return nil
+	}
+	type plain Include
+	return json.Unmarshal(data, (*plain)(inc))
+}
+
+// Origin records where a hook or template was defined.
+type Origin struct {
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	if o.Line == 0 {
+		return o.File
+	}
+	return fmt.Sprintf("%s:%d", o.File, o.Line)
+}
+
+// Digest identifies the content of an included config file: its SHA-256
+// and, for files read from git, the commit they were read at.
+type Digest struct {
+	SHA256 string
+	Commit string
+}
+
+// configSource is a config file, either in the working tree or at a ref
+// of a git repository.
+type configSource struct {
+	// path is absolute for working tree files and slash-separated,
+	// relative to the repository root, otherwise.
+	path string
+	// repoDir and ref are set for files read from git. repo is what the
+	// config said, for display; gitRef is the ref read from, which for
+	// remote repositories is the pinned copy in the include cache.
+	repoDir string
+	repo    string
+	ref     string
+	gitRef  string
+}
+
+func (s configSource) String() string {
+	if s.repoDir == "" {
+		return s.path
+	}
+	if s.repo == "" {
+		return s.ref + ":" + s.path
+	}
+	ref := s.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return fmt.Sprintf("%s@%s:%s", s.repo, ref, s.path)
+}
+
+func (s configSource) read() ([]byte, error) {
+	if s.repoDir == "" {
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		return data, nil
+	}
+	return git.ShowFile(s.repoDir, s.gitRef, s.path)
+}
+
+// includeCacheDir is where remote includes are fetched to.
+func includeCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "hookrunner", "includes")
+	}
+	return filepath.Join(os.TempDir(), "hookrunner-includes")
+}
+
+// resolve returns the files an include entry of s refers to.
+func (s configSource) resolve(inc Include) ([]configSource, error) {
+	if inc.Path == "" {
+		return nil, fmt.Errorf("%s: include needs a path", s)
+	}
+
+	if inc.Repo != "" {
+		src := configSource{path: path.Clean(inc.Path), repo: inc.Repo, ref: inc.Ref, gitRef: inc.Ref}
+		if info, err := os.Stat(s.localPath(inc.Repo)); err == nil && info.IsDir() {
+			src.repoDir = s.localPath(inc.Repo)
+			if _, err := git.ResolveCommit(src.repoDir, inc.Ref); err != nil {
+				return nil, fmt.Errorf("%s: %w", s, err)
+			}
+			return []configSource{src}, nil
+		}
+		dir, pinned, err := git.FetchPinned(inc.Repo, inc.Ref, includeCacheDir())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+		src.repoDir = dir
+		src.gitRef = pinned
+		return []configSource{src}, nil
+	}
+
+	if s.repoDir != "" {
+		// Includes inside a file read from git stay at the same ref.
+		src := s
+		src.path = path.Join(path.Dir(s.path), inc.Path)
+		return []configSource{src}, nil
+	}
+
+	pattern := s.localPath(inc.Path)
+	if !strings.ContainsAny(inc.Path, "*?[") {
+		if _, err := os.Stat(pattern); err != nil {
+			return nil, fmt.Errorf("%s: include %q not found", s, inc.Path)
+		}
+		return []configSource{{path: pattern}}, nil
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid include pattern %q: %w", s, inc.Path, err)
+	}
+	sort.Strings(matches)
+	sources := make([]configSource, 0, len(matches))
+	for _, m := range matches {
+		sources = append(sources, configSource{path: m})
+	}
+	return sources, nil
+}
+
+// localPath resolves p relative to the directory of a working tree file,
+// or of a file committed in the repository itself.
+func (s configSource) localPath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	if s.repoDir != "" && s.repo == "" {
+		return filepath.Join(s.repoDir, filepath.FromSlash(path.Dir(s.path)), p)
+	}
+	return filepath.Join(filepath.Dir(s.path), p)
+}
+
+// loadSource reads src and everything it includes. Included files are
+// merged in order, and src itself is merged last so it overrides them.
+// stack holds the files being loaded, to detect include cycles.
+func loadSource(src configSource, stack []string) (*Config, error) {
+	for i, s := range stack {
+		if s == src.String() {
+			chain := append(append([]string{}, stack[i:]...), s)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	included := len(stack) > 0
+	stack = append(stack, src.String())
+
+	data, err := src.read()
+	if err != nil {
+		return nil, err
+	}
+	var digest Digest
+	if included {
+		sum := sha256.Sum256(data)
+		digest.SHA256 = hex.EncodeToString(sum[:])
+		if src.repoDir != "" {
+			if digest.Commit, err = git.ResolveCommit(src.repoDir, src.gitRef); err != nil {
+				return nil, err
+			}
+		}
+	}
+	cfg, err := parseConfig(src.String(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Config{}
+	for _, inc := range cfg.Include {
+		sources, err := src.resolve(inc)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range sources {
+			included, err := loadSource(sub, stack)
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeConfigs(merged, included)
+		}
+	}
+
+	cfg.Include = nil
+	merged = mergeConfigs(merged, cfg)
+	merged.Sources = append(merged.Sources, src.String())
+	if included {
+		if merged.Digests == nil {
+			merged.Digests = make(map[string]Digest)
+		}
+		merged.Digests[src.String()] = digest
+	}
+	return merged, nil
+}
+
+// resolveExtends applies templates to the hooks that extend them. Fields
+// set on the hook override the template's; templates may extend other
+// templates.
+func (c *Config) resolveExtends() error {
+	c.setOrigins()
+	resolved := make(map[string]Hook)
+
+	var resolve func(name string, chain []string) (Hook, error)
+	resolve = func(name string, chain []string) (Hook, error) {
+		if h, ok := resolved[name]; ok {
+			return h, nil
+		}
+		for i, n := range chain {
+			if n == name {
+				return Hook{}, fmt.Errorf("template cycle: %s", strings.Join(append(chain[i:], name), " -> "))
+			}
+		}
+		t, ok := c.Templates[name]
+		if !ok {
+			return Hook{}, fmt.Errorf("unknown template %q", name)
+		}
+		if t.Extends != "" {
+			base, err := resolve(t.Extends, append(chain, name))
+			if err != nil {
+				return Hook{}, err
+			}
+			copyOrigins(c.Origins, c.Origins, OriginPath("templates", t.Extends), OriginPath("templates", name), false)
+			t = overrideFields(base, t)
+		}
+		resolved[name] = t
+		return t, nil
+	}
+
+	for hookType, hooks := range c.Hooks {
+		for i, h := range hooks {
+			if h.Extends == "" {
+				continue
+			}
+			base, err := resolve(h.Extends, nil)
+			if err != nil {
+				return fmt.Errorf("%s hook %q (%s): %w", hookType, h.Name, h.Origin, err)
+			}
+			hooks[i] = overrideFields(base, h)
+			copyOrigins(c.Origins, c.Origins, OriginPath("templates", h.Extends), OriginPath("hooks", hookType, h.Name), false)
+		}
+	}
+	return nil
+}
+
+// annotateOrigins records the file and line each hook and template was
+// defined at, from the parsed YAML document.
+func annotateOrigins(cfg *Config, file string, doc *yaml.Node) error {
+	err := walkHookNodes(doc, func(section, name string, i int, key, item *yaml.Node) error {
+		switch section {
+		case "hooks":
+			if hooks := cfg.Hooks[name]; i < len(hooks) {
+				hooks[i].Origin = Origin{File: file, Line: item.Line}
+			}
+		case "templates":
+			if t, ok := cfg.Templates[name]; ok {
+				t.Origin = Origin{File: file, Line: key.Line}
+				cfg.Templates[name] = t
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return annotateProfileOrigins(cfg, file, doc)
+}
+
+// walkHookNodes calls fn for the node of every hook and template in doc.
+// For hooks, name is the hook type and i the index in its list; key is
+// the node of the template name or hook type.
+func walkHookNodes(doc *yaml.Node, fn func(section, name string, i int, key, item *yaml.Node) error) error {
+	root := rootMapping(doc)
+	if root == nil {
+		return nil
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section, value := root.Content[i].Value, root.Content[i+1]
+		if section != "hooks" && section != "templates" {
+			continue
+		}
+		if err := walkSection(section, value, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkSection calls fn for each hook in value, a hooks map by hook type,
+// or each template in value, a templates map.
+func walkSection(section string, value *yaml.Node, fn func(section, name string, i int, key, item *yaml.Node) error) error {
+	if value.Kind != yaml.MappingNode {
+		return nil
+	}
+	for j := 0; j+1 < len(value.Content); j += 2 {
+		key, item := value.Content[j], value.Content[j+1]
+		if section == "templates" {
+			if err := fn(section, key.Value, 0, key, item); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Kind != yaml.SequenceNode {
+			continue
+		}
+		for k, n := range item.Content {
+			if err := fn(section, key.Value, k, key, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rootMapping returns the top-level mapping of a parsed document.
+func rootMapping(doc *yaml.Node) *yaml.Node {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	return root
+}
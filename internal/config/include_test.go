@@ -0,0 +1,264 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_IncludePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, "shared/go.yaml", `tools:
+  golangci-lint:
+    version: 1.55.0
+hooks:
+  pre-commit:
+    - name: lint
+      run: echo shared
+    - name: vet
+      run: go vet ./...
+`)
+	writeConfig(t, dir, "shared/docs.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      run: echo docs
+`)
+	writeConfig(t, dir, "hooks.yaml", `include:
+  - shared/go.yaml
+  - path: shared/d*.yaml
+hooks:
+  pre-commit:
+    - name: vet
+      run: go vet -race ./...
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	hooks := cfg.GetHooks("pre-commit")
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 hooks, got %+v", hooks)
+	}
+	if hooks[0].Run != "echo docs" {
+		t.Errorf("expected later include to override earlier, got %q", hooks[0].Run)
+	}
+	if hooks[1].Run != "go vet -race ./..." {
+		t.Errorf("expected including file to override includes, got %q", hooks[1].Run)
+	}
+	if cfg.Tools["golangci-lint"].Version != "1.55.0" {
+		t.Errorf("expected tool from include, got %+v", cfg.Tools)
+	}
+
+	want := []string{
+		filepath.Join(dir, "shared", "go.yaml"),
+		filepath.Join(dir, "shared", "docs.yaml"),
+		filepath.Join(dir, "hooks.yaml"),
+	}
+	if strings.Join(cfg.Sources, ",") != strings.Join(want, ",") {
+		t.Errorf("expected sources %v, got %v", want, cfg.Sources)
+	}
+	if o := hooks[0].Origin; o.File != want[1] || o.Line != 3 {
+		t.Errorf("unexpected origin for lint: %v", o)
+	}
+}
+
+func TestLoad_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "missing",
+			files: map[string]string{
+				"hooks.yaml": "include: [nope.yaml]\n",
+			},
+			want: `include "nope.yaml" not found`,
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"hooks.yaml": "include: [a.yaml]\n",
+				"a.yaml":     "include: [b.yaml]\n",
+				"b.yaml":     "include: [a.yaml]\n",
+			},
+			want: "include cycle: ",
+		},
+		{
+			name: "unknown field",
+			files: map[string]string{
+				"hooks.yaml": "include: [a.yaml]\n",
+				"a.yaml":     "hooks:\n  pre-commit:\n    - name: x\n      rn: echo\n",
+			},
+			want: `a.yaml:4:7: unknown field "rn"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeConfig(t, dir, name, content)
+			}
+			_, _, err := Load(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad_Extends(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `templates:
+  go-base:
+    files: \.go$
+    timeout: 2m
+  base-go-lint:
+    extends: go-base
+    tool: golangci-lint
+    args: [run]
+hooks:
+  pre-commit:
+    - name: lint
+      extends: base-go-lint
+      args: [run, --fast]
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	h := cfg.GetHooks("pre-commit")[0]
+	if h.Tool != "golangci-lint" || h.Files != `\.go$` || h.Timeout != "2m" {
+		t.Errorf("expected template fields to be inherited, got %+v", h)
+	}
+	if strings.Join(h.Args, " ") != "run --fast" {
+		t.Errorf("expected hook args to override template, got %v", h.Args)
+	}
+	if h.Name != "lint" || h.Origin.Line != 11 {
+		t.Errorf("expected hook's own name and origin, got %q %v", h.Name, h.Origin)
+	}
+}
+
+func TestLoad_ExtendsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `templates:
+  a:
+    extends: b
+  b:
+    extends: a
+hooks:
+  pre-commit:
+    - name: lint
+      extends: a
+`)
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "template cycle: a -> b -> a") {
+		t.Fatalf("expected template cycle error, got %v", err)
+	}
+
+	writeConfig(t, dir, "hooks.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      extends: missing
+`)
+	_, _, err = Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `unknown template "missing"`) {
+		t.Fatalf("expected unknown template error, got %v", err)
+	}
+}
+
+func TestLoad_IncludeFromGitRef(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	base := t.TempDir()
+	shared := filepath.Join(base, "shared")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = shared
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.Mkdir(shared, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	if err := os.Mkdir(filepath.Join(shared, "hooks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, shared, "hooks/go.yaml", "include: [common.yaml]\ntemplates:\n  lint:\n    run: echo v1\n")
+	writeConfig(t, shared, "hooks/common.yaml", "exclude_tags: [slow]\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	writeConfig(t, shared, "hooks/go.yaml", "templates:\n  lint:\n    run: echo v2\n")
+	git("commit", "-q", "-am", "v2")
+
+	dir := filepath.Join(base, "app")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, "hooks.yaml", `include:
+  - repo: ../shared
+    ref: v1
+    path: hooks/go.yaml
+hooks:
+  pre-commit:
+    - name: lint
+      extends: lint
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	h := cfg.GetHooks("pre-commit")[0]
+	if h.Run != "echo v1" {
+		t.Errorf("expected template at v1, got %q", h.Run)
+	}
+	if len(cfg.ExcludeTags) != 1 {
+		t.Errorf("expected nested include at the same ref, got %v", cfg.ExcludeTags)
+	}
+	if o := cfg.Templates["lint"].Origin; o.String() != "../shared@v1:hooks/go.yaml:3" {
+		t.Errorf("unexpected template origin %q", o)
+	}
+	d := cfg.Digests["../shared@v1:hooks/common.yaml"]
+	if len(d.SHA256) != 64 || len(d.Commit) != 40 || cfg.Digests["../shared@v1:hooks/go.yaml"].Commit != d.Commit {
+		t.Errorf("unexpected digests %+v", cfg.Digests)
+	}
+}
+
+func TestLoad_IncludeDigests(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", "include: [shared.yaml]\n")
+	writeConfig(t, dir, "shared.yaml", "exclude_tags: [slow]\n")
+	writeConfig(t, dir, "hooks-local.yaml", "include: [mine.yaml]\n")
+	writeConfig(t, dir, "mine.yaml", "exclude_tags: [lint]\n")
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Digests) != 1 {
+		t.Fatalf("expected only the committed include to have a digest, got %+v", cfg.Digests)
+	}
+	sum := sha256.Sum256([]byte("exclude_tags: [slow]\n"))
+	if d := cfg.Digests[filepath.Join(dir, "shared.yaml")]; d.SHA256 != hex.EncodeToString(sum[:]) || d.Commit != "" {
+		t.Errorf("unexpected digest %+v", d)
+	}
+}
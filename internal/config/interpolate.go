@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Vars holds the values of the built-in variables available to ${...}
+// references in config values.
+type Vars struct {
+	RepoRoot   string
+	HookType   string
+	ScriptsDir string
+	CacheDir   string
+	// OS and Arch default to the running platform. They are set to
+	// expand tool URLs for other platforms.
+	OS   string
+	Arch string
+}
+
+// Lookup returns the value of a built-in variable, or of an environment
+// variable for any other name.
+func (v Vars) Lookup(name string) (string, bool) {
+	switch name {
+	case "repo_root":
+		return v.RepoRoot, true
+	case "hook_type":
+		return v.HookType, true
+	case "os":
+		if v.OS != "" {
+			return v.OS, true
+		}
+		return runtime.GOOS, true
+	case "arch":
+		if v.Arch != "" {
+			return v.Arch, true
+		}
+		return runtime.GOARCH, true
+	case "scripts_dir":
+		return v.ScriptsDir, true
+	case "cache_dir":
+		return v.CacheDir, true
+	}
+	return os.LookupEnv(name)
+}
+
+// Interpolate expands ${VAR} and ${VAR:-default} in s. "$${" stands for a
+// literal "${". Other uses of "$", such as $VAR, $(cmd) or ${VAR%.go}, are
+// left for the shell. Referring to a variable that is not defined and has
+// no default is an error.
+func Interpolate(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s[i:])
+			return b.String(), nil
+		}
+		ref := s[i+2 : i+end]
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if !validVarName(name) {
+			// A shell expansion such as ${#arr} or ${@}.
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		s = s[i+end+1:]
+
+		value, ok := lookup(name)
+		switch {
+		case ok && (value != "" || !hasDefault):
+			b.WriteString(value)
+		case hasDefault:
+			b.WriteString(def)
+		default:
+			return "", fmt.Errorf("undefined variable ${%s} (use ${%s:-} to allow it to be empty)", name, name)
+		}
+	}
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Interpolate returns the hook with variables expanded in env, then in
+// run, args, fix_args and root. Those may also refer to the hook's own
+// env.
+func (h Hook) Interpolate(vars Vars) (Hook, error) {
+	keys := make([]string, 0, len(h.Env))
+	for k := range h.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	env := make(map[string]string, len(h.Env))
+	for _, k := range keys {
+		v, err := Interpolate(h.Env[k], vars.Lookup)
+		if err != nil {
+			return h, fmt.Errorf("env.%s: %w", k, err)
+		}
+		env[k] = v
+	}
+	if h.Env != nil {
+		h.Env = env
+	}
+
+	lookup := func(name string) (string, bool) {
+		if v, ok := env[name]; ok {
+			return v, true
+		}
+		return vars.Lookup(name)
+	}
+
+	var err error
+	if h.Run, err = Interpolate(h.Run, lookup); err != nil {
+		return h, fmt.Errorf("run: %w", err)
+	}
+	if h.Root, err = Interpolate(h.Root, lookup); err != nil {
+		return h, fmt.Errorf("root: %w", err)
+	}
+	if h.Args, err = interpolateAll(h.Args, lookup); err != nil {
+		return h, fmt.Errorf("args: %w", err)
+	}
+	if h.FixArgs, err = interpolateAll(h.FixArgs, lookup); err != nil {
+		return h, fmt.Errorf("fix_args: %w", err)
+	}
+	return h, nil
+}
+
+func interpolateAll(values []string, lookup func(string) (string, bool)) ([]string, error) {
+	if len(values) == 0 {
+		return values, nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		s, err := Interpolate(v, lookup)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// Interpolate returns the tool with variables expanded in its install
+// URLs, where ${version} is also available.
+func (t Tool) Interpolate(vars Vars) (Tool, error) {
+	if len(t.Install) == 0 {
+		return t, nil
+	}
+	lookup := func(name string) (string, bool) {
+		if name == "version" {
+			return t.Version, true
+		}
+		return vars.Lookup(name)
+	}
+	install := make(map[string]string, len(t.Install))
+	for goos, url := range t.Install {
+		u, err := Interpolate(url, lookup)
+		if err != nil {
+			return t, fmt.Errorf("install.%s: %w", goos, err)
+		}
+		install[goos] = u
+	}
+	t.Install = install
+	return t, nil
+}
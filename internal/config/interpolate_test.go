@@ -0,0 +1,114 @@
+package config
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		switch name {
+		case "NAME":
+			return "world", true
+		case "EMPTY":
+			return "", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		in, want, err string
+	}{
+		{in: "plain $HOME $(pwd)", want: "plain $HOME $(pwd)"},
+		{in: "hello ${NAME}!", want: "hello world!"},
+		{in: "${MISSING:-fallback}", want: "fallback"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "[${EMPTY}]", want: "[]"},
+		{in: "${MISSING:-}", want: ""},
+		{in: "$${NAME} ${NAME}", want: "${NAME} world"},
+		{in: "${MISSING}", err: "undefined variable ${MISSING}"},
+		{in: "${NAME", want: "${NAME"},
+		{in: "${#arr} ${@} ${1x}", want: "${#arr} ${@} ${1x}"},
+		{in: "${NAME%.go} ${NAME}", want: "${NAME%.go} world"},
+		{in: "${MISSING#x}", want: "${MISSING#x}"},
+	}
+
+	for _, tt := range tests {
+		got, err := Interpolate(tt.in, lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Interpolate(%q): expected error %q, got %q, %v", tt.in, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Interpolate(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestHookInterpolate(t *testing.T) {
+	t.Setenv("HOOKRUNNER_TEST_LEVEL", "3")
+	h := Hook{
+		Name: "lint",
+		Run:  "lint --level ${LEVEL} --os ${os}",
+		Args: []string{"${repo_root}/cfg", "${hook_type}"},
+		Root: "${scripts_dir}",
+		Env:  map[string]string{"LEVEL": "${HOOKRUNNER_TEST_LEVEL}", "CACHE": "${cache_dir}/lint"},
+	}
+
+	got, err := h.Interpolate(Vars{RepoRoot: "/repo", HookType: "pre-commit", ScriptsDir: "/repo/.hooks", CacheDir: "/cache"})
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if got.Run != "lint --level 3 --os "+runtime.GOOS {
+		t.Errorf("unexpected run %q", got.Run)
+	}
+	if got.Args[0] != "/repo/cfg" || got.Args[1] != "pre-commit" {
+		t.Errorf("unexpected args %v", got.Args)
+	}
+	if got.Root != "/repo/.hooks" || got.Env["CACHE"] != "/cache/lint" {
+		t.Errorf("unexpected root %q or env %v", got.Root, got.Env)
+	}
+	if h.Args[0] != "${repo_root}/cfg" {
+		t.Error("Interpolate modified the original hook")
+	}
+}
+
+func TestToolInterpolate(t *testing.T) {
+	tool := Tool{Version: "1.2.0", Install: map[string]string{"linux": "https://example.com/v${version}/lint-${os}-${arch}.tar.gz"}}
+	got, err := tool.Interpolate(Vars{})
+	if err != nil {
+		t.Fatalf("Interpolate failed: %v", err)
+	}
+	if want := "https://example.com/v1.2.0/lint-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz"; got.Install["linux"] != want {
+		t.Errorf("expected %q, got %q", want, got.Install["linux"])
+	}
+}
+
+func TestLoad_UndefinedVariable(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `tools:
+  lint:
+    install:
+      linux: https://example.com/${release}
+hooks:
+  pre-commit:
+    - name: lint
+      run: echo ${HOOKRUNNER_TEST_UNSET_VAR} ${1%.go}
+`)
+	// Variables are expanded when a hook runs, where the environment may
+	// differ, such as in CI.
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("expected config with undefined variables to load, got %v", err)
+	}
+	h := cfg.GetHooks("pre-commit")[0]
+	if _, err := h.Interpolate(Vars{}); err == nil || !strings.Contains(err.Error(), "run: undefined variable ${HOOKRUNNER_TEST_UNSET_VAR}") {
+		t.Errorf("expected undefined variable error, got %v", err)
+	}
+	if _, err := cfg.Tools["lint"].Interpolate(Vars{}); err == nil || !strings.Contains(err.Error(), "install.linux: undefined variable ${release}") {
+		t.Errorf("expected tool variable error, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// listMode is how a list or map field combines with the value it
+// overrides, in hooks-local or a hook extending a template. It is set with
+// the !replace and !append YAML tags.
+type listMode int
+
+const (
+	// listDefault replaces lists and merges maps by key.
+	listDefault listMode = iota
+	listReplace
+	listAppend
+)
+
+var listTags = map[string]listMode{"!replace": listReplace, "!append": listAppend}
+
+// recordListModes stores the !replace and !append tags of hook and
+// template fields, and of exclude_tags, on cfg, including those in
+// profiles. It also stores which fields each hook and template sets.
+func recordListModes(cfg *Config, file string, doc *yaml.Node) error {
+	if root := rootMapping(doc); root != nil {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			mode, ok, err := listModeOf(file, key, value)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if key.Value != "exclude_tags" {
+					return fmt.Errorf("%s:%d:%d: %s is not supported on %q", file, value.Line, value.Column, value.Tag, key.Value)
+				}
+				cfg.excludeTagsMode = mode
+			}
+		}
+	}
+
+	err := walkHookNodes(doc, func(section, name string, i int, _, item *yaml.Node) error {
+		switch section {
+		case "hooks":
+			return recordHookFields(file, item, cfg.Hooks[name], i)
+		case "templates":
+			t, ok := cfg.Templates[name]
+			if !ok {
+				return nil
+			}
+			modes, err := hookListModes(file, item)
+			if err != nil {
+				return err
+			}
+			t.listModes, t.keys = modes, hookKeys(item)
+			cfg.Templates[name] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return recordProfileListModes(cfg, file, doc)
+}
+
+// listModeOf returns the mode value is tagged with, if any.
+func listModeOf(file string, key, value *yaml.Node) (listMode, bool, error) {
+	mode, ok := listTags[value.Tag]
+	if !ok {
+		return 0, false, nil
+	}
+	if value.Kind != yaml.SequenceNode && value.Kind != yaml.MappingNode {
+		return 0, false, fmt.Errorf("%s:%d:%d: %s can only be used on a list or map, not %q", file, value.Line, value.Column, value.Tag, key.Value)
+	}
+	return mode, true, nil
+}
+
+// hookListModes returns the modes of the fields of the hook in item.
+func hookListModes(file string, item *yaml.Node) (map[string]listMode, error) {
+	if item.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var modes map[string]listMode
+	for j := 0; j+1 < len(item.Content); j += 2 {
+		mode, ok, err := listModeOf(file, item.Content[j], item.Content[j+1])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if modes == nil {
+				modes = make(map[string]listMode)
+			}
+			modes[item.Content[j].Value] = mode
+		}
+	}
+	return modes, nil
+}
+
+// hookKeys returns the keys of the hook in item.
+func hookKeys(item *yaml.Node) map[string]bool {
+	if item.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make(map[string]bool, len(item.Content)/2)
+	for j := 0; j+1 < len(item.Content); j += 2 {
+		keys[item.Content[j].Value] = true
+	}
+	return keys
+}
+
+// recordHookFields stores the modes and keys of the hook in item on
+// hooks[i].
+func recordHookFields(file string, item *yaml.Node, hooks []Hook, i int) error {
+	modes, err := hookListModes(file, item)
+	if err != nil || i >= len(hooks) {
+		return err
+	}
+	hooks[i].listModes, hooks[i].keys = modes, hookKeys(item)
+	return nil
+}
+
+// setKeys returns the keys of the fields h sets: those written in the
+// config, or for hooks built in code, those that are not zero.
+func (h Hook) setKeys() map[string]bool {
+	if h.keys != nil {
+		return h.keys
+	}
+	v := reflect.ValueOf(h)
+	keys := make(map[string]bool)
+	for _, f := range knownFields(v.Type()) {
+		if !v.FieldByIndex(f.Index).IsZero() {
+			keys[f.key] = true
+		}
+	}
+	return keys
+}
+
+// overrideFields returns base with every field that is set in over
+// replacing base's, even with a zero value. Maps are merged by key and
+// lists replaced, unless the field was tagged !replace or !append in over.
+func overrideFields(base, over Hook) Hook {
+	result := base
+	rv := reflect.ValueOf(&result).Elem()
+	ov := reflect.ValueOf(over)
+	set := over.setKeys()
+	keys := base.setKeys()
+	result.keys = make(map[string]bool, len(keys)+len(set))
+	for k := range keys {
+		result.keys[k] = true
+	}
+
+	keyOf := make(map[string]string)
+	for _, f := range knownFields(ov.Type()) {
+		keyOf[f.Name] = f.key
+	}
+
+	for i := 0; i < ov.NumField(); i++ {
+		field := ov.Type().Field(i)
+		f := ov.Field(i)
+		key, ok := keyOf[field.Name]
+		switch {
+		case !field.IsExported():
+			continue
+		case !ok:
+			// Not read from the config, such as Origin.
+			if f.IsZero() {
+				continue
+			}
+		case !set[key]:
+			continue
+		default:
+			result.keys[key] = true
+		}
+		mode := over.listModes[key]
+		dst := rv.Field(i)
+		switch {
+		case f.Kind() == reflect.Slice && mode == listAppend:
+			combined := reflect.MakeSlice(f.Type(), 0, dst.Len()+f.Len())
+			dst.Set(reflect.AppendSlice(reflect.AppendSlice(combined, dst), f))
+		case f.Kind() == reflect.Map && mode != listReplace && !dst.IsNil():
+			merged := reflect.MakeMapWithSize(f.Type(), dst.Len()+f.Len())
+			for _, m := range []reflect.Value{dst, f} {
+				iter := m.MapRange()
+				for iter.Next() {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			dst.Set(merged)
+		default:
+			dst.Set(f)
+		}
+	}
+	result.listModes = nil
+	return result
+}
+
+// overrideTool returns base with the fields set in over replacing base's.
+// Install URLs are merged per OS. A new version drops the checksum of the
+// old one unless over sets its own.
+func overrideTool(base, over Tool) Tool {
+	if over.Version != "" && over.Version != base.Version {
+		base.Version = over.Version
+		base.Checksum = ""
+	}
+	if over.Checksum != "" {
+		base.Checksum = over.Checksum
+	}
+	if len(over.Install) > 0 {
+		install := make(map[string]string, len(base.Install)+len(over.Install))
+		for goos, url := range base.Install {
+			install[goos] = url
+		}
+		for goos, url := range over.Install {
+			install[goos] = url
+		}
+		base.Install = install
+	}
+	return base
+}
+
+// overlayLocal merges hooks-local into cfg. Unlike includes, which replace
+// hooks and tools of the same name, local hooks and tools override only the
+// fields they set, so a developer can change just args or a tool version.
+func overlayLocal(cfg, local *Config) *Config {
+	hooks, tools := local.Hooks, local.Tools
+	local.Hooks, local.Tools = nil, nil
+	cfg = mergeConfigs(cfg, local)
+
+	for name, t := range tools {
+		if cfg.Tools == nil {
+			cfg.Tools = make(map[string]Tool)
+		}
+		if base, ok := cfg.Tools[name]; ok {
+			t = overrideTool(base, t)
+		}
+		cfg.Tools[name] = t
+	}
+
+	cfg.overlayHooks(hooks)
+	return cfg
+}
+
+// overlayHooks overrides the fields of hooks with the same name set in
+// hooks, and adds the others.
+func (c *Config) overlayHooks(hooks map[string][]Hook) {
+	for hookType, lhs := range hooks {
+		if c.Hooks == nil {
+			c.Hooks = make(map[string][]Hook)
+		}
+	next:
+		for _, lh := range lhs {
+			for i, bh := range c.Hooks[hookType] {
+				if bh.Name == lh.Name {
+					c.Hooks[hookType][i] = overrideFields(bh, lh)
+					continue next
+				}
+			}
+			c.Hooks[hookType] = append(c.Hooks[hookType], lh)
+		}
+	}
+}
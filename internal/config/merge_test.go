@@ -0,0 +1,194 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad_LocalOverridesFields(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `tools:
+  golangci-lint:
+    version: 1.55.0
+    checksum: abc123
+    install:
+      linux: https://example.com/v${version}/linux.tar.gz
+      darwin: https://example.com/v${version}/darwin.tar.gz
+exclude_tags: [slow]
+policies:
+  localPolicies:
+    - name: team
+      rules:
+        max_files_changed: 50
+hooks:
+  pre-commit:
+    - name: lint
+      tool: golangci-lint
+      args: [run]
+      files: \.go$
+      timeout: 5m
+      tags: [go]
+      env:
+        GOFLAGS: -mod=mod
+    - name: vet
+      run: go vet ./...
+`)
+	writeConfig(t, dir, "hooks-local.yaml", `tools:
+  golangci-lint:
+    version: 1.56.0
+    install:
+      darwin: file:///opt/lint.tar.gz
+exclude_tags: !replace [docker]
+policies:
+  localPolicies:
+    - name: mine
+      rules:
+        forbid_files: [secrets.txt]
+hooks:
+  pre-commit:
+    - name: lint
+      args: [run, --fast]
+      timeout: 1m
+      tags: !append [local]
+      env:
+        GOGC: "50"
+    - name: vet
+      disabled: true
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	hooks := cfg.GetHooks("pre-commit")
+	lint := hooks[0]
+	if lint.Tool != "golangci-lint" || lint.Files != `\.go$` {
+		t.Errorf("expected fields not set locally to be kept, got %+v", lint)
+	}
+	if strings.Join(lint.Args, " ") != "run --fast" || lint.Timeout != "1m" {
+		t.Errorf("expected args and timeout overridden, got %v %s", lint.Args, lint.Timeout)
+	}
+	if strings.Join(lint.Tags, ",") != "go,local" {
+		t.Errorf("expected !append to extend tags, got %v", lint.Tags)
+	}
+	if lint.Env["GOFLAGS"] != "-mod=mod" || lint.Env["GOGC"] != "50" {
+		t.Errorf("expected env merged by key, got %v", lint.Env)
+	}
+	if !hooks[1].Disabled || hooks[1].Run != "go vet ./..." {
+		t.Errorf("expected vet disabled and otherwise unchanged, got %+v", hooks[1])
+	}
+
+	tool := cfg.Tools["golangci-lint"]
+	if tool.Version != "1.56.0" || tool.Checksum != "" {
+		t.Errorf("expected version override to drop the old checksum, got %+v", tool)
+	}
+	if tool.Install["linux"] != "https://example.com/v${version}/linux.tar.gz" || tool.Install["darwin"] != "file:///opt/lint.tar.gz" {
+		t.Errorf("expected install URLs merged per OS, got %v", tool.Install)
+	}
+
+	if strings.Join(cfg.ExcludeTags, ",") != "docker" {
+		t.Errorf("expected !replace to replace exclude_tags, got %v", cfg.ExcludeTags)
+	}
+	if lps := cfg.Policies.LocalPolicies; len(lps) != 2 || lps[1].Name != "mine" {
+		t.Errorf("expected local policy to be added, got %+v", lps)
+	}
+}
+
+func TestLoad_LocalOverridesZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `templates:
+  strict:
+    run: make check
+    fail_fast: true
+hooks:
+  pre-commit:
+    - name: lint
+      run: lint
+      files: \.go$
+      priority: 5
+      disabled: true
+    - name: check
+      extends: strict
+      fail_fast: false
+`)
+	writeConfig(t, dir, "hooks-local.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      files: ""
+      priority: 0
+      disabled: false
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	hooks := cfg.GetHooks("pre-commit")
+	if lint := hooks[0]; lint.Disabled || lint.Priority != 0 || lint.Files != "" || lint.Run != "lint" {
+		t.Errorf("expected fields set to zero values locally to override, got %+v", lint)
+	}
+	if check := hooks[1]; check.FailFast || check.Run != "make check" {
+		t.Errorf("expected fail_fast: false to override the template, got %+v", check)
+	}
+}
+
+func TestLoad_ExtendsListModes(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `templates:
+  lint:
+    tool: golangci-lint
+    args: [run]
+    env: {A: "1", B: "2"}
+hooks:
+  pre-commit:
+    - name: fast
+      extends: lint
+      args: !append [--fast]
+      env: !replace {C: "3"}
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	h := cfg.GetHooks("pre-commit")[0]
+	if strings.Join(h.Args, " ") != "run --fast" {
+		t.Errorf("expected args appended to the template's, got %v", h.Args)
+	}
+	if len(h.Env) != 1 || h.Env["C"] != "3" {
+		t.Errorf("expected env replaced, got %v", h.Env)
+	}
+	if cfg.Templates["lint"].Args[0] != "run" || len(cfg.Templates["lint"].Args) != 1 {
+		t.Errorf("template was modified: %v", cfg.Templates["lint"].Args)
+	}
+}
+
+func TestLoad_ListTagErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      run: !append lint
+`)
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `hooks.yaml:4:12: !append can only be used on a list or map, not "run"`) {
+		t.Fatalf("expected tag error, got %v", err)
+	}
+
+	writeConfig(t, dir, "hooks.yaml", "scripts_dir: .hooks\nhooks: !replace {}\n")
+	_, _, err = Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `!replace is not supported on "hooks"`) {
+		t.Fatalf("expected unsupported tag error, got %v", err)
+	}
+}
+
+func TestLoad_LocalParseError(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", "hooks: {}\n")
+	writeConfig(t, dir, "hooks-local.yaml", "hooks:\n  pre-commit: [\n")
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "invalid YAML config") {
+		t.Fatalf("expected hooks-local parse error to be reported, got %v", err)
+	}
+}
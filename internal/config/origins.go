@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OriginPath joins the keys of a value in the config into the path it is
+// recorded under in Config.Origins. Hooks and policies in lists are named
+// by their name or url, so hooks.pre-commit.lint.args is the args of the
+// pre-commit hook lint.
+func OriginPath(keys ...string) string {
+	return strings.Join(keys, ".")
+}
+
+// ItemKey returns the key of item, the i-th entry of a list, in an origin
+// path: its name or url when it is a mapping with one.
+func ItemKey(item *yaml.Node, i int) string {
+	if item.Kind == yaml.MappingNode {
+		for _, field := range []string{"name", "url"} {
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				if item.Content[j].Value == field && item.Content[j+1].Kind == yaml.ScalarNode {
+					return item.Content[j+1].Value
+				}
+			}
+		}
+	}
+	return strconv.Itoa(i)
+}
+
+// recordOrigins returns the file and line of every key and list entry in
+// doc, by origin path. Entries of lists of scalars are not recorded; their
+// origin is the list's.
+func recordOrigins(file string, doc *yaml.Node) map[string]Origin {
+	origins := make(map[string]Origin)
+	var walk func(path string, n *yaml.Node)
+	walk = func(path string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				p := key.Value
+				if path != "" {
+					p = OriginPath(path, key.Value)
+				}
+				origins[p] = Origin{File: file, Line: key.Line}
+				walk(p, n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				if item.Kind != yaml.MappingNode {
+					continue
+				}
+				p := OriginPath(path, ItemKey(item, i))
+				origins[p] = Origin{File: file, Line: item.Line}
+				walk(p, item)
+			}
+		}
+	}
+	if root := rootMapping(doc); root != nil {
+		walk("", root)
+	}
+	return origins
+}
+
+// copyOrigins records the origins in src of from and the values under it
+// in dst, as those of to and the values under it. Values that already have
+// an origin in dst keep it unless overwrite is set.
+func copyOrigins(dst, src map[string]Origin, from, to string, overwrite bool) {
+	copied := make(map[string]Origin)
+	for p, o := range src {
+		switch {
+		case p == from:
+			copied[to] = o
+		case strings.HasPrefix(p, from+"."):
+			copied[to+p[len(from):]] = o
+		}
+	}
+	for p, o := range copied {
+		if _, ok := dst[p]; ok && !overwrite {
+			continue
+		}
+		dst[p] = o
+	}
+}
+
+// setOrigins makes sure c has an origins map to record into.
+func (c *Config) setOrigins() {
+	if c.Origins == nil {
+		c.Origins = make(map[string]Origin)
+	}
+}
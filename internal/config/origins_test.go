@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_Origins(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "shared.yaml", `templates:
+  lint:
+    run: golangci-lint run
+    timeout: 5m
+`)
+	writeConfig(t, dir, "hooks.yaml", `include: [shared.yaml]
+exclude_tags: [slow]
+hooks:
+  pre-commit:
+    - name: lint
+      extends: lint
+      args: [--fast]
+profiles:
+  ci:
+    jobs: 2
+    env:
+      CI: "1"
+    hooks:
+      pre-commit:
+        - name: lint
+          timeout: 10m
+`)
+	writeConfig(t, dir, "hooks-local.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      args: [--fix]
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyProfile("ci"); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"exclude_tags":                  "hooks.yaml:2",
+		"jobs":                          "hooks.yaml:10",
+		"hooks.pre-commit.lint":         "hooks.yaml:15",
+		"hooks.pre-commit.lint.run":     "shared.yaml:3",
+		"hooks.pre-commit.lint.args":    "hooks-local.yaml:4",
+		"hooks.pre-commit.lint.timeout": "hooks.yaml:16",
+		"hooks.pre-commit.lint.env":     "hooks.yaml:11",
+	} {
+		o, ok := cfg.Origins[path]
+		if !ok {
+			t.Errorf("%s: no origin", path)
+			continue
+		}
+		if got := (Origin{File: filepath.Base(o.File), Line: o.Line}).String(); got != want {
+			t.Errorf("%s: origin %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestLoadAt(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	initRepo(t, dir)
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, dir, "hooks.yaml", "include: [shared.yaml]\nnested: [web]\nhooks:\n  pre-commit:\n    - name: fmt\n      run: gofmt -l .\n")
+	writeConfig(t, dir, "shared.yaml", "exclude_tags: [slow]\n")
+	writeConfig(t, dir, "web/hooks.yaml", "hooks:\n  pre-commit:\n    - name: eslint\n      run: eslint .\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+
+	writeConfig(t, dir, "shared.yaml", "exclude_tags: [lint]\n")
+	writeConfig(t, dir, "hooks-local.yaml", "exclude_tags: [local]\n")
+
+	cfg, path, err := LoadAt(dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "HEAD:hooks.yaml" {
+		t.Errorf("path = %q", path)
+	}
+	if len(cfg.ExcludeTags) != 1 || cfg.ExcludeTags[0] != "slow" {
+		t.Errorf("exclude_tags = %v, want the committed include without hooks-local", cfg.ExcludeTags)
+	}
+	hooks := cfg.GetHooks("pre-commit")
+	if len(hooks) != 2 || hooks[1].Name != "web:eslint" {
+		t.Errorf("hooks = %+v, want the nested config's hooks scoped", hooks)
+	}
+	if o := cfg.Origins["hooks.pre-commit.web:eslint.run"]; o.File != "HEAD:web/hooks.yaml" || o.Line != 4 {
+		t.Errorf("nested hook origin = %v", o)
+	}
+
+	if _, _, err := LoadAt(dir, "no-such-ref"); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+}
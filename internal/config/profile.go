@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileEnv is the environment variable that selects a profile when
+// --profile is not given.
+const ProfileEnv = "HOOKRUNNER_PROFILE"
+
+// CIProfile is the profile selected automatically when running in CI.
+const CIProfile = "ci"
+
+// Profile is a named set of overrides applied on top of the config, such
+// as a ci profile that runs slow hooks or a fast one for laptops. Hooks
+// override the fields they set, like in hooks-local; exclude_tags and
+// policies add to the config's, and env is added to every hook's.
+type Profile struct {
+	Hooks       map[string][]Hook `yaml:"hooks" json:"hooks"`
+	ExcludeTags []string          `yaml:"exclude_tags" json:"exclude_tags"`
+	Jobs        int               `yaml:"jobs" json:"jobs"`
+	Env         map[string]string `yaml:"env" json:"env"`
+	Policies    *Policies         `yaml:"policies" json:"policies"`
+
+	excludeTagsMode listMode
+}
+
+// ciEnv are variables set by CI systems.
+var ciEnv = []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TF_BUILD", "TEAMCITY_VERSION", "BITBUCKET_BUILD_NUMBER"}
+
+// InCI reports whether HookRunner is running in a CI system.
+func InCI() bool {
+	for _, name := range ciEnv {
+		if v := os.Getenv(name); v != "" && v != "false" && v != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectProfile returns the profile to apply and how it was chosen: the
+// one named by flag, else by HOOKRUNNER_PROFILE, else the ci profile when
+// running in CI. Only flag may name a profile the config does not define;
+// HOOKRUNNER_PROFILE is usually set for every repository, so it is skipped
+// in those without the profile. The name is empty if no profile applies.
+func (c *Config) SelectProfile(flag string) (name, reason string) {
+	if flag != "" {
+		return flag, "--profile"
+	}
+	if env := os.Getenv(ProfileEnv); env != "" {
+		if _, ok := c.Profiles[env]; ok {
+			return env, ProfileEnv
+		}
+	}
+	if _, ok := c.Profiles[CIProfile]; ok && InCI() {
+		return CIProfile, "CI detected"
+	}
+	return "", ""
+}
+
+// ProfileNames returns the names of the profiles the config defines.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile merges the named profile into the config.
+func (c *Config) ApplyProfile(name string) error {
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q: the config defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (defined: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	c.setOrigins()
+	prefix := OriginPath("profiles", name)
+	for _, key := range []string{"exclude_tags", "jobs", "policies"} {
+		copyOrigins(c.Origins, c.Origins, OriginPath(prefix, key), key, true)
+	}
+
+	if p.excludeTagsMode == listReplace {
+		c.ExcludeTags = p.ExcludeTags
+	} else if p.ExcludeTags != nil {
+		c.ExcludeTags = append(c.ExcludeTags, p.ExcludeTags...)
+	}
+	if p.Jobs != 0 {
+		c.Jobs = p.Jobs
+	}
+	c.Policies = mergePolicies(c.Policies, p.Policies)
+
+	// Env goes first so that the profile's own hooks can override it.
+	if len(p.Env) > 0 {
+		for hookType, hooks := range c.Hooks {
+			for i := range hooks {
+				c.Hooks[hookType][i] = overrideFields(hooks[i], Hook{Env: p.Env})
+				copyOrigins(c.Origins, c.Origins, OriginPath(prefix, "env"), OriginPath("hooks", hookType, hooks[i].Name, "env"), true)
+			}
+		}
+	}
+	profileHooks := &Config{Templates: c.Templates, Hooks: p.Hooks, Origins: make(map[string]Origin)}
+	copyOrigins(profileHooks.Origins, c.Origins, "templates", "templates", true)
+	copyOrigins(profileHooks.Origins, c.Origins, OriginPath(prefix, "hooks"), "hooks", true)
+	if err := profileHooks.resolveExtends(); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	c.overlayHooks(profileHooks.Hooks)
+	copyOrigins(c.Origins, profileHooks.Origins, "hooks", "hooks", true)
+
+	c.Profile = name
+	if err := c.validateDependencies(); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// walkProfiles calls fn with the name and mapping node of every profile
+// in doc.
+func walkProfiles(doc *yaml.Node, fn func(name string, node *yaml.Node) error) error {
+	root := rootMapping(doc)
+	if root == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "profiles" || root.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		profiles := root.Content[i+1]
+		for j := 0; j+1 < len(profiles.Content); j += 2 {
+			if node := profiles.Content[j+1]; node.Kind == yaml.MappingNode {
+				if err := fn(profiles.Content[j].Value, node); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// profileHooksNode returns the hooks map of a profile node, if any.
+func profileHooksNode(node *yaml.Node) *yaml.Node {
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		if node.Content[k].Value == "hooks" {
+			return node.Content[k+1]
+		}
+	}
+	return nil
+}
+
+// annotateProfileOrigins records the file and line of each profile hook.
+func annotateProfileOrigins(cfg *Config, file string, doc *yaml.Node) error {
+	return walkProfiles(doc, func(name string, node *yaml.Node) error {
+		hooks := profileHooksNode(node)
+		if hooks == nil {
+			return nil
+		}
+		return walkSection("hooks", hooks, func(_, hookType string, i int, _, item *yaml.Node) error {
+			if hs := cfg.Profiles[name].Hooks[hookType]; i < len(hs) {
+				hs[i].Origin = Origin{File: file, Line: item.Line}
+			}
+			return nil
+		})
+	})
+}
+
+// recordProfileListModes stores the !replace and !append tags of the
+// exclude_tags and hook fields of each profile.
+func recordProfileListModes(cfg *Config, file string, doc *yaml.Node) error {
+	return walkProfiles(doc, func(name string, node *yaml.Node) error {
+		p := cfg.Profiles[name]
+		for k := 0; k+1 < len(node.Content); k += 2 {
+			key, value := node.Content[k], node.Content[k+1]
+			mode, ok, err := listModeOf(file, key, value)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if key.Value != "exclude_tags" {
+				return fmt.Errorf("%s:%d:%d: %s is not supported on %q", file, value.Line, value.Column, value.Tag, key.Value)
+			}
+			p.excludeTagsMode = mode
+		}
+		cfg.Profiles[name] = p
+
+		hooks := profileHooksNode(node)
+		if hooks == nil {
+			return nil
+		}
+		return walkSection("hooks", hooks, func(_, hookType string, i int, _, item *yaml.Node) error {
+			return recordHookFields(file, item, p.Hooks[hookType], i)
+		})
+	})
+}
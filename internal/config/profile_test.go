@@ -0,0 +1,170 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `exclude_tags: [slow]
+templates:
+  e2e:
+    run: make e2e
+    timeout: 20m
+hooks:
+  pre-commit:
+    - name: fmt
+      run: gofmt -w .
+      tags: [fixer]
+    - name: test
+      run: go test ./...
+      tags: [slow]
+      env:
+        GOFLAGS: -count=1
+profiles:
+  ci:
+    exclude_tags: !replace [fixer]
+    jobs: 2
+    env:
+      CI_MODE: "1"
+    hooks:
+      pre-commit:
+        - name: test
+          args: !append [-race]
+          env:
+            GOFLAGS: -count=1 -race
+        - name: e2e
+          extends: e2e
+          needs: test
+    policies:
+      localPolicies:
+        - name: ci-only
+          rules:
+            max_files_changed: 100
+  fast:
+    exclude_tags: [lint]
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.ProfileNames(); strings.Join(got, ",") != "ci,fast" {
+		t.Errorf("profile names = %v", got)
+	}
+	if err := cfg.ApplyProfile("ci"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Profile != "ci" || cfg.Jobs != 2 {
+		t.Errorf("profile = %q, jobs = %d", cfg.Profile, cfg.Jobs)
+	}
+	if strings.Join(cfg.ExcludeTags, ",") != "fixer" {
+		t.Errorf("exclude_tags = %v, want replaced by [fixer]", cfg.ExcludeTags)
+	}
+	if len(cfg.Policies.LocalPolicies) != 1 || cfg.Policies.LocalPolicies[0].Name != "ci-only" {
+		t.Errorf("policies = %+v", cfg.Policies)
+	}
+
+	hooks := cfg.GetHooks("pre-commit")
+	if len(hooks) != 3 {
+		t.Fatalf("hooks = %+v", hooks)
+	}
+	fmtHook, test, e2e := hooks[0], hooks[1], hooks[2]
+	if fmtHook.Env["CI_MODE"] != "1" {
+		t.Errorf("profile env not added to fmt: %v", fmtHook.Env)
+	}
+	if test.Env["CI_MODE"] != "1" || test.Env["GOFLAGS"] != "-count=1 -race" {
+		t.Errorf("test env = %v", test.Env)
+	}
+	if test.Run != "go test ./..." || strings.Join(test.Args, " ") != "-race" {
+		t.Errorf("test = %+v", test)
+	}
+	if e2e.Run != "make e2e" || e2e.Timeout != "20m" || e2e.Needs[0] != "test" {
+		t.Errorf("e2e = %+v", e2e)
+	}
+}
+
+func TestApplyProfile_AppendsExcludeTags(t *testing.T) {
+	cfg := &Config{
+		ExcludeTags: []string{"slow"},
+		Profiles:    map[string]Profile{"fast": {ExcludeTags: []string{"lint"}}},
+	}
+	if err := cfg.ApplyProfile("fast"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cfg.ExcludeTags, ",") != "slow,lint" {
+		t.Errorf("exclude_tags = %v", cfg.ExcludeTags)
+	}
+}
+
+func TestApplyProfile_Unknown(t *testing.T) {
+	cfg := &Config{Profiles: map[string]Profile{"ci": {}, "fast": {}}}
+	err := cfg.ApplyProfile("slow")
+	if err == nil || !strings.Contains(err.Error(), "defined: ci, fast") {
+		t.Errorf("expected unknown profile error listing profiles, got %v", err)
+	}
+	err = (&Config{}).ApplyProfile("ci")
+	if err == nil || !strings.Contains(err.Error(), "defines no profiles") {
+		t.Errorf("expected error without profiles, got %v", err)
+	}
+}
+
+func TestApplyProfile_UnknownNeeds(t *testing.T) {
+	cfg := &Config{Profiles: map[string]Profile{"ci": {Hooks: map[string][]Hook{
+		"pre-commit": {{Name: "e2e", Run: "make e2e", Needs: StringList{"build"}}},
+	}}}}
+	if err := cfg.ApplyProfile("ci"); err == nil || !strings.Contains(err.Error(), "profile ci") {
+		t.Errorf("expected a dependency error from the profile, got %v", err)
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	for _, name := range ciEnv {
+		t.Setenv(name, "")
+	}
+	t.Setenv(ProfileEnv, "")
+	cfg := &Config{Profiles: map[string]Profile{"ci": {}, "fast": {}}}
+
+	if name, _ := cfg.SelectProfile(""); name != "" {
+		t.Errorf("selected %q without flag, env or CI", name)
+	}
+
+	t.Setenv("CI", "true")
+	if name, reason := cfg.SelectProfile(""); name != "ci" || reason != "CI detected" {
+		t.Errorf("in CI: got %q (%s)", name, reason)
+	}
+	if name, _ := (&Config{}).SelectProfile(""); name != "" {
+		t.Errorf("selected %q in CI without a ci profile", name)
+	}
+
+	t.Setenv(ProfileEnv, "fast")
+	if name, reason := cfg.SelectProfile(""); name != "fast" || reason != ProfileEnv {
+		t.Errorf("with %s: got %q (%s)", ProfileEnv, name, reason)
+	}
+	if name, reason := cfg.SelectProfile("ci"); name != "ci" || reason != "--profile" {
+		t.Errorf("with --profile: got %q (%s)", name, reason)
+	}
+
+	// A profile from the environment is skipped where it is not defined.
+	t.Setenv(ProfileEnv, "slow")
+	if name, reason := cfg.SelectProfile(""); name != "ci" || reason != "CI detected" {
+		t.Errorf("with undefined %s: got %q (%s)", ProfileEnv, name, reason)
+	}
+	if name, _ := (&Config{}).SelectProfile(""); name != "" {
+		t.Errorf("selected %q with undefined %s", name, ProfileEnv)
+	}
+	if name, _ := (&Config{}).SelectProfile("slow"); name != "slow" {
+		t.Errorf("expected --profile to be kept even if undefined, got %q", name)
+	}
+}
+
+func TestLoad_ProfileListTagOnScalar(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", "profiles:\n  ci:\n    hooks:\n      pre-commit:\n        - name: test\n          run: !append make\n")
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "hooks.yaml:6:16: !append can only be used on a list or map, not \"run\"") {
+		t.Errorf("expected error for !append on a scalar, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+//go:generate sh -c "go run ../../cmd/hookrunner schema > schema/hooks.schema.json"
+
+// SchemaID identifies the generated hooks.yaml schema: the raw URL of the
+// committed schema/hooks.schema.json.
+const SchemaID = "https://raw.githubusercontent.com/ashavijit/hookrunner/master/internal/config/schema/hooks.schema.json"
+
+// fieldDescriptions documents config fields in the generated schema,
+// keyed by "<Type>.<key>".
+var fieldDescriptions = map[string]string{
+	"Config.version":                "Config version; files without one are version 1 and read after upgrading",
+	"Config.min_hookrunner_version": "Oldest hookrunner release that can read this config",
+	"Config.tools":                  "Tools HookRunner downloads and pins, by name",
+	"Config.hooks":                  "Hooks to run, by git hook type (pre-commit, pre-push, commit-msg, ...), or a list of hooks with stages",
+	"Config.policies":               "Policy rules evaluated before hooks run",
+	"Config.exclude_tags":           "Skip hooks carrying any of these tags",
+	"Config.scripts_dir":            "Directory holding hook scripts (default .hooks)",
+	"Config.clean_room":             "Settings for --clean-room runs",
+	"Config.include":                "Config files merged before this one; paths, globs or {repo, ref, path}",
+	"Config.nested":                 "Subdirectories whose own config is merged, with its hooks scoped to the directory",
+	"Config.templates":              "Hook templates, by name, that hooks reuse with extends",
+	"Config.jobs":                   "Maximum number of hooks to run at once when --jobs is not given",
+	"Config.profiles":               "Named overrides selected with --profile, HOOKRUNNER_PROFILE, or ci in CI",
+	"RegexBlockPattern.scope":       "file (default) to match the whole file, or added_lines to match only added lines",
+	"ForbiddenContentPattern.scope": "file (default) to match the whole file, or added_lines to match only added lines",
+	"Hook.name":                     "Unique hook name within its hook type",
+	"Hook.stages":                   "Hook types and custom stages (manual, ci, ...) to run the hook in; only in a hooks list",
+	"Hook.tool":                     "Managed tool to run",
+	"Hook.run":                      "Inline shell command",
+	"Hook.script":                   "Script in scripts_dir to run",
+	"Hook.runner":                   "Interpreter for script (default sh, or powershell for .ps1)",
+	"Hook.args":                     "Arguments passed to tool",
+	"Hook.fix_args":                 "Arguments used instead of args with --fix",
+	"Hook.files":                    "Regex files must match for the hook to run",
+	"Hook.glob":                     "Glob file base names must match",
+	"Hook.exclude":                  "Regex of files to leave out",
+	"Hook.root":                     "Working directory, relative to the repository",
+	"Hook.timeout":                  "Maximum run time, as a Go duration (default 5m)",
+	"Hook.after":                    "Hooks that must finish first (ordering only)",
+	"Hook.needs":                    "Hooks that must pass first; the hook is blocked otherwise",
+	"Hook.priority":                 "Higher runs first among hooks that are ready together",
+	"Hook.outputs":                  "Keys this hook writes to $HOOKRUNNER_OUTPUT",
+	"Hook.artifacts":                "Files or globs this hook produces",
+	"Hook.skip":                     "Skip the hook when this environment variable is set",
+	"Hook.only":                     "Run the hook only when this environment variable is set",
+	"Hook.tags":                     "Tags used by exclude_tags",
+	"Hook.env":                      "Extra environment variables",
+	"Hook.extends":                  "Template whose fields this hook inherits",
+	"Hook.disabled":                 "Turn the hook off, e.g. from hooks-local.yaml",
+
+	"Profile.hooks":        "Hook fields to override, by hook type and name; other hooks are added",
+	"Profile.exclude_tags": "Tags added to exclude_tags (!replace to replace them)",
+	"Profile.jobs":         "Maximum number of hooks to run at once",
+	"Profile.env":          "Environment variables added to every hook",
+	"Profile.policies":     "Policies added to the config's",
+}
+
+// Schema returns a JSON Schema for hooks.yaml and hooks.json, generated
+// from the Config type.
+func Schema() ([]byte, error) {
+	defs := make(map[string]any)
+	root := schemaFor(reflect.TypeOf(Config{}), defs)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["$id"] = SchemaID
+	root["title"] = "HookRunner Configuration"
+	root["definitions"] = defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+var (
+	stringListType = reflect.TypeOf(StringList{})
+	includeType    = reflect.TypeOf(Include{})
+	regexBlockType = reflect.TypeOf(RegexBlockPattern{})
+	hooksType      = reflect.TypeOf(map[string][]Hook{})
+)
+
+// schemaFor returns the schema of t. Named struct types are added to defs
+// and referenced, except for the root.
+func schemaFor(t reflect.Type, defs map[string]any) map[string]any {
+	if t == stringListType {
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string"},
+				map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			},
+		}
+	}
+
+	if t == hooksType {
+		// Hooks by type, or one list of hooks with stages.
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), defs)},
+				schemaFor(t.Elem(), defs),
+			},
+		}
+	}
+
+	if t == includeType || t == regexBlockType {
+		// A string, or the struct to set more than one field.
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = map[string]any{
+				"oneOf": []any{
+					map[string]any{"type": "string"},
+					structSchema(t, defs),
+				},
+			}
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaFor(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(Config{}) {
+			return structSchema(t, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // reserve the name before recursing
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := make(map[string]any)
+	for _, f := range knownFields(t) {
+		s := schemaFor(f.Type, defs)
+		if desc, ok := fieldDescriptions[t.Name()+"."+f.key]; ok {
+			s["description"] = desc
+		}
+		props[f.key] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+type configField struct {
+	reflect.StructField
+	key string
+}
+
+// knownFields returns the YAML keys of a config struct.
+func knownFields(t reflect.Type) []configField {
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		fields = append(fields, configField{StructField: f, key: key})
+	}
+	return fields
+}
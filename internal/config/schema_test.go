@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		ID                   string                    `json:"$id"`
+		Properties           map[string]map[string]any `json:"properties"`
+		AdditionalProperties bool                      `json:"additionalProperties"`
+		Definitions          map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	if schema.ID != SchemaID || schema.AdditionalProperties {
+		t.Errorf("unexpected root: id=%q additionalProperties=%v", schema.ID, schema.AdditionalProperties)
+	}
+	for _, key := range []string{"tools", "hooks", "policies", "clean_room"} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("missing top-level property %q", key)
+		}
+	}
+	for _, def := range []string{"Hook", "Tool", "Policies", "PolicyRules", "LocalPolicy"} {
+		if _, ok := schema.Definitions[def]; !ok {
+			t.Errorf("missing definition %q", def)
+		}
+	}
+
+	hook := schema.Definitions["Hook"].Properties
+	if _, ok := hook["after"]["oneOf"]; !ok {
+		t.Error("after should accept a string or a list")
+	}
+	if hook["timeout"]["type"] != "string" || hook["priority"]["type"] != "integer" {
+		t.Errorf("unexpected field types: %v %v", hook["timeout"], hook["priority"])
+	}
+}
+
+func TestSchema_UpToDate(t *testing.T) {
+	want, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join("schema", "hooks.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("schema/hooks.schema.json is stale; run 'go generate ./internal/config'")
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManualStage is the stage of hooks no git hook runs. They run when named,
+// as in 'hookrunner run lint', or all at once with 'hookrunner run manual'.
+const ManualStage = "manual"
+
+// gitStages are the hook types 'hookrunner install' sets up, in the order
+// they are listed.
+var gitStages = []string{"pre-commit", "pre-push", "commit-msg"}
+
+// GitStages returns the hook types git runs, which 'hookrunner install'
+// sets up.
+func GitStages() []string {
+	return append([]string(nil), gitStages...)
+}
+
+// IsGitStage reports whether stage is a hook type git runs, rather than
+// manual or a custom stage.
+func IsGitStage(stage string) bool {
+	for _, s := range gitStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Stages returns the hook types that have hooks: the git hooks first, then
+// manual and custom stages, sorted.
+func (c *Config) Stages() []string {
+	var stages, others []string
+	git := make(map[string]bool, len(gitStages))
+	for _, s := range gitStages {
+		git[s] = true
+		if len(c.Hooks[s]) > 0 {
+			stages = append(stages, s)
+		}
+	}
+	for s, hooks := range c.Hooks {
+		if !git[s] && len(hooks) > 0 {
+			others = append(others, s)
+		}
+	}
+	sort.Strings(others)
+	return append(stages, others...)
+}
+
+// HookCount returns the number of hooks by name, counting a hook that runs
+// in several stages once.
+func (c *Config) HookCount() int {
+	seen := make(map[string]bool)
+	for _, hooks := range c.Hooks {
+		for _, h := range hooks {
+			seen[h.Name] = true
+		}
+	}
+	return len(seen)
+}
+
+// FindHook returns the first stage, in Stages order, with a hook named
+// name, and that hook.
+func (c *Config) FindHook(name string) (string, Hook, bool) {
+	for _, stage := range c.Stages() {
+		for _, h := range c.Hooks[stage] {
+			if h.Name == name {
+				return stage, h, true
+			}
+		}
+	}
+	return "", Hook{}, false
+}
+
+// expandStages rewrites each hooks section of root, the root mapping of
+// file, that is written as one list of hooks with stages into the map of
+// hooks by hook type, listing each hook under every one of its stages. It
+// reports whether it rewrote anything. Hooks in the map form, and
+// templates, may not set stages.
+func expandStages(file string, root *yaml.Node) (bool, error) {
+	sections := []*yaml.Node{}
+	if _, hooks := mappingValue(root, "hooks"); hooks != nil {
+		sections = append(sections, hooks)
+	}
+	if _, profiles := mappingValue(root, "profiles"); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 1; i < len(profiles.Content); i += 2 {
+			if _, hooks := mappingValue(profiles.Content[i], "hooks"); hooks != nil {
+				sections = append(sections, hooks)
+			}
+		}
+	}
+	if _, templates := mappingValue(root, "templates"); templates != nil && templates.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(templates.Content); i += 2 {
+			if key, _ := mappingValue(templates.Content[i+1], "stages"); key != nil {
+				return false, fmt.Errorf("%s:%d: template %s: stages can only be set on hooks in a hooks list", file, key.Line, templates.Content[i].Value)
+			}
+		}
+	}
+
+	expanded := false
+	for _, hooks := range sections {
+		ok, err := expandHooks(file, hooks)
+		if err != nil {
+			return false, err
+		}
+		expanded = expanded || ok
+	}
+	return expanded, nil
+}
+
+func expandHooks(file string, hooks *yaml.Node) (bool, error) {
+	switch hooks.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(hooks.Content); i += 2 {
+			for j, item := range hooks.Content[i+1].Content {
+				if key, _ := mappingValue(item, "stages"); key != nil {
+					return false, fmt.Errorf("%s:%d: hook %s: stages can only be set on hooks in a hooks list, not under %s", file, key.Line, ItemKey(item, j), hooks.Content[i].Value)
+				}
+			}
+		}
+		return false, nil
+	case yaml.SequenceNode:
+	default:
+		return false, nil
+	}
+
+	var stages []string
+	byStage := make(map[string]*yaml.Node)
+	for i, item := range hooks.Content {
+		key, value := mappingValue(item, "stages")
+		if key == nil {
+			return false, fmt.Errorf("%s:%d: hook %s: hooks in a hooks list need stages", file, item.Line, ItemKey(item, i))
+		}
+		var names []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			names = append(names, value.Value)
+		case yaml.SequenceNode:
+			for _, n := range value.Content {
+				names = append(names, n.Value)
+			}
+		}
+		if len(names) == 0 {
+			return false, fmt.Errorf("%s:%d: hook %s: stages is empty", file, key.Line, ItemKey(item, i))
+		}
+
+		for _, stage := range names {
+			list, ok := byStage[stage]
+			if !ok {
+				list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: item.Line, Column: item.Column}
+				byStage[stage] = list
+				stages = append(stages, stage)
+			}
+			if n := len(list.Content); n > 0 && list.Content[n-1] == item {
+				continue
+			}
+			list.Content = append(list.Content, item)
+		}
+	}
+
+	content := make([]*yaml.Node, 0, 2*len(stages))
+	for _, stage := range stages {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: stage, Line: hooks.Line, Column: hooks.Column}
+		content = append(content, key, byStage[stage])
+	}
+	hooks.Kind, hooks.Tag, hooks.Style, hooks.Content = yaml.MappingNode, "!!map", 0, content
+	return true, nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func hookNames(hooks []Hook) string {
+	names := make([]string, len(hooks))
+	for i, h := range hooks {
+		names[i] = h.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLoad_Stages(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `hooks:
+  - name: fmt
+    run: gofmt -l .
+    stages: [pre-commit, pre-push]
+  - name: lint
+    run: golangci-lint run
+    stages: [pre-commit, ci]
+  - name: bench
+    run: go test -bench .
+    stages: manual
+`)
+	writeConfig(t, dir, "hooks-local.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      args: [--fast]
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stage, want := range map[string]string{
+		"pre-commit": "fmt,lint",
+		"pre-push":   "fmt",
+		"ci":         "lint",
+		ManualStage:  "bench",
+	} {
+		if got := hookNames(cfg.GetHooks(stage)); got != want {
+			t.Errorf("%s hooks = %s, want %s", stage, got, want)
+		}
+	}
+	if got := strings.Join(cfg.Stages(), ","); got != "pre-commit,pre-push,ci,manual" {
+		t.Errorf("stages = %s", got)
+	}
+	if n := cfg.HookCount(); n != 3 {
+		t.Errorf("HookCount = %d, want each hook counted once", n)
+	}
+	if !IsGitStage("pre-push") || IsGitStage(ManualStage) || IsGitStage("ci") {
+		t.Error("IsGitStage should only report git hook types")
+	}
+
+	if lint := cfg.GetHooks("pre-commit")[1]; len(lint.Args) != 1 {
+		t.Errorf("hooks-local should override the pre-commit lint, got %+v", lint)
+	}
+	if lint := cfg.GetHooks("ci")[0]; len(lint.Args) != 0 {
+		t.Errorf("hooks-local should leave the ci lint alone, got %+v", lint)
+	}
+	if stage, h, ok := cfg.FindHook("bench"); !ok || stage != ManualStage || h.Run != "go test -bench ." {
+		t.Errorf("FindHook(bench) = %s, %+v, %v", stage, h, ok)
+	}
+	if o := cfg.Origins["hooks.pre-push.fmt.run"]; o.Line != 3 {
+		t.Errorf("origin of the pre-push fmt = %v, want line 3", o)
+	}
+}
+
+func TestLoad_StagesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing stages", "hooks:\n  - name: fmt\n    run: gofmt -l .\n", "hooks.yaml:2: hook fmt: hooks in a hooks list need stages"},
+		{"empty stages", "hooks:\n  - name: fmt\n    stages: []\n", "hook fmt: stages is empty"},
+		{"map form", "hooks:\n  pre-commit:\n    - name: fmt\n      stages: [pre-push]\n", "stages can only be set on hooks in a hooks list, not under pre-commit"},
+		{"template", "templates:\n  lint:\n    stages: [ci]\n", "template lint: stages can only be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfig(t, dir, "hooks.yaml", tt.content)
+			if _, _, err := Load(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
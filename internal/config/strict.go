@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkFields reports every key in node that t does not know, with its
+// line and column, so typos such as "timout:" are not silently dropped.
+func checkFields(file string, node *yaml.Node, t reflect.Type) error {
+	var errs []error
+	walkFields(file, node, t, "", &errs)
+	return errors.Join(errs...)
+}
+
+func walkFields(file string, node *yaml.Node, t reflect.Type, path string, errs *[]error) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			walkFields(file, n, t, path, errs)
+		}
+		return
+	case yaml.AliasNode:
+		walkFields(file, node.Alias, t, path, errs)
+		return
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == stringListType {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		var keys []string
+		for _, f := range knownFields(t) {
+			fields[f.key] = f.Type
+			keys = append(keys, f.key)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				// Merge keys fill in fields of the same struct.
+				walkFields(file, value, t, path, errs)
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("%s:%d:%d: unknown field %q", file, key.Line, key.Column, key.Value)
+				if path != "" {
+					msg += " in " + path
+				}
+				if s := closest(key.Value, keys); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				*errs = append(*errs, errors.New(msg))
+				continue
+			}
+			walkFields(file, value, ft, joinPath(path, key.Value), errs)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkFields(file, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), errs)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			walkFields(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// closest returns the candidate within two edits of s, if any.
+func closest(s string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(s), c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
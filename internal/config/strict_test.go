@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) {
+	t.Helper()
+	//nolint:gosec // G306: Test file, permissions not a concern
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_UnknownFieldYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `hooks:
+  pre-commit:
+    - name: lint
+      run: "true"
+      timout: 5m
+      afer: fmt
+`)
+
+	_, _, err := Load(dir)
+	if err == nil {
+		t.Fatal("expected error for unknown fields")
+	}
+	for _, want := range []string{
+		`hooks.yaml:5:7: unknown field "timout" in hooks.pre-commit[0] (did you mean "timeout"?)`,
+		`hooks.yaml:6:7: unknown field "afer" in hooks.pre-commit[0] (did you mean "after"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_UnknownFieldJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.json", `{
+  "hooks": {
+    "pre-commit": [{"name": "lint", "run": "true"}]
+  },
+  "paralel": true
+}`)
+
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `hooks.json:5:3: unknown field "paralel" (did you mean "parallel"?)`) {
+		t.Errorf("expected positioned error, got %v", err)
+	}
+}
+
+func TestLoad_UnknownFieldNested(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `policies:
+  localPolicies:
+    - name: p
+      rules:
+        max_files: 3
+`)
+
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `unknown field "max_files" in policies.localPolicies[0].rules`) {
+		t.Errorf("expected nested path in error, got %v", err)
+	}
+}
+
+func TestLoad_UnknownFieldLocalOverride(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", "hooks:\n  pre-commit:\n    - name: lint\n      run: \"true\"\n")
+	writeConfig(t, dir, "hooks-local.yaml", "exclude_tag: [slow]\n")
+
+	_, _, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `hooks-local.yaml:1:1: unknown field "exclude_tag"`) {
+		t.Errorf("expected error from the local override, got %v", err)
+	}
+}
+
+func TestLoad_EmptyYAML(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", "")
+
+	if _, _, err := Load(dir); err != nil {
+		t.Errorf("empty config should load, got %v", err)
+	}
+}
+
+func TestLoad_YAMLAnchors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", `hooks:
+  pre-commit:
+    - &base
+      name: lint
+      run: "true"
+    - <<: *base
+      name: lint2
+`)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatalf("anchors and merge keys should load: %v", err)
+	}
+	if hooks := cfg.GetHooks("pre-commit"); len(hooks) != 2 || hooks[1].Run != "true" {
+		t.Errorf("unexpected hooks: %+v", hooks)
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+)
+
+// needsRefPattern matches {{ needs.<hook>.outputs.<key> }} and
+// {{ needs.<hook>.artifacts }}. Hook names of nested configs may contain
+// dots, so the name is matched lazily.
+var needsRefPattern = regexp.MustCompile(`\{\{\s*needs\.([^\s{}]+?)\.(?:outputs\.([A-Za-z0-9_-]+)|(artifacts))\s*\}\}`)
+
+// NeedsRef is a reference from one hook to an output or the artifacts of
+// a hook it depends on.
+type NeedsRef struct {
+	Hook      string
+	Output    string
+	Artifacts bool
+}
+
+// ExpandNeeds replaces every needs reference in s with the value returned
+// for it.
+func ExpandNeeds(s string, value func(NeedsRef) string) string {
+	return needsRefPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return value(parseNeedsRef(match))
+	})
+}
+
+// NeedsRefs returns the needs references in the hook's command, arguments
+// and environment.
+func (h Hook) NeedsRefs() []NeedsRef {
+	fields := []string{h.Run}
+	fields = append(fields, h.Args...)
+	fields = append(fields, h.FixArgs...)
+	keys := make([]string, 0, len(h.Env))
+	for k := range h.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, h.Env[k])
+	}
+
+	var refs []NeedsRef
+	for _, f := range fields {
+		for _, match := range needsRefPattern.FindAllString(f, -1) {
+			refs = append(refs, parseNeedsRef(match))
+		}
+	}
+	return refs
+}
+
+// renameNeeds replaces the hook name in every needs reference in s.
+func renameNeeds(s string, rename func(string) string) string {
+	return needsRefPattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := needsRefPattern.FindStringSubmatchIndex(match)
+		return match[:m[2]] + rename(match[m[2]:m[3]]) + match[m[3]:]
+	})
+}
+
+func parseNeedsRef(match string) NeedsRef {
+	m := needsRefPattern.FindStringSubmatch(match)
+	return NeedsRef{Hook: m[1], Output: m[2], Artifacts: m[3] != ""}
+}
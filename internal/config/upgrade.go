@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ashavijit/hookrunner/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config version this hookrunner reads without
+// upgrading. Files without a version field are version 1.
+const CurrentVersion = 2
+
+// migration upgrades a config file from version from to from+1 by editing
+// its root mapping in place. It returns a note for each change it made.
+type migration struct {
+	from  int
+	apply func(root *yaml.Node) []string
+}
+
+var migrations = []migration{
+	{from: 1, apply: localPoliciesList},
+}
+
+// upgrade checks that the config file at path can be read by this
+// hookrunner and migrates root, its root mapping, to CurrentVersion. It
+// returns what the migrations changed.
+func upgrade(path string, root *yaml.Node) ([]string, error) {
+	v := 1
+	if _, value := mappingValue(root, "version"); value != nil {
+		n, err := strconv.Atoi(value.Value)
+		if err != nil || value.Kind != yaml.ScalarNode || n < 1 {
+			return nil, fmt.Errorf("%s:%d: version must be a positive integer, got %q", path, value.Line, value.Value)
+		}
+		v = n
+	}
+	if _, value := mappingValue(root, "min_hookrunner_version"); value != nil {
+		ok, err := version.AtLeast(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: min_hookrunner_version: %w", path, value.Line, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s requires hookrunner %s or newer, but this is %s; see https://github.com/ashavijit/hookrunner#installation to upgrade", path, value.Value, version.Version)
+		}
+	}
+	if v > CurrentVersion {
+		return nil, fmt.Errorf("%s is config version %d, but this hookrunner reads up to version %d; upgrade hookrunner to use it", path, v, CurrentVersion)
+	}
+
+	var notes []string
+	for _, m := range migrations {
+		if m.from >= v {
+			notes = append(notes, m.apply(root)...)
+		}
+	}
+	return notes, nil
+}
+
+// Upgrade rewrites data, the content of the config file at path, in the
+// current config version and returns the result with what changed. YAML
+// comments are kept; JSON is rewritten with its keys sorted. A file that
+// is already current is returned unchanged, with no notes.
+func Upgrade(path string, data []byte) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	root := rootMapping(&doc)
+	if root == nil {
+		return data, nil, nil
+	}
+	notes, err := upgrade(path, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if setVersion(root) {
+		notes = append(notes, fmt.Sprintf("set version: %d", CurrentVersion))
+	}
+	if len(notes) == 0 {
+		return data, nil, nil
+	}
+
+	var buf bytes.Buffer
+	if filepath.Ext(path) == ".json" {
+		var v interface{}
+		if err := root.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		buf.Write(out)
+		buf.WriteString("\n")
+		return buf.Bytes(), notes, nil
+	}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), notes, nil
+}
+
+// setVersion sets the version field of root to CurrentVersion, adding it
+// as the first key when missing, and reports whether it changed.
+func setVersion(root *yaml.Node) bool {
+	want := strconv.Itoa(CurrentVersion)
+	if _, value := mappingValue(root, "version"); value != nil {
+		if value.Value == want {
+			return false
+		}
+		value.Value, value.Tag, value.Style = want, "!!int", 0
+		return true
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		// Keep a comment heading the file above the new key.
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: want}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+	return true
+}
+
+// mappingValue returns the key and value nodes of key in the mapping n,
+// or nils.
+func mappingValue(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// localPoliciesList turns policies.localPolicies written as a mapping of
+// name to rules, as 'hookrunner init' used to, into a list of named
+// policies, and drops commit_message.min_length, which was never a rule.
+func localPoliciesList(root *yaml.Node) []string {
+	_, policies := mappingValue(root, "policies")
+	_, local := mappingValue(policies, "localPolicies")
+	if local == nil {
+		return nil
+	}
+
+	var notes []string
+	if local.Kind == yaml.MappingNode {
+		items := make([]*yaml.Node, 0, len(local.Content)/2)
+		for i := 0; i+1 < len(local.Content); i += 2 {
+			name, body := local.Content[i], local.Content[i+1]
+			item := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: name.Line, Column: name.Column, HeadComment: name.HeadComment}
+			nameKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name", Line: name.Line, Column: name.Column}
+			name.HeadComment = ""
+			item.Content = append(item.Content, nameKey, name)
+			if _, rules := mappingValue(body, "rules"); rules != nil {
+				// Already a policy body, only missing its name.
+				item.Content = append(item.Content, body.Content...)
+			} else {
+				rulesKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "rules", Line: name.Line, Column: name.Column}
+				item.Content = append(item.Content, rulesKey, body)
+			}
+			items = append(items, item)
+		}
+		local.Kind, local.Tag, local.Style, local.Content = yaml.SequenceNode, "!!seq", 0, items
+		notes = append(notes, "policies.localPolicies: converted the mapping of names to a list of named policies")
+	}
+
+	for i, item := range local.Content {
+		_, rules := mappingValue(item, "rules")
+		_, msg := mappingValue(rules, "commit_message")
+		if msg == nil || msg.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(msg.Content); j += 2 {
+			if msg.Content[j].Value == "min_length" {
+				notes = append(notes, fmt.Sprintf("policies.localPolicies.%s: removed commit_message.min_length %s, which is not a rule; use a regex such as \"^.{%s,}\" instead", ItemKey(item, i), msg.Content[j+1].Value, msg.Content[j+1].Value))
+				msg.Content = append(msg.Content[:j], msg.Content[j+2:]...)
+				break
+			}
+		}
+	}
+	return notes
+}
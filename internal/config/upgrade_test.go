@@ -0,0 +1,119 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/version"
+)
+
+const oldLocalPolicies = `# Team hooks
+policies:
+  localPolicies:
+    # Conventional commits
+    commit-style:
+      commit_message:
+        regex: "^(feat|fix):"
+        min_length: 10
+hooks:
+  pre-commit:
+    - name: fmt
+      run: gofmt -l .
+`
+
+func TestUpgrade(t *testing.T) {
+	out, notes, err := Upgrade("hooks.yaml", []byte(oldLocalPolicies))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 {
+		t.Errorf("expected 3 notes, got %q", notes)
+	}
+	for _, want := range []string{
+		"# Team hooks\nversion: 2\n",
+		"    # Conventional commits\n    - name: commit-style\n      rules:\n        commit_message:\n          regex: \"^(feat|fix):\"\n",
+		"    - name: fmt\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "min_length") {
+		t.Errorf("min_length should be removed:\n%s", out)
+	}
+
+	again, notes, err := Upgrade("hooks.yaml", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 0 || string(again) != string(out) {
+		t.Errorf("upgrading a current file should change nothing, got %q", notes)
+	}
+}
+
+func TestUpgrade_JSON(t *testing.T) {
+	out, _, err := Upgrade("hooks.json", []byte(`{"policies": {"localPolicies": {"size": {"max_file_size_kb": 10}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := parseConfig("hooks.json", out)
+	if err != nil {
+		t.Fatalf("upgraded JSON does not parse: %v\n%s", err, out)
+	}
+	if cfg.Version != CurrentVersion || cfg.Policies.LocalPolicies[0].Rules.MaxFileSizeKB != 10 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoad_UpgradesOldConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "hooks.yaml", oldLocalPolicies)
+
+	cfg, _, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := cfg.Policies.LocalPolicies
+	if len(lp) != 1 || lp[0].Name != "commit-style" || lp[0].Rules.CommitMessage.Regex != "^(feat|fix):" {
+		t.Errorf("unexpected local policies %+v", lp)
+	}
+	if len(cfg.Upgrades) != 2 || !strings.Contains(cfg.Upgrades[0], "hooks.yaml: policies.localPolicies") {
+		t.Errorf("unexpected upgrades %q", cfg.Upgrades)
+	}
+	if o := cfg.Origins["policies.localPolicies.commit-style"]; o.Line != 5 {
+		t.Errorf("local policy origin = %v, want line 5", o)
+	}
+}
+
+func TestLoad_Version(t *testing.T) {
+	old := version.Version
+	defer func() { version.Version = old }()
+	version.Version = "0.19.0"
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"current", "version: 2\nmin_hookrunner_version: 0.19.0\n", ""},
+		{"newer", "version: 3\n", "config version 3, but this hookrunner reads up to version 2"},
+		{"invalid", "version: two\n", "version must be a positive integer"},
+		{"too old", "min_hookrunner_version: v0.20.0\n", "requires hookrunner v0.20.0 or newer, but this is 0.19.0"},
+		// The version check comes first, so fields a newer release added
+		// are not reported as unknown.
+		{"unknown fields", "min_hookrunner_version: 1.0.0\nstages: [manual]\n", "requires hookrunner 1.0.0 or newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeConfig(t, dir, "hooks.yaml", tt.content)
+			_, _, err := Load(dir)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
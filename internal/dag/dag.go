@@ -1,6 +1,10 @@
 package dag
 
 import (
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/ashavijit/hookrunner/internal/config"
 )
 
@@ -8,6 +12,9 @@ type Node struct {
 	Hook     config.Hook
 	Children []*Node
 	InDegree int
+	// Index is the hook's position in the config, used to keep ordering
+	// stable between runs.
+	Index int
 }
 
 type Graph struct {
@@ -23,17 +30,20 @@ func NewGraph() *Graph {
 func BuildGraph(hooks []config.Hook) *Graph {
 	g := NewGraph()
 
-	for _, h := range hooks {
+	for i, h := range hooks {
 		g.Nodes[h.Name] = &Node{
 			Hook:     h,
 			Children: make([]*Node, 0),
 			InDegree: 0,
+			Index:    i,
 		}
 	}
 
+	// Both after (ordering only) and needs (hard dependency) order the
+	// graph. Unknown names are rejected when the config is loaded.
 	for _, h := range hooks {
-		if h.After != "" {
-			if parent, exists := g.Nodes[h.After]; exists {
+		for _, dep := range h.Dependencies() {
+			if parent, exists := g.Nodes[dep]; exists {
 				child := g.Nodes[h.Name]
 				parent.Children = append(parent.Children, child)
 				child.InDegree++
@@ -44,21 +54,46 @@ func BuildGraph(hooks []config.Hook) *Graph {
 	return g
 }
 
+// Less reports whether a should come before b: higher priority first,
+// then config order, then name.
+func Less(a, b *Node) bool {
+	if a.Hook.Priority != b.Hook.Priority {
+		return a.Hook.Priority > b.Hook.Priority
+	}
+	if a.Index != b.Index {
+		return a.Index < b.Index
+	}
+	return a.Hook.Name < b.Hook.Name
+}
+
+// Sorted returns the nodes in the order given by Less.
+func (g *Graph) Sorted() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return Less(nodes[i], nodes[j]) })
+	return nodes
+}
+
+// TopologicalSort groups the hooks into levels, each depending only on
+// earlier ones. Within a level hooks are ordered by Less.
 func (g *Graph) TopologicalSort() [][]*Node {
 	var levels [][]*Node
 	inDegree := make(map[string]int)
 
-	for name, node := range g.Nodes {
-		inDegree[name] = node.InDegree
+	nodes := g.Sorted()
+	for _, node := range nodes {
+		inDegree[node.Hook.Name] = node.InDegree
 	}
 
 	for {
 		var currentLevel []*Node
 
-		for name, degree := range inDegree {
-			if degree == 0 {
-				currentLevel = append(currentLevel, g.Nodes[name])
-				inDegree[name] = -1
+		for _, node := range nodes {
+			if inDegree[node.Hook.Name] == 0 {
+				currentLevel = append(currentLevel, node)
+				inDegree[node.Hook.Name] = -1
 			}
 		}
 
@@ -79,38 +114,73 @@ func (g *Graph) TopologicalSort() [][]*Node {
 }
 
 func (g *Graph) HasCycle() bool {
+	return g.FindCycle() != nil
+}
+
+// FindCycle returns the hooks forming a dependency cycle, starting and
+// ending with the same hook (a, b, c, a), or nil if there is none. The
+// same cycle is reported on every run.
+func (g *Graph) FindCycle() []string {
 	visited := make(map[string]bool)
-	recStack := make(map[string]bool)
+	onStack := make(map[string]bool)
+	var stack []string
 
-	var hasCycle func(name string) bool
-	hasCycle = func(name string) bool {
+	var visit func(node *Node) []string
+	visit = func(node *Node) []string {
+		name := node.Hook.Name
 		visited[name] = true
-		recStack[name] = true
-
-		node := g.Nodes[name]
-		for _, child := range node.Children {
-			if !visited[child.Hook.Name] {
-				if hasCycle(child.Hook.Name) {
-					return true
+		onStack[name] = true
+		stack = append(stack, name)
+
+		children := append([]*Node(nil), node.Children...)
+		sort.SliceStable(children, func(i, j int) bool { return Less(children[i], children[j]) })
+		for _, child := range children {
+			childName := child.Hook.Name
+			if onStack[childName] {
+				for i, n := range stack {
+					if n == childName {
+						return append(append([]string(nil), stack[i:]...), childName)
+					}
+				}
+			}
+			if !visited[childName] {
+				if cycle := visit(child); cycle != nil {
+					return cycle
 				}
-			} else if recStack[child.Hook.Name] {
-				return true
 			}
 		}
 
-		recStack[name] = false
-		return false
+		onStack[name] = false
+		stack = stack[:len(stack)-1]
+		return nil
 	}
 
-	for name := range g.Nodes {
-		if !visited[name] {
-			if hasCycle(name) {
-				return true
+	for _, node := range g.Sorted() {
+		if !visited[node.Hook.Name] {
+			if cycle := visit(node); cycle != nil {
+				return cycle
 			}
 		}
 	}
 
-	return false
+	return nil
+}
+
+// CycleError reports a dependency cycle between hooks.
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "circular dependency: " + strings.Join(e.Path, " -> ")
+}
+
+// Validate returns a *CycleError if the graph has a cycle.
+func (g *Graph) Validate() error {
+	if cycle := g.FindCycle(); cycle != nil {
+		return &CycleError{Path: cycle}
+	}
+	return nil
 }
 
 func (g *Graph) GetExecutionPlan() [][]config.Hook {
@@ -126,3 +196,31 @@ func (g *Graph) GetExecutionPlan() [][]config.Hook {
 
 	return plan
 }
+
+// CriticalPath returns, for each hook, the estimated time from starting it
+// to finishing the longest chain of hooks that depend on it. Scheduling
+// the hook with the largest value first keeps long chains from finishing
+// last. The graph must not contain a cycle.
+func (g *Graph) CriticalPath(estimate func(config.Hook) time.Duration) map[string]time.Duration {
+	paths := make(map[string]time.Duration, len(g.Nodes))
+
+	var visit func(n *Node) time.Duration
+	visit = func(n *Node) time.Duration {
+		if d, ok := paths[n.Hook.Name]; ok {
+			return d
+		}
+		var longest time.Duration
+		for _, child := range n.Children {
+			if d := visit(child); d > longest {
+				longest = d
+			}
+		}
+		paths[n.Hook.Name] = estimate(n.Hook) + longest
+		return paths[n.Hook.Name]
+	}
+
+	for _, n := range g.Sorted() {
+		visit(n)
+	}
+	return paths
+}
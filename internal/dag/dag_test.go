@@ -1,7 +1,10 @@
 package dag
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ashavijit/hookrunner/internal/config"
 )
@@ -9,8 +12,8 @@ import (
 func TestBuildGraph(t *testing.T) {
 	hooks := []config.Hook{
 		{Name: "format"},
-		{Name: "lint", After: "format"},
-		{Name: "test", After: "lint"},
+		{Name: "lint", After: []string{"format"}},
+		{Name: "test", After: []string{"lint"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -35,8 +38,8 @@ func TestBuildGraph(t *testing.T) {
 func TestTopologicalSort(t *testing.T) {
 	hooks := []config.Hook{
 		{Name: "format"},
-		{Name: "lint", After: "format"},
-		{Name: "test", After: "lint"},
+		{Name: "lint", After: []string{"format"}},
+		{Name: "test", After: []string{"lint"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -64,7 +67,7 @@ func TestTopologicalSort_Parallel(t *testing.T) {
 		{Name: "format"},
 		{Name: "lint"},
 		{Name: "security"},
-		{Name: "test", After: "lint"},
+		{Name: "test", After: []string{"lint"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -86,8 +89,8 @@ func TestTopologicalSort_Parallel(t *testing.T) {
 func TestHasCycle_NoCycle(t *testing.T) {
 	hooks := []config.Hook{
 		{Name: "format"},
-		{Name: "lint", After: "format"},
-		{Name: "test", After: "lint"},
+		{Name: "lint", After: []string{"format"}},
+		{Name: "test", After: []string{"lint"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -116,8 +119,8 @@ func TestHasCycle_HasCycle(t *testing.T) {
 func TestGetExecutionPlan(t *testing.T) {
 	hooks := []config.Hook{
 		{Name: "format"},
-		{Name: "lint", After: "format"},
-		{Name: "test", After: "lint"},
+		{Name: "lint", After: []string{"format"}},
+		{Name: "test", After: []string{"lint"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -144,9 +147,9 @@ func TestGetExecutionPlan_Complex(t *testing.T) {
 	hooks := []config.Hook{
 		{Name: "a"},
 		{Name: "b"},
-		{Name: "c", After: "a"},
-		{Name: "d", After: "a"},
-		{Name: "e", After: "c"},
+		{Name: "c", After: []string{"a"}},
+		{Name: "d", After: []string{"a"}},
+		{Name: "e", After: []string{"c"}},
 	}
 
 	g := BuildGraph(hooks)
@@ -177,3 +180,130 @@ func TestNewGraph(t *testing.T) {
 		t.Error("Nodes should be empty")
 	}
 }
+
+func TestBuildGraph_MultipleDependencies(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "format"},
+		{Name: "lint"},
+		{Name: "test", After: []string{"format"}, Needs: []string{"lint", "format"}},
+	}
+
+	g := BuildGraph(hooks)
+
+	if g.Nodes["test"].InDegree != 2 {
+		t.Errorf("expected test in-degree 2, got %d", g.Nodes["test"].InDegree)
+	}
+
+	levels := g.GetExecutionPlan()
+	if len(levels) != 2 || len(levels[0]) != 2 || len(levels[1]) != 1 {
+		t.Errorf("expected levels [[format lint] [test]], got %v", levels)
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "lint"},
+		{Name: "build"},
+		{Name: "docs", After: []string{"lint"}},
+		{Name: "test", Needs: []string{"build"}},
+	}
+	durations := map[string]time.Duration{
+		"lint":  2 * time.Second,
+		"build": 10 * time.Second,
+		"docs":  time.Second,
+		"test":  90 * time.Second,
+	}
+
+	paths := BuildGraph(hooks).CriticalPath(func(h config.Hook) time.Duration {
+		return durations[h.Name]
+	})
+
+	if paths["build"] != 100*time.Second {
+		t.Errorf("expected build path 100s, got %v", paths["build"])
+	}
+	if paths["lint"] != 3*time.Second {
+		t.Errorf("expected lint path 3s, got %v", paths["lint"])
+	}
+	if paths["test"] != 90*time.Second {
+		t.Errorf("expected test path 90s, got %v", paths["test"])
+	}
+}
+
+func TestTopologicalSort_ConfigOrder(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "zeta"},
+		{Name: "alpha"},
+		{Name: "mid"},
+		{Name: "last", After: []string{"zeta"}},
+	}
+
+	for i := 0; i < 20; i++ {
+		plan := BuildGraph(hooks).GetExecutionPlan()
+		if got := names(plan[0]); got != "zeta,alpha,mid" {
+			t.Fatalf("expected config order, got %s", got)
+		}
+	}
+}
+
+func TestTopologicalSort_Priority(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "a"},
+		{Name: "b", Priority: 10},
+		{Name: "c", Priority: -1},
+		{Name: "d"},
+	}
+
+	plan := BuildGraph(hooks).GetExecutionPlan()
+	if got := names(plan[0]); got != "b,a,d,c" {
+		t.Errorf("expected priority then config order, got %s", got)
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	hooks := []config.Hook{
+		{Name: "ok"},
+		{Name: "a", After: []string{"c"}},
+		{Name: "b", After: []string{"a"}},
+		{Name: "c", Needs: []string{"b"}},
+	}
+
+	g := BuildGraph(hooks)
+	cycle := g.FindCycle()
+	if got := strings.Join(cycle, " -> "); got != "a -> b -> c -> a" {
+		t.Errorf("expected a -> b -> c -> a, got %q", got)
+	}
+
+	err := g.Validate()
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected CycleError, got %v", err)
+	}
+	if err.Error() != "circular dependency: a -> b -> c -> a" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFindCycle_SelfDependency(t *testing.T) {
+	g := BuildGraph([]config.Hook{{Name: "a", After: []string{"a"}}})
+	if got := strings.Join(g.FindCycle(), " -> "); got != "a -> a" {
+		t.Errorf("expected a -> a, got %q", got)
+	}
+}
+
+func TestFindCycle_NoCycle(t *testing.T) {
+	g := BuildGraph([]config.Hook{{Name: "a"}, {Name: "b", After: []string{"a"}}})
+	if cycle := g.FindCycle(); cycle != nil {
+		t.Errorf("expected no cycle, got %v", cycle)
+	}
+	if err := g.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func names(hooks []config.Hook) string {
+	out := make([]string, len(hooks))
+	for i, h := range hooks {
+		out[i] = h.Name
+	}
+	return strings.Join(out, ",")
+}
@@ -0,0 +1,205 @@
+package dag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Edge kinds, matching the hook fields that create them.
+const (
+	EdgeNeeds = "needs"
+	EdgeAfter = "after"
+)
+
+// Edge is a dependency from a hook to one that depends on it.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Annotation is run data shown next to a hook in an exported graph, such
+// as the duration and status of its last run.
+type Annotation struct {
+	Duration time.Duration
+	Status   string
+}
+
+// Edges returns the dependencies of the graph in a stable order. A hook
+// listed in both needs and after gives a single needs edge.
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, node := range g.Sorted() {
+		h := node.Hook
+		needs := make(map[string]bool, len(h.Needs))
+		for _, n := range h.Needs {
+			needs[n] = true
+		}
+		for _, dep := range h.Dependencies() {
+			if _, ok := g.Nodes[dep]; !ok {
+				continue
+			}
+			kind := EdgeAfter
+			if needs[dep] {
+				kind = EdgeNeeds
+			}
+			edges = append(edges, Edge{From: dep, To: h.Name, Kind: kind})
+		}
+	}
+	return edges
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Hard dependencies are
+// solid edges, ordering-only ones dashed.
+func (g *Graph) WriteDOT(w io.Writer, name string, notes map[string]Annotation) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	for _, node := range g.Sorted() {
+		label := node.Hook.Name
+		attrs := ""
+		if note, ok := notes[node.Hook.Name]; ok {
+			label += "\n" + note.String()
+			if c := statusColor(note.Status); c != "" {
+				attrs = fmt.Sprintf(", color=%s", c)
+			}
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(node.Hook.Name), dotQuote(label), attrs)
+	}
+
+	for _, e := range g.Edges() {
+		style := ""
+		if e.Kind == EdgeAfter {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", dotQuote(e.From), dotQuote(e.To), style)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Hard dependencies
+// are solid arrows, ordering-only ones dotted.
+func (g *Graph) WriteMermaid(w io.Writer, notes map[string]Annotation) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	// Hook names may contain characters Mermaid does not allow in node
+	// ids, so nodes get generated ids and the name as their label.
+	ids := make(map[string]string, len(g.Nodes))
+	classes := make(map[string][]string)
+	for i, node := range g.Sorted() {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.Hook.Name] = id
+		label := mermaidEscape(node.Hook.Name)
+		if note, ok := notes[node.Hook.Name]; ok {
+			label += "<br/>" + mermaidEscape(note.String())
+			if note.Status != "" {
+				classes[note.Status] = append(classes[note.Status], id)
+			}
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", id, label)
+	}
+
+	for _, e := range g.Edges() {
+		arrow := "-->"
+		if e.Kind == EdgeAfter {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[e.From], arrow, ids[e.To])
+	}
+
+	for _, status := range []string{"passed", "failed", "skipped", "blocked"} {
+		if len(classes[status]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s stroke:%s\n", status, statusColor(status))
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[status], ","), status)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonNode struct {
+	Name       string `json:"name"`
+	Level      int    `json:"level"`
+	Priority   int    `json:"priority,omitempty"`
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+	Status     string `json:"status,omitempty"`
+}
+
+type jsonGraph struct {
+	HookType string     `json:"hook_type"`
+	Nodes    []jsonNode `json:"nodes"`
+	Edges    []Edge     `json:"edges"`
+}
+
+// WriteJSON writes the nodes, with their level in the execution plan, and
+// the edges of the graph as JSON.
+func (g *Graph) WriteJSON(w io.Writer, hookType string, notes map[string]Annotation) error {
+	out := jsonGraph{HookType: hookType, Nodes: []jsonNode{}, Edges: g.Edges()}
+	if out.Edges == nil {
+		out.Edges = []Edge{}
+	}
+
+	for level, nodes := range g.TopologicalSort() {
+		for _, node := range nodes {
+			n := jsonNode{Name: node.Hook.Name, Level: level + 1, Priority: node.Hook.Priority}
+			if note, ok := notes[node.Hook.Name]; ok {
+				if note.Duration > 0 {
+					ms := note.Duration.Milliseconds()
+					n.DurationMs = &ms
+				}
+				n.Status = note.Status
+			}
+			out.Nodes = append(out.Nodes, n)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (a Annotation) String() string {
+	var parts []string
+	if a.Duration > 0 {
+		parts = append(parts, a.Duration.Round(time.Millisecond).String())
+	}
+	if a.Status != "" {
+		parts = append(parts, a.Status)
+	}
+	return strings.Join(parts, " ")
+}
+
+func statusColor(status string) string {
+	switch status {
+	case "passed":
+		return "green"
+	case "failed":
+		return "red"
+	case "skipped":
+		return "gray"
+	case "blocked":
+		return "orange"
+	}
+	return ""
+}
+
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func mermaidEscape(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	return r.Replace(s)
+}
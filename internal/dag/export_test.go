@@ -0,0 +1,107 @@
+package dag
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+)
+
+func exportGraph() *Graph {
+	return BuildGraph([]config.Hook{
+		{Name: "format"},
+		{Name: "lint", After: []string{"format"}},
+		{Name: "go test", Needs: []string{"lint"}, After: []string{"format"}},
+	})
+}
+
+func TestEdges(t *testing.T) {
+	edges := exportGraph().Edges()
+	want := []Edge{
+		{From: "format", To: "lint", Kind: EdgeAfter},
+		{From: "lint", To: "go test", Kind: EdgeNeeds},
+		{From: "format", To: "go test", Kind: EdgeAfter},
+	}
+	if len(edges) != len(want) {
+		t.Fatalf("expected %d edges, got %v", len(want), edges)
+	}
+	for i := range want {
+		if edges[i] != want[i] {
+			t.Errorf("edge %d: expected %+v, got %+v", i, want[i], edges[i])
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	notes := map[string]Annotation{"lint": {Duration: 1500 * time.Millisecond, Status: "failed"}}
+	if err := exportGraph().WriteDOT(&buf, "pre-commit", notes); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`digraph "pre-commit" {`,
+		`"lint" [label="lint\n1.5s failed", color=red];`,
+		`"format" -> "lint" [style=dashed];`,
+		`"lint" -> "go test";`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := exportGraph().WriteMermaid(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `flowchart LR
+  n0["format"]
+  n1["lint"]
+  n2["go test"]
+  n0 -.-> n1
+  n1 --> n2
+  n0 -.-> n2
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	notes := map[string]Annotation{"format": {Duration: 20 * time.Millisecond, Status: "passed"}}
+	if err := exportGraph().WriteJSON(&buf, "pre-commit", notes); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		HookType string `json:"hook_type"`
+		Nodes    []struct {
+			Name       string `json:"name"`
+			Level      int    `json:"level"`
+			DurationMs *int64 `json:"duration_ms"`
+			Status     string `json:"status"`
+		} `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.HookType != "pre-commit" || len(out.Nodes) != 3 || len(out.Edges) != 3 {
+		t.Fatalf("unexpected graph: %s", buf.String())
+	}
+	if n := out.Nodes[0]; n.Name != "format" || n.Level != 1 || n.DurationMs == nil || *n.DurationMs != 20 || n.Status != "passed" {
+		t.Errorf("unexpected first node: %+v", n)
+	}
+	if n := out.Nodes[2]; n.Name != "go test" || n.Level != 3 || n.DurationMs != nil {
+		t.Errorf("unexpected last node: %+v", n)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -14,19 +15,28 @@ import (
 	"github.com/ashavijit/hookrunner/internal/cache"
 	"github.com/ashavijit/hookrunner/internal/config"
 	"github.com/ashavijit/hookrunner/internal/dag"
+	"github.com/ashavijit/hookrunner/internal/git"
+	"github.com/ashavijit/hookrunner/internal/history"
+	"github.com/ashavijit/hookrunner/internal/lock"
 	luapkg "github.com/ashavijit/hookrunner/internal/lua"
 	"github.com/ashavijit/hookrunner/internal/policy"
 	"github.com/ashavijit/hookrunner/internal/tool"
 	"github.com/fatih/color"
 )
 
+// Result is the outcome of one hook. Blocked is set, together with
+// Skipped, when a hook listed in its needs failed or did not run. Outputs
+// and Artifacts are what a successful hook passes to its dependents.
 type Result struct {
-	Name     string
-	Success  bool
-	Skipped  bool
-	Duration time.Duration
-	Output   string
-	Error    error
+	Name      string
+	Success   bool
+	Skipped   bool
+	Blocked   bool
+	Duration  time.Duration
+	Output    string
+	Error     error
+	Outputs   map[string]string
+	Artifacts []string
 }
 
 type Options struct {
@@ -40,6 +50,10 @@ type Options struct {
 	UseCache   bool
 	SkipHooks  []string
 	CommitMsg  string
+	// Jobs caps how many hooks run at once; zero means no limit.
+	Jobs int
+	// Hooks limits a run to the named hooks and the hooks they need.
+	Hooks []string
 }
 
 type Executor struct {
@@ -48,6 +62,15 @@ type Executor struct {
 	workDir string
 	opts    Options
 	cache   *cache.Cache
+	source  policy.Source
+	changes git.ChangeSet
+	realDir string
+	// maxParallel is the max_parallel_hooks policy, set by CheckPolicies.
+	maxParallel int
+	lock        *lock.Lock
+	// varErrs are the variable errors of the hooks in the current run, by
+	// hook name, reported when those hooks run.
+	varErrs map[string]error
 }
 
 func New(cfg *config.Config, toolMgr *tool.Manager, workDir string) *Executor {
@@ -64,18 +87,48 @@ func (e *Executor) SetOptions(opts Options) {
 	e.opts = opts
 }
 
+// SetSource sets where policies read file contents from, such as the git
+// index for staged runs. By default files are read from the work dir.
+func (e *Executor) SetSource(src policy.Source) {
+	e.source = src
+}
+
+// SetRealDir makes hook output refer to realDir wherever it mentions the
+// work dir, so errors from a clean-room run point at the real files.
+func (e *Executor) SetRealDir(realDir string) {
+	e.realDir = realDir
+}
+
+// SetChanges gives policies the full change set, including deleted and
+// renamed files. Without it the files passed to CheckPolicies are treated
+// as modified.
+func (e *Executor) SetChanges(changes git.ChangeSet) {
+	e.changes = changes
+}
+
+// SetLock checks tool downloads and remote policies against the sums in
+// hooks.lock.
+func (e *Executor) SetLock(l *lock.Lock) {
+	e.lock = l
+}
+
 func (e *Executor) Run(hookType string, files []string, allFiles bool) []Result {
 	hooks := e.config.GetHooks(hookType)
+	if len(e.opts.Hooks) > 0 {
+		hooks = withNeeds(hooks, e.opts.Hooks)
+	}
 	if len(hooks) == 0 {
 		return nil
 	}
 
+	hooks, e.varErrs = e.interpolate(hookType, hooks)
+
 	graph := dag.BuildGraph(hooks)
-	if graph.HasCycle() {
+	if err := graph.Validate(); err != nil {
 		return []Result{{
 			Name:    "dag",
 			Success: false,
-			Error:   fmt.Errorf("circular dependency detected in hook graph"),
+			Error:   err,
 		}}
 	}
 
@@ -86,43 +139,144 @@ func (e *Executor) Run(hookType string, files []string, allFiles bool) []Result
 		return e.dryRun(executionPlan, files, allFiles)
 	}
 
-	var results []Result
-	failed := false
-	totalHooks := 0
-	for _, batch := range executionPlan {
-		totalHooks += len(batch)
+	hist := history.Load(e.stateDir())
+	results := e.schedule(hookType, hooks, graph, files, allFiles, e.parallelLimit(), hist)
+	recordHistory(hist, hookType, results)
+
+	return results
+}
+
+// vars returns the values of the built-in config variables for a run of
+// hookType in the work dir.
+func (e *Executor) vars(hookType string) config.Vars {
+	return config.Vars{
+		RepoRoot:   e.workDir,
+		HookType:   hookType,
+		ScriptsDir: e.scriptsDir(),
+		CacheDir:   e.toolMgr.CacheDir,
 	}
+}
 
-	hookIndex := 0
-	for _, batch := range executionPlan {
-		if failed && e.opts.FailFast {
-			break
+// interpolate expands ${...} variables in the hooks' commands, arguments,
+// env and root. Hooks that refer to undefined variables are returned as
+// they are, with their error by name, so that they fail only if they run.
+func (e *Executor) interpolate(hookType string, hooks []config.Hook) ([]config.Hook, map[string]error) {
+	vars := e.vars(hookType)
+	expanded := make([]config.Hook, len(hooks))
+	errs := make(map[string]error)
+	for i, h := range hooks {
+		var err error
+		if expanded[i], err = h.Interpolate(vars); err != nil {
+			errs[h.Name] = fmt.Errorf("hook %q: %w", h.Name, err)
 		}
+	}
+	return expanded, errs
+}
 
-		// Show progress for parallel batch
-		if !e.opts.Quiet && len(batch) > 1 && e.opts.Verbose {
-			names := make([]string, len(batch))
-			for i, h := range batch {
-				names[i] = h.Name
-			}
-			fmt.Printf("Running in parallel: %s\n", strings.Join(names, ", "))
+// ensureTool returns the path of a tool, installing managed tools with the
+// variables in their install URLs expanded.
+func (e *Executor) ensureTool(name string) (string, error) {
+	t := e.config.GetTool(name)
+	if t == nil {
+		return e.toolMgr.EnsureTool(name, nil)
+	}
+	expanded, err := t.Interpolate(e.vars(""))
+	if err != nil {
+		return "", fmt.Errorf("tool %q: %w", name, err)
+	}
+	// Downloads hooks-local adds or changes are not in the lock; the
+	// committed ones were checked against it before the run.
+	if sum, ok := e.lockedSum(name, expanded); ok {
+		if expanded.Checksum != "" && expanded.Checksum != sum {
+			return "", fmt.Errorf("tool %q: checksum %s does not match %s", name, expanded.Checksum, lock.FileName)
 		}
+		expanded.Checksum = sum
+	}
+	return e.toolMgr.EnsureTool(name, &expanded)
+}
 
-		batchResults := e.runBatch(batch, files, allFiles)
-		results = append(results, batchResults...)
+// lockedSum returns the SHA-256 hooks.lock pins the download of the
+// expanded tool to on this platform.
+func (e *Executor) lockedSum(name string, expanded config.Tool) (string, bool) {
+	url, ok := expanded.Install[runtime.GOOS]
+	if e.lock == nil || !ok {
+		return "", false
+	}
+	return e.lock.ToolSum(name, url)
+}
 
-		for _, r := range batchResults {
-			hookIndex++
-			if !r.Success && !r.Skipped {
-				failed = true
-				if e.opts.FailFast {
-					break
-				}
-			}
+func (e *Executor) scriptsDir() string {
+	dir := e.config.ScriptsDir
+	if dir == "" {
+		dir = ".hooks"
+	}
+	return filepath.Join(e.workDir, dir)
+}
+
+// parallelLimit is the number of hooks allowed to run at once: the
+// smaller of --jobs, or the config's jobs without it, and the
+// max_parallel_hooks policy, or no limit when neither is set.
+func (e *Executor) parallelLimit() int {
+	limit := e.opts.Jobs
+	if limit <= 0 {
+		limit = e.config.Jobs
+	}
+	if e.maxParallel > 0 && (limit <= 0 || e.maxParallel < limit) {
+		limit = e.maxParallel
+	}
+	return limit
+}
+
+// stateDir is where run history is kept: the real repository for
+// clean-room runs, the work dir otherwise.
+func (e *Executor) stateDir() string {
+	if e.realDir != "" {
+		return e.realDir
+	}
+	return e.workDir
+}
+
+// withNeeds returns the hooks named in names and, transitively, the hooks
+// they need, in their config order.
+func withNeeds(hooks []config.Hook, names []string) []config.Hook {
+	byName := make(map[string]config.Hook, len(hooks))
+	for _, h := range hooks {
+		byName[h.Name] = h
+	}
+	selected := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		h, ok := byName[name]
+		if !ok || selected[name] {
+			return
+		}
+		selected[name] = true
+		for _, need := range h.Needs {
+			add(need)
 		}
 	}
+	for _, name := range names {
+		add(name)
+	}
 
-	return results
+	out := make([]config.Hook, 0, len(selected))
+	for _, h := range hooks {
+		if selected[h.Name] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// unmetNeed returns the first hook in h.Needs that failed, was skipped or
+// was itself blocked.
+func unmetNeed(h config.Hook, finished map[string]Result) (string, bool) {
+	for _, need := range h.Needs {
+		if r, ok := finished[need]; !ok || !r.Success || r.Skipped {
+			return need, true
+		}
+	}
+	return "", false
 }
 
 func (e *Executor) dryRun(executionPlan [][]config.Hook, files []string, allFiles bool) []Result {
@@ -130,8 +284,13 @@ func (e *Executor) dryRun(executionPlan [][]config.Hook, files []string, allFile
 	cyan := color.New(color.FgCyan).SprintFunc()
 
 	fmt.Println(cyan("Dry-run mode: showing hooks that would execute"))
+	if e.config.Profile != "" {
+		fmt.Printf("Profile: %s\n", e.config.Profile)
+	}
 	fmt.Println()
 
+	finished := make(map[string]Result)
+
 	level := 1
 	for _, batch := range executionPlan {
 		if len(batch) > 1 {
@@ -141,10 +300,20 @@ func (e *Executor) dryRun(executionPlan [][]config.Hook, files []string, allFile
 		}
 
 		for _, hook := range batch {
+			if need, ok := unmetNeed(hook, finished); ok {
+				fmt.Printf("  ⊘ %s (would be blocked: needs %s)\n", hook.Name, need)
+				r := Result{Name: hook.Name, Skipped: true, Blocked: true}
+				results = append(results, r)
+				finished[hook.Name] = r
+				continue
+			}
+
 			skip, reason := e.shouldSkip(hook)
 			if skip {
 				fmt.Printf("  ⊘ %s (would skip: %s)\n", hook.Name, reason)
-				results = append(results, Result{Name: hook.Name, Skipped: true, Success: true})
+				r := Result{Name: hook.Name, Skipped: true, Success: true}
+				results = append(results, r)
+				finished[hook.Name] = r
 				continue
 			}
 
@@ -155,7 +324,9 @@ func (e *Executor) dryRun(executionPlan [][]config.Hook, files []string, allFile
 
 			if len(matchedFiles) == 0 && !allFiles {
 				fmt.Printf("  ⊘ %s (no matching files)\n", hook.Name)
-				results = append(results, Result{Name: hook.Name, Skipped: true, Success: true})
+				r := Result{Name: hook.Name, Skipped: true, Success: true}
+				results = append(results, r)
+				finished[hook.Name] = r
 				continue
 			}
 
@@ -173,7 +344,9 @@ func (e *Executor) dryRun(executionPlan [][]config.Hook, files []string, allFile
 				fmt.Printf("      files: %d matching\n", len(matchedFiles))
 			}
 
-			results = append(results, Result{Name: hook.Name, Success: true})
+			r := Result{Name: hook.Name, Success: true}
+			results = append(results, r)
+			finished[hook.Name] = r
 		}
 		level++
 		fmt.Println()
@@ -187,29 +360,7 @@ func (e *Executor) CheckPolicies(files []string, commitMsg string) *policy.EvalR
 		return nil
 	}
 
-	cacheDir := filepath.Join(e.workDir, ".hooks", "cache")
-	registry := policy.NewRegistry(e.workDir, cacheDir)
-
-	p := e.config.Policies
-	userCfg := &policy.UserConfig{
-		Type: p.Type,
-	}
-
-	for _, ref := range p.Policies {
-		userCfg.Policies = append(userCfg.Policies, policy.PolicyRef{URL: ref.URL})
-	}
-
-	for _, lp := range p.LocalPolicies {
-		userCfg.LocalPolicies = append(userCfg.LocalPolicies, policy.LocalPolicy{
-			Name:        lp.Name,
-			Version:     lp.Version,
-			Description: lp.Description,
-			Metadata:    lp.Metadata,
-			Rules:       convertRules(lp.Rules),
-		})
-	}
-
-	merged, err := registry.Load(userCfg)
+	merged, err := e.loadPolicies()
 	if err != nil {
 		return &policy.EvalResult{
 			Passed:     false,
@@ -221,7 +372,19 @@ func (e *Executor) CheckPolicies(files []string, commitMsg string) *policy.EvalR
 		return nil
 	}
 
-	result := policy.Evaluate(&merged.EffectiveRules, files, commitMsg)
+	e.maxParallel = merged.EffectiveRules.MaxParallelHooks
+
+	src := e.source
+	if src == nil {
+		src = policy.WorkTree(e.workDir)
+	}
+
+	p := e.config.Policies
+	changes := e.changes
+	if changes == nil {
+		changes = git.ChangesFromPaths(files)
+	}
+	result := policy.EvaluateChanges(&merged.EffectiveRules, changes, commitMsg, src)
 
 	if len(p.LuaScripts) > 0 {
 		type luaResult struct {
@@ -238,6 +401,10 @@ func (e *Executor) CheckPolicies(files []string, commitMsg string) *policy.EvalR
 			go func(s string) {
 				defer wg.Done()
 				luaRunner := luapkg.NewRunner(e.workDir)
+				if e.source != nil {
+					luaRunner.SetReader(e.source)
+				}
+				luaRunner.SetChanges(changes)
 				scriptPath := filepath.Join(e.workDir, s)
 				luaResults, err := luaRunner.RunPolicy(scriptPath, files)
 
@@ -279,6 +446,58 @@ func (e *Executor) CheckPolicies(files []string, commitMsg string) *policy.EvalR
 	return &result
 }
 
+// CheckCommitMessages validates the message of every commit in a push
+// against the effective commit_message rule.
+func (e *Executor) CheckCommitMessages(commits []policy.Commit) *policy.EvalResult {
+	if e.config.Policies == nil || len(commits) == 0 {
+		return nil
+	}
+
+	merged, err := e.loadPolicies()
+	if err != nil {
+		return &policy.EvalResult{
+			Passed:     false,
+			Violations: []policy.Violation{{Rule: "load", Message: err.Error()}},
+		}
+	}
+
+	if merged == nil {
+		return nil
+	}
+
+	result := policy.EvaluateCommits(&merged.EffectiveRules, commits)
+	return &result
+}
+
+func (e *Executor) loadPolicies() (*policy.MergedPolicy, error) {
+	cacheDir := filepath.Join(e.workDir, ".hooks", "cache")
+	registry := policy.NewRegistry(e.workDir, cacheDir)
+	if e.lock != nil {
+		registry.Pin(e.lock.Policies)
+	}
+
+	p := e.config.Policies
+	userCfg := &policy.UserConfig{
+		Type: p.Type,
+	}
+
+	for _, ref := range p.Policies {
+		userCfg.Policies = append(userCfg.Policies, policy.PolicyRef{URL: ref.URL})
+	}
+
+	for _, lp := range p.LocalPolicies {
+		userCfg.LocalPolicies = append(userCfg.LocalPolicies, policy.LocalPolicy{
+			Name:        lp.Name,
+			Version:     lp.Version,
+			Description: lp.Description,
+			Metadata:    lp.Metadata,
+			Rules:       convertRules(lp.Rules),
+		})
+	}
+
+	return registry.Load(userCfg)
+}
+
 func convertRules(r config.PolicyRules) policy.PolicyRules {
 	var cm *policy.CommitMessageRule
 	if r.CommitMessage != nil {
@@ -288,11 +507,17 @@ func convertRules(r config.PolicyRules) policy.PolicyRules {
 		}
 	}
 
+	regexBlock := make([]policy.RegexBlockPattern, 0, len(r.RegexBlock))
+	for _, p := range r.RegexBlock {
+		regexBlock = append(regexBlock, policy.RegexBlockPattern{Pattern: p.Pattern, Scope: p.Scope})
+	}
+
 	patterns := make([]policy.ForbiddenContentPattern, 0, len(r.ForbidFileContent))
 	for _, p := range r.ForbidFileContent {
 		patterns = append(patterns, policy.ForbiddenContentPattern{
 			Pattern:     p.Pattern,
 			Description: p.Description,
+			Scope:       p.Scope,
 		})
 	}
 
@@ -301,9 +526,12 @@ func convertRules(r config.PolicyRules) policy.PolicyRules {
 		ForbidDirectories:    r.ForbidDirectories,
 		ForbidFileExtensions: r.ForbidFileExtensions,
 		RequiredFiles:        r.RequiredFiles,
+		ForbidDelete:         r.ForbidDelete,
+		ForbidRenameFrom:     r.ForbidRenameFrom,
 		MaxFileSizeKB:        r.MaxFileSizeKB,
 		MaxFilesChanged:      r.MaxFilesChanged,
 		ForbidFileContent:    patterns,
+		RegexBlock:           regexBlock,
 		ExcludeExtensions:    r.ExcludeExtensions,
 		CommitMessage:        cm,
 		EnforceHooks:         r.EnforceHooks,
@@ -312,23 +540,11 @@ func convertRules(r config.PolicyRules) policy.PolicyRules {
 	}
 }
 
-func (e *Executor) runBatch(hooks []config.Hook, files []string, allFiles bool) []Result {
-	var wg sync.WaitGroup
-	results := make([]Result, len(hooks))
-
-	for i, hook := range hooks {
-		wg.Add(1)
-		go func(idx int, h config.Hook) {
-			defer wg.Done()
-			results[idx] = e.runHook(h, files, allFiles)
-		}(i, hook)
+func (e *Executor) shouldSkip(hook config.Hook) (bool, string) {
+	if hook.Disabled {
+		return true, "disabled"
 	}
 
-	wg.Wait()
-	return results
-}
-
-func (e *Executor) shouldSkip(hook config.Hook) (bool, string) {
 	for _, skip := range e.opts.SkipHooks {
 		if skip == hook.Name {
 			return true, "SKIP env"
@@ -358,9 +574,12 @@ func (e *Executor) shouldSkip(hook config.Hook) (bool, string) {
 	return false, ""
 }
 
-func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Result {
+// runHook runs a single hook. deps holds the results of the hooks it
+// depends on, whose outputs and artifacts it can refer to.
+func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool, deps map[string]Result) Result {
 	start := time.Now()
 	result := Result{Name: hook.Name}
+	hook = expandNeeds(hook, deps)
 
 	if skip, reason := e.shouldSkip(hook); skip {
 		result.Skipped = true
@@ -384,6 +603,12 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 		matchedFiles = files
 	}
 
+	if err := e.varErrs[hook.Name]; err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
 	hookHash := cache.ComputeHookHash(hook.Tool, hook.Args, hook.Files, hook.Glob, hook.Exclude)
 
 	if e.opts.UseCache && len(matchedFiles) > 0 {
@@ -413,8 +638,10 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 
 	var cmd *exec.Cmd
 	workDir := e.workDir
-	if hook.Root != "" {
-		workDir = filepath.Join(e.workDir, hook.Root)
+	if filepath.IsAbs(hook.Root) {
+		workDir = hook.Root
+	} else if hook.Root != "" || hook.Scope != "" {
+		workDir = filepath.Join(e.workDir, filepath.FromSlash(hook.Scope), hook.Root)
 	}
 
 	if hook.Run != "" {
@@ -426,11 +653,7 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 		}
 		cmd = exec.CommandContext(ctx, shell, shellArgs...)
 	} else if hook.Script != "" {
-		scriptsDir := e.config.ScriptsDir
-		if scriptsDir == "" {
-			scriptsDir = ".hooks"
-		}
-		scriptPath := filepath.Join(e.workDir, scriptsDir, hook.Script)
+		scriptPath := filepath.Join(e.scriptsDir(), hook.Script)
 		runner := hook.Runner
 		if runner == "" {
 			if strings.HasSuffix(hook.Script, ".ps1") {
@@ -441,7 +664,7 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 		}
 		cmd = exec.CommandContext(ctx, runner, scriptPath)
 	} else if hook.Tool != "" {
-		toolPath, err := e.toolMgr.EnsureTool(hook.Tool, e.config.GetTool(hook.Tool))
+		toolPath, err := e.ensureTool(hook.Tool)
 		if err != nil {
 			result.Error = err
 			result.Duration = time.Since(start)
@@ -458,13 +681,23 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 		return result
 	}
 
+	outputFile, err := os.CreateTemp("", "hookrunner-output-*")
+	if err != nil {
+		result.Error = fmt.Errorf("failed to create output file: %w", err)
+		result.Duration = time.Since(start)
+		return result
+	}
+	outputFile.Close()
+	defer os.Remove(outputFile.Name())
+
 	cmd.Dir = workDir
-	cmd.Env = e.buildEnv(hook)
+	cmd.Env = append(e.buildEnv(hook), "HOOKRUNNER_OUTPUT="+outputFile.Name())
+	cmd.Env = append(cmd.Env, needsEnv(deps)...)
 
 	output, err := cmd.CombinedOutput()
 
 	result.Duration = time.Since(start)
-	result.Output = string(output)
+	result.Output = e.realPaths(string(output))
 
 	if ctx.Err() == context.DeadlineExceeded {
 		result.Error = fmt.Errorf("timeout after %v", timeout)
@@ -483,6 +716,19 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 		return result
 	}
 
+	if result.Outputs, err = readOutputs(hook, outputFile.Name()); err != nil {
+		result.Error = fmt.Errorf("invalid $HOOKRUNNER_OUTPUT: %w", err)
+		return result
+	}
+	if result.Artifacts, err = e.collectArtifacts(hook, workDir); err != nil {
+		result.Error = err
+		return result
+	}
+	if err := e.preserveArtifacts(hook, result.Artifacts); err != nil {
+		result.Error = err
+		return result
+	}
+
 	result.Success = true
 
 	if e.opts.UseCache && len(matchedFiles) > 0 {
@@ -496,6 +742,13 @@ func (e *Executor) runHook(hook config.Hook, files []string, allFiles bool) Resu
 	return result
 }
 
+func (e *Executor) realPaths(output string) string {
+	if e.realDir == "" || e.realDir == e.workDir {
+		return output
+	}
+	return strings.ReplaceAll(output, e.workDir, e.realDir)
+}
+
 func (e *Executor) buildEnv(hook config.Hook) []string {
 	env := os.Environ()
 
@@ -507,12 +760,18 @@ func (e *Executor) buildEnv(hook config.Hook) []string {
 }
 
 func (e *Executor) filterFiles(files []string, hook config.Hook) []string {
+	if hook.Scope != "" {
+		files = scopedFiles(files, hook.Scope)
+	}
 	matched := make([]string, 0, len(files))
 
 	for _, f := range files {
+		// Patterns of scoped hooks are relative to their directory.
+		rel := strings.TrimPrefix(f, hook.Scope+"/")
+
 		if hook.Files != "" {
 			re, err := regexp.Compile(hook.Files)
-			if err != nil || !re.MatchString(f) {
+			if err != nil || !re.MatchString(rel) {
 				continue
 			}
 		}
@@ -526,7 +785,7 @@ func (e *Executor) filterFiles(files []string, hook config.Hook) []string {
 
 		if hook.Exclude != "" {
 			re, err := regexp.Compile(hook.Exclude)
-			if err == nil && re.MatchString(f) {
+			if err == nil && re.MatchString(rel) {
 				continue
 			}
 		}
@@ -552,11 +811,17 @@ func PrintResults(results []Result, verbose bool, quiet bool) {
 	cyan := color.New(color.FgCyan).SprintFunc()
 
 	var totalDuration time.Duration
-	passed, failed, skipped := 0, 0, 0
+	passed, failed, skipped, blocked := 0, 0, 0, 0
 
 	for _, r := range results {
 		totalDuration += r.Duration
 
+		if r.Blocked {
+			blocked++
+			fmt.Printf("%s %s - %s\n", yellow("[BLOCKED]"), r.Name, r.Output)
+			continue
+		}
+
 		if r.Skipped {
 			skipped++
 			if verbose {
@@ -587,11 +852,14 @@ func PrintResults(results []Result, verbose bool, quiet bool) {
 	if len(results) > 0 {
 		fmt.Println()
 		summary := fmt.Sprintf("Ran %d hooks in %v", len(results), totalDuration.Round(time.Millisecond))
+		counts := fmt.Sprintf("%d passed, %d failed", passed, failed)
 		if skipped > 0 {
-			summary += fmt.Sprintf(" (%d passed, %d failed, %d skipped)", passed, failed, skipped)
-		} else {
-			summary += fmt.Sprintf(" (%d passed, %d failed)", passed, failed)
+			counts += fmt.Sprintf(", %d skipped", skipped)
+		}
+		if blocked > 0 {
+			counts += fmt.Sprintf(", %d blocked", blocked)
 		}
+		summary += " (" + counts + ")"
 
 		if failed > 0 {
 			fmt.Println(red(summary))
@@ -644,6 +912,18 @@ func ParseSkipEnv() []string {
 	return strings.Split(skip, ",")
 }
 
+// scopedFiles returns the files under dir.
+func scopedFiles(files []string, dir string) []string {
+	prefix := dir + "/"
+	scoped := make([]string, 0, len(files))
+	for _, f := range files {
+		if strings.HasPrefix(f, prefix) {
+			scoped = append(scoped, f)
+		}
+	}
+	return scoped
+}
+
 func (e *Executor) ClearCache() error {
 	return e.cache.Clear()
 }
@@ -1,11 +1,18 @@
 package executor
 
 import (
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/history"
+	"github.com/ashavijit/hookrunner/internal/lock"
 	"github.com/ashavijit/hookrunner/internal/tool"
 )
 
@@ -67,8 +74,8 @@ func TestRun_WithCycleDetection(t *testing.T) {
 	cfg := &config.Config{
 		Hooks: map[string][]config.Hook{
 			"pre-commit": {
-				{Name: "a", Tool: "echo", After: "b"},
-				{Name: "b", Tool: "echo", After: "a"},
+				{Name: "a", Tool: "echo", After: []string{"b"}},
+				{Name: "b", Tool: "echo", After: []string{"a"}},
 			},
 		},
 	}
@@ -83,6 +90,9 @@ func TestRun_WithCycleDetection(t *testing.T) {
 	if results[0].Success {
 		t.Error("expected failure due to cycle")
 	}
+	if results[0].Error == nil || results[0].Error.Error() != "circular dependency: a -> b -> a" {
+		t.Errorf("expected cycle path in error, got %v", results[0].Error)
+	}
 }
 
 func TestRun_DryRun(t *testing.T) {
@@ -267,3 +277,376 @@ func TestOptions_Fields(t *testing.T) {
 		t.Error("UseCache should be true")
 	}
 }
+
+func TestRun_RealDirMapsOutput(t *testing.T) {
+	workDir := t.TempDir()
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {{Name: "echo", Run: "echo \"$(pwd)/a.go:1: bad\"; exit 1"}},
+		},
+	}
+	realDir := t.TempDir()
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetRealDir(realDir)
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if got := strings.TrimSpace(results[0].Output); got != realDir+"/a.go:1: bad" {
+		t.Errorf("expected output mapped to the real tree, got %q", got)
+	}
+}
+
+func TestRun_NeedsBlocksOnFailure(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "build", Run: "exit 1"},
+				{Name: "test", Run: "true", Needs: []string{"build"}},
+				{Name: "docs", Run: "true", After: []string{"build"}},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{FailFast: false, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+
+	if !byName["test"].Blocked || !byName["test"].Skipped {
+		t.Errorf("expected test to be blocked, got %+v", byName["test"])
+	}
+	if byName["docs"].Blocked || byName["docs"].Skipped || !byName["docs"].Success {
+		t.Errorf("expected docs to run after build, got %+v", byName["docs"])
+	}
+}
+
+func TestRun_SelectedHooks(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"manual": {
+				{Name: "build", Run: "true"},
+				{Name: "test", Run: "true", Needs: []string{"build"}},
+				{Name: "docs", Run: "true", After: []string{"build"}},
+				{Name: "deploy", Run: "true", Needs: []string{"test"}},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{Quiet: true, Hooks: []string{"test"}})
+
+	results := exec.Run("manual", []string{"a.go"}, false)
+	var ran []string
+	for _, r := range results {
+		if !r.Success {
+			t.Errorf("%s failed: %+v", r.Name, r)
+		}
+		ran = append(ran, r.Name)
+	}
+	sort.Strings(ran)
+	if strings.Join(ran, ",") != "build,test" {
+		t.Errorf("expected test and the build it needs to run, got %v", ran)
+	}
+}
+
+func TestRun_NeedsBlocksOnSkip(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "build", Run: "true"},
+				{Name: "test", Run: "true", Needs: []string{"build"}},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{FailFast: true, Quiet: true, SkipHooks: []string{"build"}})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Name == "test" && !r.Blocked {
+			t.Errorf("expected test to be blocked by skipped build, got %+v", r)
+		}
+	}
+}
+
+func TestRun_StartsHooksWhenDependenciesFinish(t *testing.T) {
+	workDir := t.TempDir()
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "slow", Run: "sleep 0.5; echo slow >> order.log"},
+				{Name: "fast", Run: "true"},
+				{Name: "child", Run: "echo child >> order.log", After: []string{"fast"}},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if HasFailure(results) {
+		t.Fatalf("unexpected failure: %+v", results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workDir, "order.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "child\nslow\n" {
+		t.Errorf("expected child to finish before slow, got %q", got)
+	}
+}
+
+func TestRun_ResultsInConfigOrder(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "slow", Run: "sleep 0.3"},
+				{Name: "fast", Run: "true"},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if len(results) != 2 || results[0].Name != "slow" || results[1].Name != "fast" {
+		t.Fatalf("expected results in config order, got %+v", results)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	PrintResults(results, false, false)
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slow, fast := strings.Index(string(out), "slow"), strings.Index(string(out), "fast")
+	if slow < 0 || fast < 0 || slow > fast {
+		t.Errorf("expected slow printed before fast:\n%s", out)
+	}
+}
+
+func TestRun_JobsLimit(t *testing.T) {
+	workDir := t.TempDir()
+	// mkdir fails if another hook holds the lock, so any overlap fails.
+	run := "mkdir lock || exit 1; sleep 0.1; rmdir lock"
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "a", Run: run},
+				{Name: "b", Run: run},
+				{Name: "c", Run: run},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: false, Quiet: true, Jobs: 1})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if HasFailure(results) {
+		t.Errorf("hooks overlapped despite Jobs=1: %+v", results)
+	}
+}
+
+func TestRun_LongestHookFirst(t *testing.T) {
+	workDir := t.TempDir()
+	hist := history.Load(workDir)
+	hist.Record("pre-commit", "short", history.StatusPassed, 10*time.Millisecond)
+	hist.Record("pre-commit", "long", history.StatusPassed, time.Minute)
+	if err := hist.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "short", Run: "echo short >> order.log"},
+				{Name: "long", Run: "echo long >> order.log"},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true, Jobs: 1})
+	exec.Run("pre-commit", []string{"a.go"}, false)
+
+	data, err := os.ReadFile(filepath.Join(workDir, "order.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "long\nshort\n" {
+		t.Errorf("expected long hook first, got %q", got)
+	}
+
+	if e, ok := history.Load(workDir).Lookup("pre-commit", "short"); !ok || e.Runs != 2 {
+		t.Errorf("expected run to be recorded in history, got %+v", e)
+	}
+}
+
+func TestRun_InterpolatesVariables(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "scripts", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		ScriptsDir: "scripts",
+		Hooks: map[string][]config.Hook{
+			"pre-push": {{
+				Name: "vars",
+				Run:  `echo "${hook_type} ${repo_root} ${GREETING}" '$${literal}'`,
+				Root: "${scripts_dir}/sub",
+				Env:  map[string]string{"GREETING": "${HOOKRUNNER_TEST_MISSING:-hi}"},
+			}},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-push", nil, true)
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("expected hook to pass, got %+v", results)
+	}
+	want := "pre-push " + workDir + " hi ${literal}"
+	if got := strings.TrimSpace(results[0].Output); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRun_UndefinedVariableFails(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {{Name: "bad", Run: "echo ${HOOKRUNNER_TEST_MISSING}"}},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", nil, true)
+	if len(results) != 1 || results[0].Success || results[0].Error == nil ||
+		!strings.Contains(results[0].Error.Error(), "undefined variable ${HOOKRUNNER_TEST_MISSING}") {
+		t.Fatalf("expected undefined variable error, got %+v", results)
+	}
+
+	// Only hooks that run need their variables.
+	cfg.Hooks["pre-commit"] = append(cfg.Hooks["pre-commit"], config.Hook{Name: "ok", Run: "echo ok"})
+	cfg.Hooks["pre-commit"][0].Files = `\.go$`
+	results = exec.Run("pre-commit", []string{"README.md"}, false)
+	for _, r := range results {
+		if !r.Success {
+			t.Errorf("expected %s to pass, got %v", r.Name, r.Error)
+		}
+	}
+}
+
+func TestRun_ScopedHooks(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "services", "payments"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{Name: "services/payments:lint", Scope: "services/payments", Files: `^a\.go$`, Run: "pwd"},
+				{Name: "web:lint", Scope: "web", Run: "pwd"},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"services/payments/a.go", "a.go", "services/payments/b.go"}, false)
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+
+	lint := byName["services/payments:lint"]
+	if !lint.Success || lint.Skipped {
+		t.Fatalf("expected scoped hook to run, got %+v", lint)
+	}
+	if got := strings.TrimSpace(lint.Output); !strings.HasSuffix(got, filepath.Join("services", "payments")) {
+		t.Errorf("expected hook to run in its directory, got %q", got)
+	}
+	if !byName["web:lint"].Skipped {
+		t.Errorf("expected hook without files in its scope to be skipped, got %+v", byName["web:lint"])
+	}
+
+	got := exec.filterFiles([]string{"services/payments/a.go", "a.go", "services/payments/b.go"}, cfg.Hooks["pre-commit"][0])
+	if len(got) != 1 || got[0] != "services/payments/a.go" {
+		t.Errorf("expected patterns relative to the scope, got %v", got)
+	}
+}
+
+func TestShouldSkip_Disabled(t *testing.T) {
+	exec := New(&config.Config{}, tool.NewManager(t.TempDir()), t.TempDir())
+	if skip, reason := exec.shouldSkip(config.Hook{Name: "vet", Disabled: true}); !skip || reason != "disabled" {
+		t.Errorf("expected disabled hook to be skipped, got %v %q", skip, reason)
+	}
+}
+
+func TestParallelLimit(t *testing.T) {
+	tests := []struct {
+		jobs, configJobs, maxParallel, want int
+	}{
+		{0, 0, 0, 0},
+		{0, 2, 0, 2},
+		{3, 2, 0, 3},
+		{0, 4, 1, 1},
+	}
+	for _, tt := range tests {
+		e := New(&config.Config{Jobs: tt.configJobs}, tool.NewManager(t.TempDir()), t.TempDir())
+		e.SetOptions(Options{Jobs: tt.jobs})
+		e.maxParallel = tt.maxParallel
+		if got := e.parallelLimit(); got != tt.want {
+			t.Errorf("jobs %d, config jobs %d, max_parallel_hooks %d: limit = %d, want %d", tt.jobs, tt.configJobs, tt.maxParallel, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureTool_Locked(t *testing.T) {
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"lint": {Version: "1.0", Install: map[string]string{runtime.GOOS: "https://example.com/lint-${arch}.tar.gz"}, Checksum: "abc"},
+	}}
+	e := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+
+	url := "https://example.com/lint-" + runtime.GOARCH + ".tar.gz"
+	expanded, err := cfg.Tools["lint"].Interpolate(e.vars(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.SetLock(&lock.Lock{Tools: map[string]lock.Tool{"lint": {Version: "1.0"}}})
+	if _, ok := e.lockedSum("lint", expanded); ok {
+		t.Error("expected no pin for a platform the lock lacks")
+	}
+	e.SetLock(&lock.Lock{Tools: map[string]lock.Tool{"lint": {Version: "0.9", Platforms: map[string]lock.Artifact{
+		lock.Platform(): {URL: "https://example.com/lint-0.9.tar.gz", SHA256: "def"},
+	}}}})
+	if _, ok := e.lockedSum("lint", expanded); ok {
+		t.Error("expected no pin for a download hooks-local changed")
+	}
+
+	e.SetLock(&lock.Lock{Tools: map[string]lock.Tool{"lint": {Version: "1.0", Platforms: map[string]lock.Artifact{
+		lock.Platform(): {URL: url, SHA256: "def"},
+	}}}})
+	if _, err := e.ensureTool("lint"); err == nil || !strings.Contains(err.Error(), "does not match hooks.lock") {
+		t.Errorf("expected checksum mismatch with the lock, got %v", err)
+	}
+}
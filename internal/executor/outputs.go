@@ -0,0 +1,249 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+)
+
+// ArtifactsDir is where clean-room runs keep hook artifacts in the real
+// repository, under one directory per hook.
+const ArtifactsDir = ".hookrunner/artifacts"
+
+// parseOutputs reads the file a hook wrote to $HOOKRUNNER_OUTPUT. Lines
+// are "key=value", or "key<<DELIM" followed by value lines and a line
+// holding DELIM for multi-line values, as in GitHub Actions.
+func parseOutputs(r io.Reader) (map[string]string, error) {
+	outputs := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if key, delim, ok := strings.Cut(line, "<<"); ok && !strings.Contains(key, "=") {
+			var value []string
+			closed := false
+			for scanner.Scan() {
+				l := strings.TrimSuffix(scanner.Text(), "\r")
+				if l == delim {
+					closed = true
+					break
+				}
+				value = append(value, l)
+			}
+			if !closed {
+				return nil, fmt.Errorf("output %q: missing closing %q", key, delim)
+			}
+			outputs[key] = strings.Join(value, "\n")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid output line: %q", line)
+		}
+		outputs[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// readOutputs returns the outputs the hook declared, from the file it
+// wrote. Undeclared keys are ignored.
+func readOutputs(hook config.Hook, path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	all, err := parseOutputs(f)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make(map[string]string, len(hook.Outputs))
+	for _, key := range hook.Outputs {
+		if v, ok := all[key]; ok {
+			outputs[key] = v
+		}
+	}
+	return outputs, nil
+}
+
+// dependencyResults returns the results of the hooks h depends on that
+// have finished, for passing their outputs and artifacts on.
+func dependencyResults(h config.Hook, finished map[string]Result) map[string]Result {
+	deps := make(map[string]Result)
+	for _, name := range h.Dependencies() {
+		if r, ok := finished[name]; ok {
+			deps[name] = r
+		}
+	}
+	return deps
+}
+
+// expandNeeds substitutes {{ needs.X.outputs.key }} and
+// {{ needs.X.artifacts }} in the hook's command, arguments and env.
+// Outputs of hooks that were skipped or failed expand to nothing.
+func expandNeeds(hook config.Hook, deps map[string]Result) config.Hook {
+	value := func(ref config.NeedsRef) string {
+		r := deps[ref.Hook]
+		if ref.Artifacts {
+			return strings.Join(r.Artifacts, " ")
+		}
+		return r.Outputs[ref.Output]
+	}
+
+	hook.Run = config.ExpandNeeds(hook.Run, value)
+	hook.Args = expandAll(hook.Args, value)
+	hook.FixArgs = expandAll(hook.FixArgs, value)
+	if len(hook.Env) > 0 {
+		env := make(map[string]string, len(hook.Env))
+		for k, v := range hook.Env {
+			env[k] = config.ExpandNeeds(v, value)
+		}
+		hook.Env = env
+	}
+	return hook
+}
+
+func expandAll(values []string, value func(config.NeedsRef) string) []string {
+	if len(values) == 0 {
+		return values
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = config.ExpandNeeds(v, value)
+	}
+	return out
+}
+
+// needsEnv exposes dependency outputs and artifacts as
+// HOOKRUNNER_NEEDS_<HOOK>_<KEY> and HOOKRUNNER_NEEDS_<HOOK>_ARTIFACTS.
+func needsEnv(deps map[string]Result) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var env []string
+	for _, name := range names {
+		r := deps[name]
+		prefix := "HOOKRUNNER_NEEDS_" + envName(name) + "_"
+		keys := make([]string, 0, len(r.Outputs))
+		for k := range r.Outputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, prefix+envName(k)+"="+r.Outputs[k])
+		}
+		if len(r.Artifacts) > 0 {
+			env = append(env, prefix+"ARTIFACTS="+strings.Join(r.Artifacts, " "))
+		}
+	}
+	return env
+}
+
+// envName upper-cases s and replaces anything but letters and digits
+// with underscores.
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// collectArtifacts resolves the hook's artifact patterns in dir, the
+// directory the hook ran in, and returns the matches relative to the work
+// dir. A pattern that matches nothing is an error.
+func (e *Executor) collectArtifacts(hook config.Hook, dir string) ([]string, error) {
+	var artifacts []string
+	for _, pattern := range hook.Artifacts {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("artifact %q was not produced", pattern)
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(e.workDir, m)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, filepath.ToSlash(rel))
+		}
+	}
+	return artifacts, nil
+}
+
+// preserveArtifacts copies a clean-room hook's artifacts to
+// .hookrunner/artifacts/<hook>/ in the real repository, so they outlive
+// the next sync of the clean room.
+func (e *Executor) preserveArtifacts(hook config.Hook, artifacts []string) error {
+	if e.realDir == "" || e.realDir == e.workDir || len(artifacts) == 0 {
+		return nil
+	}
+
+	dest := filepath.Join(e.realDir, filepath.FromSlash(ArtifactsDir), sanitizeHookName(hook.Name))
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	for _, a := range artifacts {
+		src := filepath.Join(e.workDir, filepath.FromSlash(a))
+		if err := copyTree(src, filepath.Join(dest, filepath.FromSlash(a))); err != nil {
+			return fmt.Errorf("failed to preserve artifact %s: %w", a, err)
+		}
+	}
+	return nil
+}
+
+func sanitizeHookName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", " ", "_").Replace(name)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path) //nolint:gosec // G304: path is an artifact the hook declared
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/tool"
+)
+
+func TestParseOutputs(t *testing.T) {
+	input := "version=1.2.3\nempty=\nnotes<<EOF\nline one\nline=two\nEOF\nurl=http://x?a=b\n"
+	outputs, err := parseOutputs(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"version": "1.2.3",
+		"empty":   "",
+		"notes":   "line one\nline=two",
+		"url":     "http://x?a=b",
+	}
+	for k, v := range want {
+		if outputs[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, outputs[k])
+		}
+	}
+}
+
+func TestParseOutputs_Invalid(t *testing.T) {
+	for _, input := range []string{"no separator\n", "=value\n", "notes<<EOF\nunterminated\n"} {
+		if _, err := parseOutputs(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := envName("go-generate.v2"); got != "GO_GENERATE_V2" {
+		t.Errorf("expected GO_GENERATE_V2, got %s", got)
+	}
+}
+
+func TestRun_OutputsAndArtifacts(t *testing.T) {
+	workDir := t.TempDir()
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {
+				{
+					Name:      "generate",
+					Run:       `mkdir -p gen && echo code > gen/a.go && echo "version=1.2" >> "$HOOKRUNNER_OUTPUT" && echo "extra=x" >> "$HOOKRUNNER_OUTPUT"`,
+					Outputs:   []string{"version"},
+					Artifacts: []string{"gen/*.go"},
+				},
+				{
+					Name:  "lint",
+					Run:   `echo "{{ needs.generate.outputs.version }} {{ needs.generate.artifacts }} $HOOKRUNNER_NEEDS_GENERATE_VERSION $HOOKRUNNER_NEEDS_GENERATE_ARTIFACTS $VIA_ENV"`,
+					Needs: []string{"generate"},
+					Env:   map[string]string{"VIA_ENV": "v{{ needs.generate.outputs.version }}"},
+				},
+			},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	byName := make(map[string]Result)
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+
+	gen := byName["generate"]
+	if !gen.Success {
+		t.Fatalf("generate failed: %+v", gen)
+	}
+	if len(gen.Outputs) != 1 || gen.Outputs["version"] != "1.2" {
+		t.Errorf("expected only the declared output, got %v", gen.Outputs)
+	}
+	if len(gen.Artifacts) != 1 || gen.Artifacts[0] != "gen/a.go" {
+		t.Errorf("expected artifact gen/a.go, got %v", gen.Artifacts)
+	}
+
+	lint := byName["lint"]
+	if got := strings.TrimSpace(lint.Output); got != "1.2 gen/a.go 1.2 gen/a.go v1.2" {
+		t.Errorf("unexpected lint output %q", got)
+	}
+}
+
+func TestRun_MissingArtifactFails(t *testing.T) {
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {{Name: "build", Run: "true", Artifacts: []string{"dist/app"}}},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), t.TempDir())
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if len(results) != 1 || results[0].Success || results[0].Error == nil {
+		t.Fatalf("expected build to fail, got %+v", results)
+	}
+}
+
+func TestRun_CleanRoomPreservesArtifacts(t *testing.T) {
+	workDir := t.TempDir()
+	realDir := t.TempDir()
+	cfg := &config.Config{
+		Hooks: map[string][]config.Hook{
+			"pre-commit": {{Name: "build", Run: "mkdir -p dist && echo bin > dist/app", Artifacts: []string{"dist"}}},
+		},
+	}
+	exec := New(cfg, tool.NewManager(t.TempDir()), workDir)
+	exec.SetOptions(Options{FailFast: true, Quiet: true})
+	exec.SetRealDir(realDir)
+
+	results := exec.Run("pre-commit", []string{"a.go"}, false)
+	if HasFailure(results) {
+		t.Fatalf("unexpected failure: %+v", results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(realDir, ".hookrunner", "artifacts", "build", "dist", "app"))
+	if err != nil {
+		t.Fatalf("artifact not preserved: %v", err)
+	}
+	if string(data) != "bin\n" {
+		t.Errorf("unexpected artifact content %q", data)
+	}
+}
@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/dag"
+	"github.com/ashavijit/hookrunner/internal/history"
+)
+
+// schedule runs the hooks of graph as soon as all of their dependencies
+// have finished, instead of waiting for a whole level. At most limit hooks
+// run at once (no limit when limit <= 0). When more hooks are ready than
+// can start, the one heading the longest chain of remaining work, by
+// historical duration, goes first, unless a priority: says otherwise;
+// ties keep config order. Results come back in config order, whatever
+// order the hooks finished in.
+func (e *Executor) schedule(hookType string, hooks []config.Hook, graph *dag.Graph, files []string, allFiles bool, limit int, hist *history.History) []Result {
+	critical := graph.CriticalPath(estimator(hookType, hooks, hist))
+
+	pending := make(map[string]int, len(graph.Nodes))
+	var ready []*dag.Node
+	for _, h := range hooks {
+		node := graph.Nodes[h.Name]
+		pending[h.Name] = node.InDegree
+		if node.InDegree == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	var results []Result
+	finished := make(map[string]Result, len(hooks))
+	done := make(chan Result)
+	running := 0
+	stopped := false
+
+	complete := func(r Result) {
+		results = append(results, r)
+		finished[r.Name] = r
+		if !r.Success && !r.Skipped && e.opts.FailFast {
+			stopped = true
+		}
+		for _, child := range graph.Nodes[r.Name].Children {
+			pending[child.Hook.Name]--
+			if pending[child.Hook.Name] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+
+	for {
+		for !stopped && len(ready) > 0 && (limit <= 0 || running < limit) {
+			i := next(ready, critical)
+			node := ready[i]
+			ready = append(ready[:i], ready[i+1:]...)
+
+			h := node.Hook
+			if need, ok := unmetNeed(h, finished); ok {
+				complete(Result{Name: h.Name, Skipped: true, Blocked: true, Output: fmt.Sprintf("blocked (needs %s)", need)})
+				continue
+			}
+
+			if !e.opts.Quiet && e.opts.Verbose {
+				fmt.Printf("Starting %s\n", h.Name)
+			}
+			running++
+			go func(h config.Hook, deps map[string]Result) {
+				done <- e.runHook(h, files, allFiles, deps)
+			}(h, dependencyResults(h, finished))
+		}
+
+		if running == 0 {
+			break
+		}
+		r := <-done
+		running--
+		complete(r)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return dag.Less(graph.Nodes[results[i].Name], graph.Nodes[results[j].Name])
+	})
+	return results
+}
+
+// next picks the ready hook to start: the highest configured priority,
+// then the longest critical path, then config order.
+func next(ready []*dag.Node, critical map[string]time.Duration) int {
+	best := 0
+	for i := 1; i < len(ready); i++ {
+		a, b := ready[i], ready[best]
+		if a.Hook.Priority != b.Hook.Priority {
+			if a.Hook.Priority > b.Hook.Priority {
+				best = i
+			}
+			continue
+		}
+		if ca, cb := critical[a.Hook.Name], critical[b.Hook.Name]; ca != cb {
+			if ca > cb {
+				best = i
+			}
+			continue
+		}
+		if dag.Less(a, b) {
+			best = i
+		}
+	}
+	return best
+}
+
+// estimator returns each hook's average duration from the run history.
+// Hooks that have never run are assumed to take the average of those that
+// have, so they are neither starved nor always started first.
+func estimator(hookType string, hooks []config.Hook, hist *history.History) func(config.Hook) time.Duration {
+	var total time.Duration
+	known := 0
+	for _, h := range hooks {
+		if d, ok := hist.Estimate(hookType, h.Name); ok {
+			total += d
+			known++
+		}
+	}
+	var fallback time.Duration
+	if known > 0 {
+		fallback = total / time.Duration(known)
+	}
+
+	return func(h config.Hook) time.Duration {
+		if d, ok := hist.Estimate(hookType, h.Name); ok {
+			return d
+		}
+		return fallback
+	}
+}
+
+// recordHistory stores the results of a run so later runs can prioritize
+// long hooks.
+func recordHistory(hist *history.History, hookType string, results []Result) {
+	for _, r := range results {
+		status := history.StatusPassed
+		switch {
+		case r.Blocked:
+			status = history.StatusBlocked
+		case r.Skipped:
+			status = history.StatusSkipped
+		case !r.Success:
+			status = history.StatusFailed
+		}
+		hist.Record(hookType, r.Name, status, r.Duration)
+	}
+	_ = hist.Save() //nolint:errcheck // history only tunes scheduling
+}
@@ -0,0 +1,185 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BlobSource reads file contents from the index or a commit through a
+// single long-running "git cat-file --batch" process, so policies see what
+// is actually being committed or pushed rather than the working tree.
+type BlobSource struct {
+	repoRoot string
+	rev      string
+	// Revs overrides the revision for individual paths, for pushes that
+	// span several refs.
+	Revs map[string]string
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	content map[string][]byte
+}
+
+// NewBlobSource returns a source reading paths at rev, or from the index
+// when rev is empty. Paths are relative to repoRoot.
+func NewBlobSource(repoRoot, rev string) *BlobSource {
+	return &BlobSource{
+		repoRoot: repoRoot,
+		rev:      rev,
+		content:  make(map[string][]byte),
+	}
+}
+
+func (b *BlobSource) ReadFile(path string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.read(path)
+}
+
+func (b *BlobSource) Size(path string) (int64, error) {
+	data, err := b.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// Close stops the cat-file process.
+func (b *BlobSource) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cmd == nil {
+		return nil
+	}
+	b.stdin.Close()
+	err := b.cmd.Wait()
+	b.cmd = nil
+	return err
+}
+
+func (b *BlobSource) read(path string) ([]byte, error) {
+	if data, ok := b.content[path]; ok {
+		return data, nil
+	}
+
+	if err := b.start(); err != nil {
+		return nil, err
+	}
+
+	rev := b.rev
+	if r, ok := b.Revs[path]; ok {
+		rev = r
+	}
+	object := rev + ":" + path
+	if strings.ContainsAny(path, "\r\n") {
+		// The batch protocol is line based, so names containing line
+		// breaks are resolved to an object id first.
+		oid, err := b.resolve(object)
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: path, Err: os.ErrNotExist}
+		}
+		object = oid
+	}
+	if _, err := fmt.Fprintf(b.stdin, "%s\n", object); err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", path, err)
+	}
+
+	header, err := b.stdout.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	header = strings.TrimSuffix(header, "\n")
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return nil, &os.PathError{Op: "read", Path: path, Err: os.ErrNotExist}
+	}
+	typ, size, err := parseHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cat-file header for %s: %q", path, header)
+	}
+	if typ != "blob" {
+		// Still consume the object so the stream stays in sync.
+		_, _ = io.CopyN(io.Discard, b.stdout, size+1) //nolint:errcheck // best-effort resync
+		return nil, fmt.Errorf("%s is a %s, not a file", path, typ)
+	}
+
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(b.stdout, data); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	data = data[:size]
+
+	b.content[path] = data
+	return data, nil
+}
+
+// parseHeader returns the type and size of an object from its
+// "<oid> <type> <size>" cat-file header, read from the end.
+func parseHeader(header string) (string, int64, error) {
+	rest, sizeField, ok := cutLast(header)
+	if !ok {
+		return "", 0, fmt.Errorf("no size")
+	}
+	_, typ, ok := cutLast(rest)
+	if !ok || typ == "" {
+		return "", 0, fmt.Errorf("no type")
+	}
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, fmt.Errorf("invalid size %q", sizeField)
+	}
+	return typ, size, nil
+}
+
+// cutLast splits s around its last space.
+func cutLast(s string) (string, string, bool) {
+	i := strings.LastIndexByte(s, ' ')
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+func (b *BlobSource) resolve(object string) (string, error) {
+	//nolint:gosec // G204: object is passed as a separate argument, not shell input
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", object)
+	cmd.Dir = b.repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (b *BlobSource) start() error {
+	if b.cmd != nil {
+		return nil
+	}
+
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Dir = b.repoRoot
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+
+	b.cmd = cmd
+	b.stdin = stdin
+	b.stdout = bufio.NewReader(stdout)
+	return nil
+}
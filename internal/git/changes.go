@@ -0,0 +1,214 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ChangeStatus is how a file was changed, as reported by "git diff --raw".
+type ChangeStatus string
+
+const (
+	StatusAdded       ChangeStatus = "added"
+	StatusModified    ChangeStatus = "modified"
+	StatusDeleted     ChangeStatus = "deleted"
+	StatusRenamed     ChangeStatus = "renamed"
+	StatusCopied      ChangeStatus = "copied"
+	StatusTypeChanged ChangeStatus = "type_changed"
+)
+
+const (
+	modeSymlink   = "120000"
+	modeSubmodule = "160000"
+)
+
+// Change is a single file in a ChangeSet. OldPath is set for renames and
+// copies. Modes are git's octal file modes, "000000" when the file does
+// not exist on that side.
+type Change struct {
+	Path       string
+	OldPath    string
+	Status     ChangeStatus
+	OldMode    string
+	NewMode    string
+	Similarity int
+}
+
+// ModeChanged reports whether the file mode changed, such as gaining the
+// executable bit.
+func (c Change) ModeChanged() bool {
+	return c.Status != StatusAdded && c.Status != StatusDeleted && c.OldMode != c.NewMode
+}
+
+// IsSymlink reports whether either side of the change is a symlink.
+func (c Change) IsSymlink() bool {
+	return c.OldMode == modeSymlink || c.NewMode == modeSymlink
+}
+
+// IsSubmodule reports whether either side of the change is a submodule.
+func (c Change) IsSubmodule() bool {
+	return c.OldMode == modeSubmodule || c.NewMode == modeSubmodule
+}
+
+// ChangeSet is the list of files touched by a change, including deletions
+// and renames.
+type ChangeSet []Change
+
+// Files returns the paths that exist after the change, which is what
+// hooks and content checks run on.
+func (cs ChangeSet) Files() []string {
+	var files []string
+	for _, c := range cs {
+		if c.Status != StatusDeleted {
+			files = append(files, c.Path)
+		}
+	}
+	return files
+}
+
+// ChangesFromPaths builds a ChangeSet for paths selected without a diff,
+// treating each as modified.
+func ChangesFromPaths(paths []string) ChangeSet {
+	cs := make(ChangeSet, 0, len(paths))
+	for _, p := range paths {
+		cs = append(cs, Change{Path: p, Status: StatusModified})
+	}
+	return cs
+}
+
+// GetStagedChanges returns the staged changes, with renames detected.
+func GetStagedChanges() (ChangeSet, error) {
+	return rawChanges("diff", "--cached")
+}
+
+// GetRangeChanges returns the changes between the merge-base of fromRef
+// and toRef, and toRef. An empty toRef means HEAD.
+func GetRangeChanges(fromRef, toRef string) (ChangeSet, error) {
+	if toRef == "" {
+		toRef = "HEAD"
+	}
+	return rawChanges("diff", fromRef+"..."+toRef)
+}
+
+// GetCommitChanges returns the changes made by a single commit, including
+// the root commit.
+func GetCommitChanges(rev string) (ChangeSet, error) {
+	return rawChanges("diff-tree", "--root", "--no-commit-id", "-r", rev)
+}
+
+// GetPushedChanges returns what the pushed refs change on the remote.
+// Refs without a usable base fall back to the changes of their unpushed
+// commits, keeping the newest status of each path.
+func GetPushedChanges(refs []PushRef, remote string) (ChangeSet, error) {
+	var result ChangeSet
+	seen := make(map[string]int)
+	for _, ref := range refs {
+		if ref.IsDelete() {
+			continue
+		}
+
+		var changes ChangeSet
+		var err error
+		if base := pushBase(ref, remote); base != "" {
+			changes, err = rawChanges("diff", base, ref.LocalSHA)
+		} else {
+			changes, err = rawChanges(append([]string{"log", "--format="}, unpushedRevs(ref, remote)...)...)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		refSeen := make(map[string]bool)
+		for _, c := range changes {
+			if refSeen[c.Path] {
+				continue
+			}
+			refSeen[c.Path] = true
+			if i, ok := seen[c.Path]; ok {
+				result[i] = c
+				continue
+			}
+			seen[c.Path] = len(result)
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func rawChanges(args ...string) (ChangeSet, error) {
+	full := append([]string{args[0], "--raw", "-z", "-M", "--no-abbrev"}, args[1:]...)
+
+	//nolint:gosec // G204: arguments are git options and object names, not shell input
+	out, err := exec.Command("git", full...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changes: %w", err)
+	}
+	return ParseRawDiff(out)
+}
+
+// ParseRawDiff parses the output of "git diff --raw -z". Each entry is a
+// ":<old mode> <new mode> <old sha> <new sha> <status>" header followed by
+// one path, or two for renames and copies.
+func ParseRawDiff(data []byte) (ChangeSet, error) {
+	fields := bytes.Split(bytes.TrimSuffix(data, []byte{0}), []byte{0})
+
+	var cs ChangeSet
+	for i := 0; i < len(fields); i++ {
+		header := string(fields[i])
+		if header == "" {
+			continue
+		}
+		if !strings.HasPrefix(header, ":") {
+			return nil, fmt.Errorf("invalid raw diff entry: %q", header)
+		}
+
+		parts := strings.Fields(header[1:])
+		if len(parts) != 5 || parts[4] == "" {
+			return nil, fmt.Errorf("invalid raw diff entry: %q", header)
+		}
+
+		c := Change{OldMode: parts[0], NewMode: parts[1]}
+		code, score := parts[4][0], parts[4][1:]
+		paths := 1
+		switch code {
+		case 'A':
+			c.Status = StatusAdded
+		case 'M':
+			c.Status = StatusModified
+		case 'D':
+			c.Status = StatusDeleted
+		case 'T':
+			c.Status = StatusTypeChanged
+		case 'R':
+			c.Status = StatusRenamed
+			paths = 2
+		case 'C':
+			c.Status = StatusCopied
+			paths = 2
+		default:
+			// Unmerged or unknown entries carry a single path; skip them.
+			i++
+			continue
+		}
+		if score != "" {
+			c.Similarity, _ = strconv.Atoi(score) //nolint:errcheck // zero when absent
+		}
+
+		if i+paths >= len(fields) {
+			return nil, fmt.Errorf("truncated raw diff entry: %q", header)
+		}
+		if paths == 2 {
+			c.OldPath = string(fields[i+1])
+			c.Path = string(fields[i+2])
+		} else {
+			c.Path = string(fields[i+1])
+		}
+		i += paths
+
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
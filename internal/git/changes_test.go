@@ -0,0 +1,87 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRawDiff(t *testing.T) {
+	zero := "0000000000000000000000000000000000000000"
+	sha := "e69de29bb2d1d6434b8b29ae775ad8c2d48c5391"
+	data := ":000000 100644 " + zero + " " + sha + " A\x00new file.txt\x00" +
+		":100644 000000 " + sha + " " + zero + " D\x00old.sql\x00" +
+		":100644 100644 " + sha + " " + sha + " R087\x00api/a.go\x00internal/a.go\x00" +
+		":100644 100755 " + sha + " " + sha + " M\x00run.sh\x00" +
+		":160000 160000 " + sha + " " + sha + " M\x00vendor/lib\x00"
+
+	cs, err := ParseRawDiff([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseRawDiff failed: %v", err)
+	}
+	if len(cs) != 5 {
+		t.Fatalf("expected 5 changes, got %+v", cs)
+	}
+
+	if cs[0].Status != StatusAdded || cs[0].Path != "new file.txt" {
+		t.Errorf("unexpected added entry: %+v", cs[0])
+	}
+	if cs[1].Status != StatusDeleted || cs[1].Path != "old.sql" {
+		t.Errorf("unexpected deleted entry: %+v", cs[1])
+	}
+	if cs[2].Status != StatusRenamed || cs[2].OldPath != "api/a.go" || cs[2].Path != "internal/a.go" || cs[2].Similarity != 87 {
+		t.Errorf("unexpected renamed entry: %+v", cs[2])
+	}
+	if !cs[3].ModeChanged() {
+		t.Errorf("expected mode change for %+v", cs[3])
+	}
+	if !cs[4].IsSubmodule() || cs[4].ModeChanged() {
+		t.Errorf("expected unchanged submodule for %+v", cs[4])
+	}
+
+	files := cs.Files()
+	if len(files) != 4 {
+		t.Errorf("expected deleted file to be left out of Files, got %v", files)
+	}
+}
+
+func TestParseRawDiff_Invalid(t *testing.T) {
+	if _, err := ParseRawDiff([]byte("not a raw diff\x00")); err == nil {
+		t.Error("expected error for malformed entry")
+	}
+	if _, err := ParseRawDiff([]byte(":100644 100644 a b R100\x00only-one\x00")); err == nil {
+		t.Error("expected error for truncated rename")
+	}
+}
+
+func TestGetStagedChanges(t *testing.T) {
+	repo := initTestRepo(t)
+	commitFile(t, repo, "migrations/001.sql", "create table a;\n", "add migration")
+	commitFile(t, repo, "api/handler.go", "package api\n\nfunc Handle() {}\n", "add handler")
+
+	runGit(t, repo, "rm", "-q", "migrations/001.sql")
+	runGit(t, repo, "mv", "api/handler.go", "handler.go")
+	if err := os.Symlink("handler.go", filepath.Join(repo, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", "link.go")
+
+	cs, err := GetStagedChanges()
+	if err != nil {
+		t.Fatalf("GetStagedChanges failed: %v", err)
+	}
+
+	byPath := make(map[string]Change)
+	for _, c := range cs {
+		byPath[c.Path] = c
+	}
+	if c := byPath["migrations/001.sql"]; c.Status != StatusDeleted {
+		t.Errorf("expected deleted migration, got %+v", c)
+	}
+	if c := byPath["handler.go"]; c.Status != StatusRenamed || c.OldPath != "api/handler.go" {
+		t.Errorf("expected rename from api/handler.go, got %+v", c)
+	}
+	if c := byPath["link.go"]; c.Status != StatusAdded || !c.IsSymlink() {
+		t.Errorf("expected added symlink, got %+v", c)
+	}
+}
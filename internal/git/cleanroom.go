@@ -0,0 +1,338 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CleanRoomDir is where the persistent clean room lives, relative to the
+// repository root.
+const CleanRoomDir = ".hookrunner/cleanroom"
+
+const cleanRoomState = ".hookrunner/cleanroom.json"
+
+// CleanRoom is a copy of the index kept under .hookrunner/ between runs.
+type CleanRoom struct {
+	// Dir is the absolute path hooks run in.
+	Dir string
+	// RepoRoot is the real working tree the clean room mirrors.
+	RepoRoot string
+	// Updated and Removed count the files written and deleted by the
+	// last sync.
+	Updated int
+	Removed int
+}
+
+type cleanRoomEntry struct {
+	Mode  string `json:"mode"`
+	SHA   string `json:"sha"`
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"`
+}
+
+type cleanRoomManifest struct {
+	Files map[string]cleanRoomEntry `json:"files"`
+	Links []string                  `json:"links"`
+}
+
+type indexEntry struct {
+	mode string
+	sha  string
+	path string
+}
+
+// SyncCleanRoom brings the clean room up to date with the index. Only
+// files whose blob changed, or that were modified in the clean room since
+// the last sync, are checked out again; files no longer staged are
+// removed. Each entry of links that exists in the real tree and is not
+// tracked, such as node_modules, is symlinked into the clean room instead
+// of copied.
+func SyncCleanRoom(links []string) (*CleanRoom, error) {
+	repoRoot, err := FindRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	room := &CleanRoom{
+		Dir:      filepath.Join(repoRoot, filepath.FromSlash(CleanRoomDir)),
+		RepoRoot: repoRoot,
+	}
+	if err := os.MkdirAll(room.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create clean-room: %w", err)
+	}
+	IgnoreHookRunnerDir(repoRoot)
+
+	statePath := filepath.Join(repoRoot, filepath.FromSlash(cleanRoomState))
+	manifest := loadCleanRoomManifest(statePath)
+
+	entries, err := indexEntries(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := make(map[string]indexEntry, len(entries))
+	for _, e := range entries {
+		staged[e.path] = e
+	}
+
+	for path := range manifest.Files {
+		if _, ok := staged[path]; ok {
+			continue
+		}
+		if err := removeCleanRoomPath(room.Dir, path); err != nil {
+			return nil, err
+		}
+		delete(manifest.Files, path)
+		room.Removed++
+	}
+
+	var stale []string
+	for _, e := range entries {
+		if e.mode == modeSubmodule {
+			// checkout-index leaves submodules alone; keep an empty
+			// directory so paths into them still resolve.
+			if err := os.MkdirAll(filepath.Join(room.Dir, e.path), 0755); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if prev, ok := manifest.Files[e.path]; ok && prev.Mode == e.mode && prev.SHA == e.sha &&
+			unchangedOnDisk(filepath.Join(room.Dir, e.path), prev) {
+			continue
+		}
+		stale = append(stale, e.path)
+	}
+
+	if err := checkoutInto(repoRoot, room.Dir, stale); err != nil {
+		return nil, err
+	}
+	for _, path := range stale {
+		e := staged[path]
+		entry := cleanRoomEntry{Mode: e.mode, SHA: e.sha}
+		if info, err := os.Lstat(filepath.Join(room.Dir, path)); err == nil {
+			entry.Size = info.Size()
+			entry.MTime = info.ModTime().UnixNano()
+		}
+		manifest.Files[path] = entry
+	}
+	room.Updated = len(stale)
+
+	manifest.Links, err = syncLinks(room, staged, manifest.Links, links)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveCleanRoomManifest(statePath, manifest); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// RemoveCleanRoom deletes the clean room and its state.
+func RemoveCleanRoom() error {
+	repoRoot, err := FindRepoRoot()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(repoRoot, filepath.FromSlash(CleanRoomDir))); err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(repoRoot, filepath.FromSlash(cleanRoomState)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func indexEntries(repoRoot string) ([]indexEntry, error) {
+	cmd := exec.Command("git", "ls-files", "--stage", "-z")
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var entries []indexEntry
+	for _, record := range splitNul(out) {
+		// "<mode> <sha> <stage>\t<path>"
+		meta, path, ok := strings.Cut(record, "\t")
+		if !ok {
+			return nil, fmt.Errorf("invalid index entry: %q", record)
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid index entry: %q", record)
+		}
+		if fields[2] != "0" {
+			// Unmerged paths have no single staged version.
+			continue
+		}
+		entries = append(entries, indexEntry{mode: fields[0], sha: fields[1], path: path})
+	}
+	return entries, nil
+}
+
+func checkoutInto(repoRoot, dir string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	// Remove stale copies first so a path that changed type, or that a
+	// hook replaced with a directory, is written fresh.
+	for _, path := range paths {
+		if err := os.RemoveAll(filepath.Join(dir, path)); err != nil {
+			return err
+		}
+	}
+
+	var input bytes.Buffer
+	for _, path := range paths {
+		input.WriteString(path)
+		input.WriteByte(0)
+	}
+
+	// --prefix must end with a trailing slash
+	//nolint:gosec // G204: dir is derived from the repository root, not user input
+	cmd := exec.Command("git", "checkout-index", "--force", "-z", "--stdin", "--prefix="+dir+string(filepath.Separator))
+	cmd.Dir = repoRoot
+	cmd.Stdin = &input
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to extract staged files: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
+func unchangedOnDisk(path string, prev cleanRoomEntry) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.Size() == prev.Size && info.ModTime().UnixNano() == prev.MTime
+}
+
+func removeCleanRoomPath(dir, path string) error {
+	full := filepath.Join(dir, path)
+	if err := os.RemoveAll(full); err != nil {
+		return fmt.Errorf("failed to remove %s from clean-room: %w", path, err)
+	}
+	// Drop directories the removal left empty, up to the clean room root.
+	for parent := filepath.Dir(full); parent != dir && strings.HasPrefix(parent, dir); parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// syncLinks creates the requested symlinks and removes ones that are no
+// longer configured. It returns the links now present.
+func syncLinks(room *CleanRoom, staged map[string]indexEntry, previous, links []string) ([]string, error) {
+	wanted := make(map[string]bool, len(links))
+	for _, link := range links {
+		wanted[filepath.ToSlash(filepath.Clean(link))] = true
+	}
+	for _, link := range previous {
+		if wanted[link] {
+			continue
+		}
+		path := filepath.Join(room.Dir, filepath.FromSlash(link))
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var present []string
+	for _, link := range links {
+		link = filepath.ToSlash(filepath.Clean(link))
+		if link == "." || link == ".." || strings.HasPrefix(link, "../") || filepath.IsAbs(link) {
+			return nil, fmt.Errorf("clean-room link %q must be a path inside the repository", link)
+		}
+		if isTracked(staged, link) {
+			continue
+		}
+
+		target := filepath.Join(room.RepoRoot, filepath.FromSlash(link))
+		path := filepath.Join(room.Dir, filepath.FromSlash(link))
+		if _, err := os.Stat(target); err != nil {
+			// Nothing to share yet; drop a link left from an earlier run.
+			if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+				_ = os.Remove(path) //nolint:errcheck // best-effort removal of a dangling link
+			}
+			continue
+		}
+
+		if current, err := os.Readlink(path); err == nil && current == target {
+			present = append(present, link)
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Symlink(target, path); err != nil {
+			return nil, linkError(link, err)
+		}
+		present = append(present, link)
+	}
+	return present, nil
+}
+
+// linkError explains a failure to create the clean-room link for link.
+// Windows only lets administrators create symlinks unless Developer Mode
+// is on, so say so there rather than leave a bare privilege error.
+func linkError(link string, err error) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("failed to link %s into clean-room: %w\n"+
+			"creating symlinks on Windows needs Developer Mode or an elevated shell; "+
+			"enable one, or remove %s from clean_room.link", link, err, link)
+	}
+	return fmt.Errorf("failed to link %s into clean-room: %w", link, err)
+}
+
+// isTracked reports whether link or anything below it is in the index.
+func isTracked(staged map[string]indexEntry, link string) bool {
+	if _, ok := staged[link]; ok {
+		return true
+	}
+	prefix := link + "/"
+	for path := range staged {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadCleanRoomManifest(path string) *cleanRoomManifest {
+	manifest := &cleanRoomManifest{}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, manifest) //nolint:errcheck // a corrupt manifest just forces a full sync
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]cleanRoomEntry)
+	}
+	return manifest
+}
+
+func saveCleanRoomManifest(path string, manifest *cleanRoomManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save clean-room state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
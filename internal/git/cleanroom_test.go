@@ -0,0 +1,123 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncCleanRoom_Incremental(t *testing.T) {
+	repo := initTestRepo(t)
+	commitFile(t, repo, "src/a.go", "package a\n", "add a")
+	commitFile(t, repo, "src/b.go", "package b\n", "add b")
+
+	room, err := SyncCleanRoom(nil)
+	if err != nil {
+		t.Fatalf("SyncCleanRoom failed: %v", err)
+	}
+	if room.Updated != 3 || room.Removed != 0 {
+		t.Errorf("expected a full first sync, got %d updated, %d removed", room.Updated, room.Removed)
+	}
+	if got := readFile(t, filepath.Join(room.Dir, "src", "a.go")); got != "package a\n" {
+		t.Errorf("unexpected clean-room content: %q", got)
+	}
+	if out := runGit(t, repo, "status", "--porcelain"); out != "" {
+		t.Errorf("clean-room should not show in git status, got:\n%s", out)
+	}
+
+	room, err = SyncCleanRoom(nil)
+	if err != nil {
+		t.Fatalf("second SyncCleanRoom failed: %v", err)
+	}
+	if room.Updated != 0 || room.Removed != 0 {
+		t.Errorf("expected nothing to sync, got %d updated, %d removed", room.Updated, room.Removed)
+	}
+
+	// Staged changes, a deletion and a hook tampering with the clean room
+	// are all picked up; unstaged edits are not.
+	writeFile(t, filepath.Join(repo, "src", "a.go"), "package a // staged\n")
+	runGit(t, repo, "add", "src/a.go")
+	writeFile(t, filepath.Join(repo, "src", "a.go"), "package a // unstaged\n")
+	runGit(t, repo, "rm", "-q", "src/b.go")
+	writeFile(t, filepath.Join(room.Dir, "README.md"), "changed by a hook")
+
+	room, err = SyncCleanRoom(nil)
+	if err != nil {
+		t.Fatalf("third SyncCleanRoom failed: %v", err)
+	}
+	if room.Updated != 2 || room.Removed != 1 {
+		t.Errorf("expected 2 updated and 1 removed, got %d and %d", room.Updated, room.Removed)
+	}
+	if got := readFile(t, filepath.Join(room.Dir, "src", "a.go")); got != "package a // staged\n" {
+		t.Errorf("expected staged content, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(room.Dir, "README.md")); got != "hello" {
+		t.Errorf("expected tampered file to be restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(room.Dir, "src", "b.go")); !os.IsNotExist(err) {
+		t.Errorf("expected deleted file to be removed, got %v", err)
+	}
+
+	if err := RemoveCleanRoom(); err != nil {
+		t.Fatalf("RemoveCleanRoom failed: %v", err)
+	}
+	if _, err := os.Stat(room.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected clean-room to be removed, got %v", err)
+	}
+}
+
+func TestSyncCleanRoom_Links(t *testing.T) {
+	repo := initTestRepo(t)
+	commitFile(t, repo, "vendor/tracked.txt", "tracked", "add vendor")
+	writeFile(t, filepath.Join(repo, "node_modules", "pkg", "index.js"), "module.exports = 1")
+
+	room, err := SyncCleanRoom([]string{"node_modules", "vendor", ".venv"})
+	if err != nil {
+		t.Fatalf("SyncCleanRoom failed: %v", err)
+	}
+
+	link := filepath.Join(room.Dir, "node_modules")
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatalf("expected node_modules to be a symlink: %v", err)
+	}
+	if target != filepath.Join(repo, "node_modules") {
+		t.Errorf("unexpected link target %q", target)
+	}
+	if info, err := os.Lstat(filepath.Join(room.Dir, "vendor")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("tracked vendor/ should be checked out, not linked: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(room.Dir, ".venv")); !os.IsNotExist(err) {
+		t.Errorf("missing .venv should not be linked, got %v", err)
+	}
+
+	if _, err := SyncCleanRoom(nil); err != nil {
+		t.Fatalf("SyncCleanRoom failed: %v", err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("expected unconfigured link to be removed, got %v", err)
+	}
+
+	if _, err := SyncCleanRoom([]string{"../outside"}); err == nil {
+		t.Error("expected error for a link outside the repository")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,162 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// AddedLine is a line added by a change, numbered as in the new file.
+type AddedLine struct {
+	Number int
+	Text   string
+}
+
+// GetStagedAddedLines returns the lines added by the staged changes, keyed
+// by path.
+func GetStagedAddedLines() (map[string][]AddedLine, error) {
+	return addedLines("diff", "--cached")
+}
+
+// GetRangeAddedLines returns the lines added between the merge-base of
+// fromRef and toRef, and toRef. An empty toRef means HEAD.
+func GetRangeAddedLines(fromRef, toRef string) (map[string][]AddedLine, error) {
+	if toRef == "" {
+		toRef = "HEAD"
+	}
+	return addedLines("diff", fromRef+"..."+toRef)
+}
+
+// GetCommitAddedLines returns the lines added by a single commit.
+func GetCommitAddedLines(rev string) (map[string][]AddedLine, error) {
+	return addedLines("diff-tree", "--root", "--no-commit-id", "-r", rev)
+}
+
+// GetPushedAddedLines returns the lines the pushed refs add to the remote.
+// Refs without a usable base are compared with the empty tree, so every
+// line of their files counts as added.
+func GetPushedAddedLines(refs []PushRef, remote string) (map[string][]AddedLine, error) {
+	result := make(map[string][]AddedLine)
+	for _, ref := range refs {
+		if ref.IsDelete() {
+			continue
+		}
+
+		base := pushBase(ref, remote)
+		if base == "" {
+			var err error
+			base, err = emptyTree()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		added, err := addedLines("diff", base, ref.LocalSHA)
+		if err != nil {
+			return nil, err
+		}
+		for path, lines := range added {
+			result[path] = lines
+		}
+	}
+	return result, nil
+}
+
+func emptyTree() (string, error) {
+	cmd := exec.Command("git", "hash-object", "-t", "tree", "--stdin")
+	cmd.Stdin = strings.NewReader("")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to hash empty tree: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func addedLines(args ...string) (map[string][]AddedLine, error) {
+	full := append([]string{"-c", "core.quotePath=false"}, args[0], "-p", "-U0", "--no-color", "--no-ext-diff", "--diff-filter=ACMR")
+	full = append(full, args[1:]...)
+
+	//nolint:gosec // G204: arguments are git options and object names, not shell input
+	out, err := exec.Command("git", full...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+	return ParseAddedLines(strings.NewReader(string(out)))
+}
+
+// ParseAddedLines extracts the added lines of each file from a unified
+// diff, as produced by "git diff -U0".
+func ParseAddedLines(r io.Reader) (map[string][]AddedLine, error) {
+	result := make(map[string][]AddedLine)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var path string
+	lineNo := 0
+	inHunk := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			path = ""
+			inHunk = false
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			path = diffPath(strings.TrimPrefix(line, "+++ "))
+		case strings.HasPrefix(line, "@@ "):
+			start, err := hunkStart(line)
+			if err != nil {
+				return nil, err
+			}
+			lineNo = start
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "+"):
+			if path != "" {
+				result[path] = append(result[path], AddedLine{Number: lineNo, Text: line[1:]})
+			}
+			lineNo++
+		case inHunk && strings.HasPrefix(line, " "):
+			lineNo++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse diff: %w", err)
+	}
+	return result, nil
+}
+
+// diffPath turns a "+++" header value into a repository path. Patch
+// output has no -z mode: git C-quotes names with special characters and
+// terminates names containing spaces with a tab.
+func diffPath(s string) string {
+	s = strings.TrimSuffix(s, "\t")
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	return strings.TrimPrefix(s, "b/")
+}
+
+// hunkStart parses the new-file start line from "@@ -a,b +c,d @@".
+func hunkStart(header string) (int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, fmt.Errorf("invalid hunk header: %q", header)
+	}
+	start := strings.TrimPrefix(fields[2], "+")
+	if i := strings.IndexByte(start, ','); i >= 0 {
+		start = start[:i]
+	}
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hunk header: %q", header)
+	}
+	return n, nil
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAddedLines(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3,0 +4,2 @@ func main() {
++	// TODO: added
++++counter
+@@ -10 +12 @@ func other() {
+-	old()
++	updated()
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ "b/sp\303\244ce.txt"
+@@ -0,0 +1 @@
++hello
+`
+
+	added, err := ParseAddedLines(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("ParseAddedLines failed: %v", err)
+	}
+
+	main := added["main.go"]
+	if len(main) != 3 {
+		t.Fatalf("expected 3 added lines in main.go, got %+v", main)
+	}
+	if main[0].Number != 4 || main[0].Text != "\t// TODO: added" {
+		t.Errorf("unexpected first line: %+v", main[0])
+	}
+	if main[1].Number != 5 || main[1].Text != "++counter" {
+		t.Errorf("unexpected second line: %+v", main[1])
+	}
+	if main[2].Number != 12 {
+		t.Errorf("expected line 12, got %d", main[2].Number)
+	}
+	if lines := added["späce.txt"]; len(lines) != 1 || lines[0].Number != 1 {
+		t.Errorf("expected quoted path to be unquoted, got %+v", added)
+	}
+}
+
+func TestGetStagedAddedLines(t *testing.T) {
+	repo := initTestRepo(t)
+	commitFile(t, repo, "legacy.go", "// TODO: old\nfunc a() {}\n", "add legacy")
+
+	path := filepath.Join(repo, "legacy.go")
+	if err := os.WriteFile(path, []byte("// TODO: old\nfunc a() {}\nfunc b() {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repo, "add", "legacy.go")
+
+	added, err := GetStagedAddedLines()
+	if err != nil {
+		t.Fatalf("GetStagedAddedLines failed: %v", err)
+	}
+	lines := added["legacy.go"]
+	if len(lines) != 1 || lines[0].Number != 3 || lines[0].Text != "func b() {}" {
+		t.Errorf("expected only line 3 to be added, got %+v", lines)
+	}
+}
+
+func TestGetPushedAddedLines_NewBranch(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "checkout", "--orphan", "fresh")
+	localSHA := commitFile(t, repo, "a.txt", "one\ntwo\n", "fresh start")
+
+	refs := []PushRef{{
+		LocalRef:  "refs/heads/fresh",
+		LocalSHA:  localSHA,
+		RemoteRef: "refs/heads/fresh",
+		RemoteSHA: strings.Repeat("0", 40),
+	}}
+
+	added, err := GetPushedAddedLines(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedAddedLines failed: %v", err)
+	}
+	if len(added["a.txt"]) != 2 {
+		t.Errorf("expected every line of a.txt to be added, got %+v", added)
+	}
+}
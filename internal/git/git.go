@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/version"
 )
 
 func FindRepoRoot() (string, error) {
@@ -14,92 +16,327 @@ func FindRepoRoot() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("not a git repository: %w", err)
 	}
-	return strings.TrimSpace(string(out)), nil
+	return trimNewline(out), nil
 }
 
-func GetStagedFiles() ([]string, error) {
-	cmd := exec.Command("git", "diff", "--cached", "--name-only", "--diff-filter=ACMR")
+func GetAllFiles() ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z")
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get staged files: %w", err)
+		return nil, fmt.Errorf("failed to get files: %w", err)
 	}
+	return splitNul(out), nil
+}
 
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+// FilesNamedAt returns the files committed at ref in the repository at
+// repoDir whose base name is one of names.
+func FilesNamedAt(repoDir, ref string, names ...string) ([]string, error) {
+	//nolint:gosec // G204: argument is a ref, not shell input
+	out, err := exec.Command("git", "-C", repoDir, "ls-tree", "-r", "-z", "--name-only", ref).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files at %s: %w", ref, err)
+	}
 	var files []string
-	for _, line := range lines {
-		if line != "" {
-			files = append(files, line)
+	for _, f := range splitNul(out) {
+		base := f[strings.LastIndex(f, "/")+1:]
+		for _, name := range names {
+			if base == name {
+				files = append(files, f)
+				break
+			}
 		}
 	}
 	return files, nil
 }
 
-func GetAllFiles() ([]string, error) {
-	cmd := exec.Command("git", "ls-files")
-	out, err := cmd.Output()
+// splitNul splits the output of a git command run with -z. Paths are
+// returned verbatim: with -z git neither quotes nor escapes them, so names
+// with spaces, quotes, newlines or non-ASCII characters survive intact.
+func splitNul(out []byte) []string {
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// trimNewline strips the single line terminator git prints after a path,
+// leaving any other whitespace in the path alone.
+func trimNewline(out []byte) string {
+	s := strings.TrimSuffix(string(out), "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// HooksDir returns the directory git runs hooks from. It honours
+// core.hooksPath and resolves the common git directory for linked
+// worktrees and submodules, where .git is a file.
+func HooksDir() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get files: %w", err)
+		return "", fmt.Errorf("failed to resolve hooks directory: %w", err)
 	}
+	return absPath(trimNewline(out))
+}
 
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
-	var files []string
-	for _, line := range lines {
-		if line != "" {
-			files = append(files, line)
-		}
+// DefaultHooksDir returns the repository's own hooks directory, ignoring
+// core.hooksPath.
+func DefaultHooksDir() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve git directory: %w", err)
 	}
-	return files, nil
+	dir, err := absPath(trimNewline(out))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hooks"), nil
 }
 
-func InstallHook(hookType string, binaryPath string) error {
-	repoRoot, err := FindRepoRoot()
+// ConfiguredHooksPath returns the raw core.hooksPath value, or "" if unset.
+func ConfiguredHooksPath() string {
+	out, err := exec.Command("git", "config", "-z", "--get", "core.hooksPath").Output()
 	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(out), "\x00")
+}
+
+const (
+	// hooksPathKey records the core.hooksPath SetHooksPath wrote, so that
+	// ResetHooksPath only undoes hookrunner's own change.
+	hooksPathKey = "hookrunner.hooksPath"
+	// previousHooksPathKey records the local core.hooksPath it replaced.
+	previousHooksPathKey = "hookrunner.previousHooksPath"
+)
+
+// SetHooksPath points core.hooksPath at dir in the repository's local config
+// and records the change for ResetHooksPath.
+func SetHooksPath(dir string) error {
+	current := localConfig("core.hooksPath")
+	recorded := localConfig(hooksPathKey)
+	if current == dir && recorded == "" {
+		// Already set by the user, not ours to undo.
+		return nil
+	}
+	if current != recorded {
+		if err := setLocalConfig(previousHooksPathKey, current); err != nil {
+			return err
+		}
+	}
+	if err := setLocalConfig("core.hooksPath", dir); err != nil {
 		return err
 	}
+	return setLocalConfig(hooksPathKey, dir)
+}
+
+// ResetHooksPath undoes SetHooksPath: if core.hooksPath still has the value
+// it wrote, the previous local value is restored, or core.hooksPath unset.
+// It returns whether core.hooksPath changed and the value restored.
+func ResetHooksPath() (reset bool, previous string, err error) {
+	recorded := localConfig(hooksPathKey)
+	if recorded == "" {
+		return false, "", nil
+	}
+	previous = localConfig(previousHooksPathKey)
+	if localConfig("core.hooksPath") == recorded {
+		if err := setLocalConfig("core.hooksPath", previous); err != nil {
+			return false, "", err
+		}
+		reset = true
+	}
+	for _, key := range []string{hooksPathKey, previousHooksPathKey} {
+		if err := setLocalConfig(key, ""); err != nil {
+			return reset, previous, err
+		}
+	}
+	return reset, previous, nil
+}
+
+// localConfig returns key from the repository's local config, or "" if it
+// is unset.
+func localConfig(key string) string {
+	out, err := exec.Command("git", "config", "--local", "-z", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(out), "\x00")
+}
+
+// setLocalConfig sets key in the repository's local config, or unsets it
+// when value is empty.
+func setLocalConfig(key, value string) error {
+	if value == "" {
+		if localConfig(key) == "" {
+			return nil
+		}
+		if output, err := exec.Command("git", "config", "--local", "--unset", key).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to unset %s: %w\n%s", key, err, string(output))
+		}
+		return nil
+	}
+	//nolint:gosec // G204: value is passed as a separate argument, not shell input
+	if output, err := exec.Command("git", "config", "--local", key, value).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set %s: %w\n%s", key, err, string(output))
+	}
+	return nil
+}
+
+// IgnoreHookRunnerDir keeps the .hookrunner/ state directory under dir out
+// of git status without touching the project's .gitignore. The directory
+// must already exist.
+func IgnoreHookRunnerDir(dir string) {
+	path := filepath.Join(dir, ".hookrunner", ".gitignore")
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	_ = os.WriteFile(path, []byte("*\n"), 0600) //nolint:errcheck // best-effort, status noise only
+}
+
+func absPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, path), nil
+}
+
+const (
+	// hookMarker identifies scripts written by InstallHook.
+	hookMarker = "# HookRunner - Auto-generated hook script"
+	// BackupSuffix is appended to hooks from other tools that InstallHook
+	// moves out of the way.
+	BackupSuffix = ".pre-hookrunner"
+)
+
+// InstallOptions controls where a hook script is written and what it runs.
+type InstallOptions struct {
+	// HooksDir overrides the directory resolved by HooksDir.
+	HooksDir string
+	// ChainTo is an existing hook script run before hookrunner, with the
+	// same arguments and input.
+	ChainTo string
+	// Force replaces a hook written by another tool without a backup.
+	Force bool
+	// Migrate backs up a hook written by another tool and chains to it.
+	Migrate bool
+}
+
+// InstallResult describes what InstallHook did to the hooks directory.
+type InstallResult struct {
+	Path string
+	// BackupPath is where a hook written by another tool was moved.
+	BackupPath string
+	// ChainTo is the hook the installed script runs first, if any.
+	ChainTo string
+}
+
+// UninstallResult describes what UninstallHook did to the hooks directory.
+type UninstallResult struct {
+	Removed bool
+	// Foreign is set when the hook was left alone because hookrunner
+	// didn't write it.
+	Foreign bool
+	// RestoredFrom is the backup moved back into place.
+	RestoredFrom string
+}
+
+func InstallHook(hookType string, binaryPath string, opts InstallOptions) (InstallResult, error) {
+	hooksDir := opts.HooksDir
+	if hooksDir == "" {
+		var err error
+		hooksDir, err = HooksDir()
+		if err != nil {
+			return InstallResult{}, err
+		}
+	}
 
-	hooksDir := filepath.Join(repoRoot, ".git", "hooks")
 	if err := os.MkdirAll(hooksDir, 0755); err != nil {
-		return fmt.Errorf("failed to create hooks directory: %w", err)
+		return InstallResult{}, fmt.Errorf("failed to create hooks directory: %w", err)
 	}
 
 	hookPath := filepath.Join(hooksDir, hookType)
+	backupPath := hookPath + BackupSuffix
+	result := InstallResult{Path: hookPath, ChainTo: opts.ChainTo}
+
+	if _, err := os.Lstat(hookPath); err == nil {
+		existing, readErr := os.ReadFile(hookPath)
+		switch {
+		case readErr == nil && IsManagedHook(existing):
+			// Reinstalling keeps chaining to whatever the old script ran.
+			if result.ChainTo == "" {
+				result.ChainTo = chainTarget(existing)
+			}
+		case opts.Force:
+			// Replaced below without a backup.
+		default:
+			if _, err := os.Lstat(backupPath); err == nil {
+				return result, fmt.Errorf("existing %s hook was not installed by hookrunner and %s already exists; remove it or use --force", hookType, backupPath)
+			}
+			if err := os.Rename(hookPath, backupPath); err != nil {
+				return result, fmt.Errorf("failed to back up existing hook: %w", err)
+			}
+			result.BackupPath = backupPath
+		}
+	}
+
+	if opts.Migrate && result.ChainTo == "" {
+		if _, err := os.Stat(backupPath); err == nil {
+			result.ChainTo = backupPath
+		}
+	}
+
 	binaryPath = strings.ReplaceAll(binaryPath, "\\", "/")
 
+	chain := ""
+	if result.ChainTo != "" {
+		chain = fmt.Sprintf(`
+# Run the hook this script replaced before hookrunner's own checks
+HOOKRUNNER_CHAIN=%s
+export HOOKRUNNER_CHAIN
+`, shellQuote(strings.ReplaceAll(result.ChainTo, "\\", "/")))
+	}
+
 	// Generate a smart hook script that finds hookrunner dynamically
 	// This prevents the frustrating "No such file or directory" error
 	// when the binary path changes (reinstall, different machine, etc.)
-	// NOTE: We don't pass "$@" because hookrunner run only accepts the hook type
+	// "$@" forwards git's hook arguments (e.g. the remote name for pre-push)
 	content := fmt.Sprintf(`#!/bin/sh
-# HookRunner - Auto-generated hook script
+%s
+# hookrunner-version: %s
 # This script finds hookrunner dynamically to avoid path issues
-
+%s
 # Try the installed path first
-if [ -x "%s" ]; then
-    exec "%s" run %s
+if [ -x %s ]; then
+    exec %s run %s "$@"
 fi
 
 # Try finding hookrunner in PATH
 if command -v hookrunner >/dev/null 2>&1; then
-    exec hookrunner run %s
+    exec hookrunner run %s "$@"
 fi
 
 # Try common installation locations
 for dir in "$GOPATH/bin" "$HOME/go/bin" "$HOME/.local/bin" "/usr/local/bin" "."; do
     if [ -x "$dir/hookrunner" ]; then
-        exec "$dir/hookrunner" run %s
+        exec "$dir/hookrunner" run %s "$@"
     fi
     # Windows executable
     if [ -x "$dir/hookrunner.exe" ]; then
-        exec "$dir/hookrunner.exe" run %s
+        exec "$dir/hookrunner.exe" run %s "$@"
     fi
 done
 
 # Try the current directory (for development)
 if [ -x "./hookrunner" ]; then
-    exec ./hookrunner run %s
+    exec ./hookrunner run %s "$@"
 fi
 if [ -x "./hookrunner.exe" ]; then
-    exec ./hookrunner.exe run %s
+    exec ./hookrunner.exe run %s "$@"
 fi
 
 echo "ERROR: hookrunner not found!"
@@ -111,69 +348,99 @@ echo ""
 echo "Or reinstall hooks after building:"
 echo "  hookrunner install"
 exit 1
-`, binaryPath, binaryPath, hookType, hookType, hookType, hookType, hookType, hookType)
+`, hookMarker, version.String(), chain, shellQuote(binaryPath), shellQuote(binaryPath), hookType, hookType, hookType, hookType, hookType, hookType)
 
 	//nolint:gosec // G306: Hook script must be executable (0755)
 	if err := os.WriteFile(hookPath, []byte(content), 0755); err != nil {
-		return fmt.Errorf("failed to write hook: %w", err)
+		return result, fmt.Errorf("failed to write hook: %w", err)
 	}
 
-	return nil
+	return result, nil
 }
 
-func UninstallHook(hookType string) error {
-	repoRoot, err := FindRepoRoot()
-	if err != nil {
-		return err
-	}
+// IsManagedHook reports whether a hook script was written by hookrunner.
+func IsManagedHook(content []byte) bool {
+	return strings.Contains(string(content), hookMarker)
+}
 
-	hookPath := filepath.Join(repoRoot, ".git", "hooks", hookType)
-	if _, err := os.Stat(hookPath); os.IsNotExist(err) {
-		return nil
+// ManagedHookVersion returns the hookrunner version recorded in a hook
+// script, or "" for scripts written before versions were recorded.
+func ManagedHookVersion(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		if v, ok := strings.CutPrefix(line, "# hookrunner-version: "); ok {
+			return strings.TrimSpace(v)
+		}
 	}
+	return ""
+}
 
-	if err := os.Remove(hookPath); err != nil {
-		return fmt.Errorf("failed to remove hook: %w", err)
+func chainTarget(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		if v, ok := strings.CutPrefix(line, "HOOKRUNNER_CHAIN="); ok {
+			return shellUnquote(strings.TrimSpace(v))
+		}
 	}
+	return ""
+}
 
-	return nil
+// shellQuote quotes s for a POSIX shell, so that $, ` and " in paths are
+// taken literally.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
-func IsInsideWorkTree() bool {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	out, err := cmd.Output()
-	if err != nil {
-		return false
+// shellUnquote reverses shellQuote. Scripts from older versions quoted
+// the value in double quotes.
+func shellUnquote(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], `'\''`, "'")
 	}
-	return strings.TrimSpace(string(out)) == "true"
+	return strings.Trim(s, `"`)
 }
 
-func CreateCleanRoom() (string, error) {
-	repoRoot, err := FindRepoRoot()
-	if err != nil {
-		return "", err
+// UninstallHook removes a hook script written by hookrunner and restores
+// the hook it backed up, if any. Hooks written by other tools are left in
+// place.
+func UninstallHook(hookType string, hooksDir string) (UninstallResult, error) {
+	var result UninstallResult
+	if hooksDir == "" {
+		var err error
+		hooksDir, err = HooksDir()
+		if err != nil {
+			return result, err
+		}
 	}
 
-	tempDir, err := os.MkdirTemp("", "hookrunner-cleanroom-*")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	hookPath := filepath.Join(hooksDir, hookType)
+	backupPath := hookPath + BackupSuffix
+
+	if _, err := os.Lstat(hookPath); err == nil {
+		content, readErr := os.ReadFile(hookPath)
+		if readErr != nil || !IsManagedHook(content) {
+			result.Foreign = true
+			return result, nil
+		}
+		if err := os.Remove(hookPath); err != nil {
+			return result, fmt.Errorf("failed to remove hook: %w", err)
+		}
+		result.Removed = true
 	}
 
-	// --prefix must end with a trailing slash
-	//nolint:gosec // G204: tempDir is safely generated by os.MkdirTemp, not user input
-	cmd := exec.Command("git", "checkout-index", "--all", "--prefix="+tempDir+"/")
-	cmd.Dir = repoRoot
-	if output, err := cmd.CombinedOutput(); err != nil {
-		os.RemoveAll(tempDir)
-		return "", fmt.Errorf("failed to extract staged files: %w\n%s", err, string(output))
+	if _, err := os.Lstat(backupPath); err == nil {
+		if err := os.Rename(backupPath, hookPath); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", backupPath, err)
+		}
+		result.RestoredFrom = backupPath
 	}
 
-	return tempDir, nil
+	return result, nil
 }
 
-func CleanupCleanRoom(path string) error {
-	if path == "" {
-		return nil
+func IsInsideWorkTree() bool {
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	out, err := cmd.Output()
+	if err != nil {
+		return false
 	}
-	return os.RemoveAll(path)
+	return strings.TrimSpace(string(out)) == "true"
 }
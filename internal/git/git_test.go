@@ -2,7 +2,9 @@ package git
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -21,27 +23,6 @@ func TestFindRepoRoot(t *testing.T) {
 	}
 }
 
-func TestGetStagedFiles(t *testing.T) {
-	if !IsInsideWorkTree() {
-		t.Skip("not in a git repository")
-	}
-
-	files, err := GetStagedFiles()
-	if err != nil {
-		t.Fatalf("failed to get staged files: %v", err)
-	}
-
-	if files == nil {
-		files = []string{}
-	}
-
-	for _, f := range files {
-		if f == "" {
-			t.Error("file should not be empty string")
-		}
-	}
-}
-
 func TestGetAllFiles(t *testing.T) {
 	if !IsInsideWorkTree() {
 		t.Skip("not in a git repository")
@@ -69,20 +50,14 @@ func TestIsInsideWorkTree(t *testing.T) {
 }
 
 func TestInstallHook(t *testing.T) {
-	if !IsInsideWorkTree() {
-		t.Skip("not in a git repository")
-	}
+	repo := initTestRepo(t)
 
-	err := InstallHook("pre-commit", "/path/to/binary")
+	_, err := InstallHook("pre-commit", "/path/to/binary", InstallOptions{})
 	if err != nil {
 		t.Fatalf("failed to install hook: %v", err)
 	}
 
-	root, rootErr := FindRepoRoot()
-	if rootErr != nil {
-		t.Fatalf("FindRepoRoot failed: %v", rootErr)
-	}
-	hookPath := filepath.Join(root, ".git", "hooks", "pre-commit")
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
 
 	if _, statErr := os.Stat(hookPath); os.IsNotExist(statErr) {
 		t.Error("hook file should exist")
@@ -93,31 +68,77 @@ func TestInstallHook(t *testing.T) {
 		t.Fatalf("failed to read hook: %v", err)
 	}
 
-	if len(content) == 0 {
-		t.Error("hook content should not be empty")
+	if !IsManagedHook(content) {
+		t.Error("hook should carry the hookrunner marker")
+	}
+
+	if ManagedHookVersion(content) == "" {
+		t.Error("hook should record the hookrunner version")
 	}
 }
 
-func TestUninstallHook(t *testing.T) {
-	if !IsInsideWorkTree() {
-		t.Skip("not in a git repository")
+func TestInstallHook_Chain(t *testing.T) {
+	initTestRepo(t)
+	dir := t.TempDir()
+
+	_, err := InstallHook("pre-push", "/path/to/binary", InstallOptions{HooksDir: dir, ChainTo: "/global/hooks/pre-push"})
+	if err != nil {
+		t.Fatalf("failed to install hook: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "pre-push"))
+	if err != nil {
+		t.Fatalf("failed to read hook: %v", err)
+	}
+	if !strings.Contains(string(content), `HOOKRUNNER_CHAIN='/global/hooks/pre-push'`) {
+		t.Error("hook should export the chained hook path")
+	}
+}
+
+func TestInstallHook_ChainQuoting(t *testing.T) {
+	initTestRepo(t)
+	dir := t.TempDir()
+	chain := `/hooks/it's $HOME/"pre-push"`
+
+	if _, err := InstallHook("pre-push", "/path/to/binary", InstallOptions{HooksDir: dir, ChainTo: chain}); err != nil {
+		t.Fatalf("failed to install hook: %v", err)
 	}
+	content, err := os.ReadFile(filepath.Join(dir, "pre-push"))
+	if err != nil {
+		t.Fatalf("failed to read hook: %v", err)
+	}
+	if got := chainTarget(content); got != chain {
+		t.Errorf("expected chain %q, got %q", chain, got)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(line, "HOOKRUNNER_CHAIN=") {
+			continue
+		}
+		out, err := exec.Command("sh", "-c", line+`; printf %s "$HOOKRUNNER_CHAIN"`).Output()
+		if err != nil {
+			t.Skipf("sh not available: %v", err)
+		}
+		if string(out) != chain {
+			t.Errorf("shell should read chain %q, got %q", chain, out)
+		}
+	}
+}
 
-	err := InstallHook("test-hook", "/path/to/binary")
+func TestUninstallHook(t *testing.T) {
+	repo := initTestRepo(t)
+
+	_, err := InstallHook("test-hook", "/path/to/binary", InstallOptions{})
 	if err != nil {
 		t.Fatalf("failed to install hook: %v", err)
 	}
 
-	err = UninstallHook("test-hook")
+	_, err = UninstallHook("test-hook", "")
 	if err != nil {
 		t.Fatalf("failed to uninstall hook: %v", err)
 	}
 
-	root, rootErr := FindRepoRoot()
-	if rootErr != nil {
-		t.Fatalf("FindRepoRoot failed: %v", rootErr)
-	}
-	hookPath := filepath.Join(root, ".git", "hooks", "test-hook")
+	hookPath := filepath.Join(repo, ".git", "hooks", "test-hook")
 
 	if _, statErr := os.Stat(hookPath); !os.IsNotExist(statErr) {
 		t.Error("hook file should not exist after uninstall")
@@ -125,12 +146,230 @@ func TestUninstallHook(t *testing.T) {
 }
 
 func TestUninstallHook_NotExists(t *testing.T) {
-	if !IsInsideWorkTree() {
-		t.Skip("not in a git repository")
-	}
+	initTestRepo(t)
 
-	err := UninstallHook("nonexistent-hook")
+	_, err := UninstallHook("nonexistent-hook", "")
 	if err != nil {
 		t.Errorf("uninstalling nonexistent hook should not error: %v", err)
 	}
 }
+
+func writeForeignHook(t *testing.T, path string) {
+	t.Helper()
+	//nolint:gosec // G306: Test hook must be executable
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho husky\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallHook_BacksUpForeignHook(t *testing.T) {
+	repo := initTestRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	writeForeignHook(t, hookPath)
+
+	result, err := InstallHook("pre-commit", "/path/to/binary", InstallOptions{})
+	if err != nil {
+		t.Fatalf("failed to install hook: %v", err)
+	}
+	if result.BackupPath != hookPath+BackupSuffix {
+		t.Errorf("expected backup at %s, got %q", hookPath+BackupSuffix, result.BackupPath)
+	}
+	if result.ChainTo != "" {
+		t.Error("foreign hook should not be chained without Migrate")
+	}
+
+	backup, err := os.ReadFile(hookPath + BackupSuffix)
+	if err != nil || !strings.Contains(string(backup), "husky") {
+		t.Fatalf("backup should hold the original hook: %v", err)
+	}
+
+	// A second foreign hook must not overwrite the first backup.
+	writeForeignHook(t, hookPath)
+	if _, err := InstallHook("pre-commit", "/path/to/binary", InstallOptions{}); err == nil {
+		t.Error("expected error when a backup already exists")
+	}
+
+	if _, err := InstallHook("pre-commit", "/path/to/binary", InstallOptions{Force: true}); err != nil {
+		t.Fatalf("force install failed: %v", err)
+	}
+
+	uninstalled, err := UninstallHook("pre-commit", "")
+	if err != nil {
+		t.Fatalf("failed to uninstall hook: %v", err)
+	}
+	if !uninstalled.Removed || uninstalled.RestoredFrom == "" {
+		t.Errorf("expected removal and restore, got %+v", uninstalled)
+	}
+
+	restored, err := os.ReadFile(hookPath)
+	if err != nil || !strings.Contains(string(restored), "husky") {
+		t.Errorf("original hook should be restored: %v", err)
+	}
+}
+
+func TestInstallHook_MigrateChains(t *testing.T) {
+	repo := initTestRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	writeForeignHook(t, hookPath)
+
+	result, err := InstallHook("pre-commit", "/path/to/binary", InstallOptions{Migrate: true})
+	if err != nil {
+		t.Fatalf("failed to install hook: %v", err)
+	}
+	if result.ChainTo != hookPath+BackupSuffix {
+		t.Errorf("expected chain to backup, got %q", result.ChainTo)
+	}
+
+	// Reinstalling keeps the chain without needing Migrate again.
+	result, err = InstallHook("pre-commit", "/path/to/binary", InstallOptions{})
+	if err != nil {
+		t.Fatalf("reinstall failed: %v", err)
+	}
+	if result.ChainTo != hookPath+BackupSuffix {
+		t.Errorf("reinstall should keep the chain, got %q", result.ChainTo)
+	}
+}
+
+func TestUninstallHook_LeavesForeignHook(t *testing.T) {
+	repo := initTestRepo(t)
+	hookPath := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	writeForeignHook(t, hookPath)
+
+	result, err := UninstallHook("pre-commit", "")
+	if err != nil {
+		t.Fatalf("failed to uninstall hook: %v", err)
+	}
+	if !result.Foreign || result.Removed {
+		t.Errorf("foreign hook should be skipped, got %+v", result)
+	}
+	if _, err := os.Stat(hookPath); err != nil {
+		t.Error("foreign hook should still exist")
+	}
+}
+
+func TestHooksDir_CoreHooksPath(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "config", "core.hooksPath", ".githooks")
+
+	if got := ConfiguredHooksPath(); got != ".githooks" {
+		t.Errorf("expected .githooks, got %q", got)
+	}
+
+	dir, err := HooksDir()
+	if err != nil {
+		t.Fatalf("HooksDir failed: %v", err)
+	}
+	if !sameDir(t, dir, filepath.Join(repo, ".githooks")) {
+		t.Errorf("expected hooks in .githooks, got %s", dir)
+	}
+
+	def, err := DefaultHooksDir()
+	if err != nil {
+		t.Fatalf("DefaultHooksDir failed: %v", err)
+	}
+	if !sameDir(t, def, filepath.Join(repo, ".git", "hooks")) {
+		t.Errorf("expected default hooks in .git/hooks, got %s", def)
+	}
+}
+
+func TestSetHooksPath_Reset(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "config", "core.hooksPath", ".husky")
+
+	if err := SetHooksPath(".githooks"); err != nil {
+		t.Fatalf("SetHooksPath failed: %v", err)
+	}
+	if err := SetHooksPath(".githooks"); err != nil {
+		t.Fatalf("SetHooksPath failed: %v", err)
+	}
+	if got := ConfiguredHooksPath(); got != ".githooks" {
+		t.Errorf("expected .githooks, got %q", got)
+	}
+
+	reset, previous, err := ResetHooksPath()
+	if err != nil {
+		t.Fatalf("ResetHooksPath failed: %v", err)
+	}
+	if !reset || previous != ".husky" {
+		t.Errorf("expected .husky restored, got reset=%v previous=%q", reset, previous)
+	}
+	if got := ConfiguredHooksPath(); got != ".husky" {
+		t.Errorf("expected .husky, got %q", got)
+	}
+
+	reset, _, err = ResetHooksPath()
+	if err != nil || reset {
+		t.Errorf("second reset should do nothing, got reset=%v err=%v", reset, err)
+	}
+}
+
+func TestSetHooksPath_ResetUnsets(t *testing.T) {
+	initTestRepo(t)
+	if err := SetHooksPath(".githooks"); err != nil {
+		t.Fatalf("SetHooksPath failed: %v", err)
+	}
+
+	reset, previous, err := ResetHooksPath()
+	if err != nil {
+		t.Fatalf("ResetHooksPath failed: %v", err)
+	}
+	if !reset || previous != "" {
+		t.Errorf("expected core.hooksPath unset, got reset=%v previous=%q", reset, previous)
+	}
+	if got := ConfiguredHooksPath(); got != "" {
+		t.Errorf("expected no core.hooksPath, got %q", got)
+	}
+}
+
+func TestHooksDir_Worktree(t *testing.T) {
+	repo := initTestRepo(t)
+	worktree := filepath.Join(t.TempDir(), "wt")
+	runGit(t, repo, "worktree", "add", "-b", "wt", worktree)
+	t.Chdir(worktree)
+
+	dir, err := HooksDir()
+	if err != nil {
+		t.Fatalf("HooksDir failed: %v", err)
+	}
+	if !sameDir(t, dir, filepath.Join(repo, ".git", "hooks")) {
+		t.Errorf("worktree should share the main hooks dir, got %s", dir)
+	}
+}
+
+func sameDir(t *testing.T, a, b string) bool {
+	t.Helper()
+	ra, errA := filepath.EvalSymlinks(filepath.Dir(a))
+	rb, errB := filepath.EvalSymlinks(filepath.Dir(b))
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return filepath.Join(ra, filepath.Base(a)) == filepath.Join(rb, filepath.Base(b))
+}
+
+func TestGetRangeChanges(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "checkout", "-b", "feature")
+	commitFile(t, repo, "feature.go", "package feature", "feat: feature")
+	runGit(t, repo, "checkout", "main")
+	commitFile(t, repo, "main.go", "package main", "feat: main")
+
+	changes, err := GetRangeChanges("main", "feature")
+	if err != nil {
+		t.Fatalf("GetRangeChanges failed: %v", err)
+	}
+	if files := changes.Files(); len(files) != 1 || files[0] != "feature.go" {
+		t.Errorf("expected only feature.go from merge-base, got %v", files)
+	}
+}
+
+func TestGetCommitChanges_RootCommit(t *testing.T) {
+	initTestRepo(t)
+
+	changes, err := GetCommitChanges("HEAD")
+	if err != nil {
+		t.Fatalf("GetCommitChanges failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "README.md" || changes[0].Status != StatusAdded {
+		t.Errorf("expected README.md added, got %+v", changes)
+	}
+}
@@ -0,0 +1,110 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// weirdNames are paths git would quote or that a line-based parser would
+// split: spaces, quotes, non-ASCII, a leading dash and an embedded newline.
+var weirdNames = []string{
+	"with space.go",
+	`quote"d.go`,
+	"ünïcödé/日本.go",
+	"-leading-dash.go",
+	"new\nline.go",
+}
+
+func writeWeirdFiles(t *testing.T, repo, content string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("filenames with quotes and newlines are not valid on Windows")
+	}
+	for _, name := range weirdNames {
+		path := filepath.Join(repo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, repo, "add", "-A")
+}
+
+func assertWeirdNames(t *testing.T, label string, got []string) {
+	t.Helper()
+	for _, name := range weirdNames {
+		if !slices.Contains(got, name) {
+			t.Errorf("%s: missing %q in %q", label, name, got)
+		}
+	}
+}
+
+func TestWeirdFilenames(t *testing.T) {
+	repo := initTestRepo(t)
+	// Quoting is git's default; make sure nothing relies on it being off.
+	runGit(t, repo, "config", "core.quotePath", "true")
+	base := runGit(t, repo, "rev-parse", "HEAD")
+
+	writeWeirdFiles(t, repo, "secret\n")
+
+	changes, err := GetStagedChanges()
+	if err != nil {
+		t.Fatalf("GetStagedChanges failed: %v", err)
+	}
+	assertWeirdNames(t, "GetStagedChanges", changes.Files())
+
+	added, err := GetStagedAddedLines()
+	if err != nil {
+		t.Fatalf("GetStagedAddedLines failed: %v", err)
+	}
+	for _, name := range weirdNames {
+		if lines := added[name]; len(lines) != 1 || lines[0].Text != "secret" {
+			t.Errorf("GetStagedAddedLines: unexpected lines for %q: %+v", name, lines)
+		}
+	}
+
+	index := NewBlobSource(repo, "")
+	defer index.Close()
+	for _, name := range weirdNames {
+		if data, err := index.ReadFile(name); err != nil || string(data) != "secret\n" {
+			t.Errorf("BlobSource: reading %q gave %q, %v", name, data, err)
+		}
+	}
+
+	runGit(t, repo, "commit", "-m", "weird names")
+
+	all, err := GetAllFiles()
+	if err != nil {
+		t.Fatalf("GetAllFiles failed: %v", err)
+	}
+	assertWeirdNames(t, "GetAllFiles", all)
+
+	commit, err := GetCommitChanges("HEAD")
+	if err != nil {
+		t.Fatalf("GetCommitChanges failed: %v", err)
+	}
+	assertWeirdNames(t, "GetCommitChanges", commit.Files())
+
+	ranged, err := GetRangeChanges(base, "HEAD")
+	if err != nil {
+		t.Fatalf("GetRangeChanges failed: %v", err)
+	}
+	assertWeirdNames(t, "GetRangeChanges", ranged.Files())
+
+	refs := []PushRef{{
+		LocalRef:  "refs/heads/main",
+		LocalSHA:  runGit(t, repo, "rev-parse", "HEAD"),
+		RemoteRef: "refs/heads/main",
+		RemoteSHA: base,
+	}}
+	pushed, err := GetPushedChanges(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedChanges failed: %v", err)
+	}
+	assertWeirdNames(t, "GetPushedChanges", pushed.Files())
+}
@@ -0,0 +1,158 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// PushRef is one "<local ref> <local sha> <remote ref> <remote sha>" line
+// that git writes to the pre-push hook's stdin.
+type PushRef struct {
+	LocalRef  string
+	LocalSHA  string
+	RemoteRef string
+	RemoteSHA string
+}
+
+// IsDelete reports whether the push removes the remote ref.
+func (r PushRef) IsDelete() bool {
+	return isZeroSHA(r.LocalSHA)
+}
+
+// IsNew reports whether the push creates the remote ref.
+func (r PushRef) IsNew() bool {
+	return isZeroSHA(r.RemoteSHA)
+}
+
+// Commit is a pushed commit and its full message.
+type Commit struct {
+	SHA     string
+	Message string
+}
+
+// isZeroSHA matches the all-zero object name git uses for a ref that does
+// not exist on one side of the push.
+func isZeroSHA(sha string) bool {
+	return sha == "" || strings.Trim(sha, "0") == ""
+}
+
+// ParsePushRefs reads the pre-push ref lines from r.
+func ParsePushRefs(r io.Reader) ([]PushRef, error) {
+	var refs []PushRef
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid pre-push line: %q", line)
+		}
+		refs = append(refs, PushRef{
+			LocalRef:  fields[0],
+			LocalSHA:  fields[1],
+			RemoteRef: fields[2],
+			RemoteSHA: fields[3],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pushed refs: %w", err)
+	}
+	return refs, nil
+}
+
+// GetPushedCommits returns the commits being pushed, newest first within
+// each ref, so their messages can be checked individually.
+func GetPushedCommits(refs []PushRef, remote string) ([]Commit, error) {
+	seen := make(map[string]bool)
+	var commits []Commit
+
+	for _, ref := range refs {
+		if ref.IsDelete() {
+			continue
+		}
+
+		revs := unpushedRevs(ref, remote)
+		if base := pushBase(ref, remote); base != "" {
+			revs = []string{base + ".." + ref.LocalSHA}
+		}
+		args := append([]string{"log", "--format=%H%x00%B%x00"}, revs...)
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pushed commits for %s: %w", ref.LocalRef, err)
+		}
+
+		parts := strings.Split(string(out), "\x00")
+		for i := 0; i+1 < len(parts); i += 2 {
+			sha := strings.TrimSpace(parts[i])
+			if sha == "" || seen[sha] {
+				continue
+			}
+			seen[sha] = true
+			commits = append(commits, Commit{SHA: sha, Message: strings.TrimSpace(parts[i+1])})
+		}
+	}
+	return commits, nil
+}
+
+// pushBase returns the commit the pushed ref should be compared against.
+// Existing refs use the remote tip. New refs, and remote tips we don't have
+// locally, use their merge-base with the remote default branch. An empty
+// result means no base could be determined.
+func pushBase(ref PushRef, remote string) string {
+	if !ref.IsNew() && commitExists(ref.RemoteSHA) {
+		return ref.RemoteSHA
+	}
+
+	base := defaultBranch(remote)
+	if base == "" {
+		return ""
+	}
+	//nolint:gosec // G204: arguments are object names, not shell input
+	out, err := exec.Command("git", "merge-base", ref.LocalSHA, base).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// unpushedRevs selects every commit reachable from the pushed ref that is
+// not on any ref of the remote, for pushes without a usable base.
+func unpushedRevs(ref PushRef, remote string) []string {
+	if remote == "" {
+		remote = "origin"
+	}
+	return []string{ref.LocalSHA, "--not", "--remotes=" + remote}
+}
+
+func commitExists(sha string) bool {
+	//nolint:gosec // G204: sha comes from git's own pre-push input
+	return exec.Command("git", "cat-file", "-e", sha+"^{commit}").Run() == nil
+}
+
+// defaultBranch resolves the remote's default branch as a local
+// remote-tracking ref, or "" when it can't be determined.
+func defaultBranch(remote string) string {
+	if remote == "" {
+		remote = "origin"
+	}
+
+	out, err := exec.Command("git", "symbolic-ref", "--quiet", "refs/remotes/"+remote+"/HEAD").Output()
+	if err == nil {
+		if ref := strings.TrimSpace(string(out)); ref != "" {
+			return ref
+		}
+	}
+
+	for _, name := range []string{"main", "master"} {
+		ref := "refs/remotes/" + remote + "/" + name
+		if exec.Command("git", "rev-parse", "--verify", "--quiet", ref).Run() == nil {
+			return ref
+		}
+	}
+	return ""
+}
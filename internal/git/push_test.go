@@ -0,0 +1,163 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initTestRepo creates a repository with a bare "origin" remote whose main
+// branch already holds one commit, and makes it the working directory.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	base := t.TempDir()
+	remote := filepath.Join(base, "remote.git")
+	repo := filepath.Join(base, "repo")
+
+	runGit(t, base, "init", "--bare", "-b", "main", remote)
+	runGit(t, base, "init", "-b", "main", repo)
+	runGit(t, repo, "remote", "add", "origin", remote)
+	commitFile(t, repo, "README.md", "hello", "initial")
+	runGit(t, repo, "push", "-u", "origin", "main")
+
+	t.Chdir(repo)
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commitFile(t *testing.T, dir, name, content, msg string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-m", msg)
+	return runGit(t, dir, "rev-parse", "HEAD")
+}
+
+func TestParsePushRefs(t *testing.T) {
+	input := "refs/heads/main abc123 refs/heads/main def456\n\n" +
+		"(delete) 0000000000000000000000000000000000000000 refs/heads/old 123abc\n"
+
+	refs, err := ParsePushRefs(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePushRefs failed: %v", err)
+	}
+
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 refs, got %d", len(refs))
+	}
+	if refs[0].LocalSHA != "abc123" || refs[0].RemoteRef != "refs/heads/main" {
+		t.Errorf("unexpected first ref: %+v", refs[0])
+	}
+	if refs[0].IsDelete() || refs[0].IsNew() {
+		t.Error("first ref should be an update")
+	}
+	if !refs[1].IsDelete() {
+		t.Error("second ref should be a delete")
+	}
+}
+
+func TestParsePushRefs_Invalid(t *testing.T) {
+	if _, err := ParsePushRefs(strings.NewReader("refs/heads/main abc123\n")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestGetPushedChanges_ExistingBranch(t *testing.T) {
+	repo := initTestRepo(t)
+	remoteSHA := runGit(t, repo, "rev-parse", "origin/main")
+	commitFile(t, repo, "a.go", "package a", "feat: add a")
+	localSHA := commitFile(t, repo, "b.go", "package b", "feat: add b")
+
+	refs := []PushRef{{
+		LocalRef:  "refs/heads/main",
+		LocalSHA:  localSHA,
+		RemoteRef: "refs/heads/main",
+		RemoteSHA: remoteSHA,
+	}}
+
+	changes, err := GetPushedChanges(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedChanges failed: %v", err)
+	}
+	if files := changes.Files(); strings.Join(files, ",") != "a.go,b.go" {
+		t.Errorf("expected [a.go b.go], got %v", files)
+	}
+
+	commits, err := GetPushedCommits(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(commits))
+	}
+	if commits[0].SHA != localSHA || commits[0].Message != "feat: add b" {
+		t.Errorf("unexpected newest commit: %+v", commits[0])
+	}
+}
+
+func TestGetPushedChanges_NewBranch(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "remote", "set-head", "origin", "main")
+	runGit(t, repo, "checkout", "-b", "feature")
+	localSHA := commitFile(t, repo, "pkg/feature.go", "package pkg", "feat: feature")
+
+	refs := []PushRef{{
+		LocalRef:  "refs/heads/feature",
+		LocalSHA:  localSHA,
+		RemoteRef: "refs/heads/feature",
+		RemoteSHA: strings.Repeat("0", 40),
+	}}
+
+	changes, err := GetPushedChanges(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedChanges failed: %v", err)
+	}
+	if files := changes.Files(); len(files) != 1 || files[0] != "pkg/feature.go" {
+		t.Errorf("expected only pkg/feature.go, got %v", files)
+	}
+}
+
+func TestGetPushedChanges_Delete(t *testing.T) {
+	repo := initTestRepo(t)
+	remoteSHA := runGit(t, repo, "rev-parse", "origin/main")
+
+	refs := []PushRef{{
+		LocalRef:  "(delete)",
+		LocalSHA:  strings.Repeat("0", 40),
+		RemoteRef: "refs/heads/main",
+		RemoteSHA: remoteSHA,
+	}}
+
+	changes, err := GetPushedChanges(refs, "origin")
+	if err != nil {
+		t.Fatalf("GetPushedChanges failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes for a deletion, got %+v", changes)
+	}
+}
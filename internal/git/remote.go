@@ -0,0 +1,111 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ShowFile returns the content of path at ref in the repository at
+// repoDir. An empty ref means HEAD.
+func ShowFile(repoDir, ref, path string) ([]byte, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	//nolint:gosec // G204: arguments are a ref and a path, not shell input
+	cmd := exec.Command("git", "-C", repoDir, "show", ref+":"+path)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s in %s: %w", path, ref, repoDir, err)
+	}
+	return out, nil
+}
+
+// ResolveCommit returns the commit ref points to in the repository at
+// repoDir. An empty ref means HEAD.
+func ResolveCommit(repoDir, ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	//nolint:gosec // G204: argument is a ref, not shell input
+	out, err := exec.Command("git", "-C", repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown ref %q in %s", ref, repoDir)
+	}
+	return trimNewline(out), nil
+}
+
+// FetchPinned makes ref of the remote repository at url available in a
+// bare clone under cacheDir and returns the clone's directory and the
+// pinned commit name to read from. Tags and full commit hashes are fetched
+// once and then served from the cache. Other refs, such as branches, and
+// an empty ref, meaning the remote's HEAD, move, so they are fetched again
+// on every call.
+func FetchPinned(url, ref, cacheDir string) (string, string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(sum[:8]))
+	refSum := sha256.Sum256([]byte(ref))
+	name := hex.EncodeToString(refSum[:8])
+
+	cached := "refs/pinned/tags/" + name
+	if isCommitHash(ref) {
+		cached = "refs/pinned/commits/" + name
+	}
+	if _, err := ResolveCommit(dir, cached); err == nil {
+		return dir, cached, nil
+	}
+
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", "", err
+		}
+		if out, err := exec.Command("git", "init", "--quiet", "--bare", dir).CombinedOutput(); err != nil {
+			return "", "", fmt.Errorf("failed to create include cache: %w\n%s", err, out)
+		}
+	}
+
+	//nolint:gosec // G204: url and ref come from the config, passed as separate arguments
+	fetch := exec.Command("git", "-C", dir, "fetch", "--quiet", "--depth=1", "--", url, ref)
+	if out, err := fetch.CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to fetch %s from %s: %w\n%s", ref, url, err, out)
+	}
+
+	pinned := cached
+	if !isCommitHash(ref) && !fetchedTag(dir) {
+		pinned = "refs/fetched/" + name
+	}
+	//nolint:gosec // G204: pinned is derived from a hash
+	if out, err := exec.Command("git", "-C", dir, "update-ref", pinned, "FETCH_HEAD").CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("failed to pin %s: %w\n%s", ref, err, out)
+	}
+	return dir, pinned, nil
+}
+
+// isCommitHash reports whether ref is a full SHA-1 or SHA-256 commit hash.
+func isCommitHash(ref string) bool {
+	if len(ref) != 40 && len(ref) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(ref)
+	return err == nil
+}
+
+// fetchedTag reports whether the last fetch into the bare repository at
+// dir fetched a tag, which git records in FETCH_HEAD.
+func fetchedTag(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "FETCH_HEAD"))
+	if err != nil {
+		return false
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	_, desc, _ := strings.Cut(line, "\t\t")
+	return strings.HasPrefix(desc, "tag '")
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestShowFileAndResolveCommit(t *testing.T) {
+	repo := initTestRepo(t)
+	first := runGit(t, repo, "rev-parse", "HEAD")
+	commitFile(t, repo, "README.md", "changed", "second")
+
+	data, err := ShowFile(repo, first, "README.md")
+	if err != nil {
+		t.Fatalf("ShowFile failed: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected content at first commit, got %q", data)
+	}
+
+	head, err := ResolveCommit(repo, "")
+	if err != nil || head == first {
+		t.Errorf("expected HEAD to resolve to the second commit, got %q, %v", head, err)
+	}
+	if _, err := ResolveCommit(repo, "no-such-ref"); err == nil {
+		t.Error("expected error for unknown ref")
+	}
+}
+
+func TestFetchPinned(t *testing.T) {
+	repo := initTestRepo(t)
+	runGit(t, repo, "tag", "v1")
+	runGit(t, repo, "push", "origin", "v1")
+	remote := "file://" + filepath.Join(filepath.Dir(repo), "remote.git")
+	cache := t.TempDir()
+
+	dir, pinned, err := FetchPinned(remote, "v1", cache)
+	if err != nil {
+		t.Fatalf("FetchPinned failed: %v", err)
+	}
+	data, err := ShowFile(dir, pinned, "README.md")
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected pinned README, got %q, %v", data, err)
+	}
+
+	// Later changes to the remote are not picked up: the ref stays pinned.
+	commitFile(t, repo, "README.md", "changed", "second")
+	runGit(t, repo, "tag", "-f", "v1")
+	runGit(t, repo, "push", "-f", "origin", "v1")
+
+	dir2, pinned2, err := FetchPinned(remote, "v1", cache)
+	if err != nil || dir2 != dir || pinned2 != pinned {
+		t.Fatalf("expected cached pin, got %s %s, %v", dir2, pinned2, err)
+	}
+	if data, _ := ShowFile(dir2, pinned2, "README.md"); string(data) != "hello" {
+		t.Errorf("expected cached content, got %q", data)
+	}
+}
+
+func TestFetchPinned_Branch(t *testing.T) {
+	repo := initTestRepo(t)
+	remote := "file://" + filepath.Join(filepath.Dir(repo), "remote.git")
+	cache := t.TempDir()
+	branch := runGit(t, repo, "rev-parse", "--abbrev-ref", "HEAD")
+
+	for _, ref := range []string{"", branch} {
+		dir, pinned, err := FetchPinned(remote, ref, cache)
+		if err != nil {
+			t.Fatalf("FetchPinned(%q) failed: %v", ref, err)
+		}
+		if data, _ := ShowFile(dir, pinned, "README.md"); string(data) != "hello" {
+			t.Errorf("FetchPinned(%q): expected README, got %q", ref, data)
+		}
+	}
+
+	// Branches and the remote HEAD move, so they are fetched again.
+	commitFile(t, repo, "README.md", "changed", "second")
+	runGit(t, repo, "push", "origin", branch)
+
+	for _, ref := range []string{"", branch} {
+		dir, pinned, err := FetchPinned(remote, ref, cache)
+		if err != nil {
+			t.Fatalf("FetchPinned(%q) failed: %v", ref, err)
+		}
+		if data, _ := ShowFile(dir, pinned, "README.md"); string(data) != "changed" {
+			t.Errorf("FetchPinned(%q): expected the new commit, got %q", ref, data)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ashavijit/hookrunner/internal/git"
+)
+
+// Status values recorded for a hook run.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	StatusBlocked = "blocked"
+)
+
+// Entry is what is remembered about one hook between runs. Durations are
+// only updated when the hook actually ran.
+type Entry struct {
+	LastMs  int64     `json:"last_ms"`
+	AvgMs   int64     `json:"avg_ms"`
+	Runs    int       `json:"runs"`
+	Status  string    `json:"status"`
+	LastRun time.Time `json:"last_run"`
+}
+
+// History stores per-hook durations and statuses under
+// .hookrunner/history.json, keyed by hook type and hook name.
+type History struct {
+	dir   string
+	path  string
+	mu    sync.Mutex
+	Hooks map[string]map[string]Entry `json:"hooks"`
+}
+
+// Load reads the history kept under dir. A missing or corrupt file gives
+// an empty history.
+func Load(dir string) *History {
+	h := &History{dir: dir, path: filepath.Join(dir, ".hookrunner", "history.json")}
+	if data, err := os.ReadFile(h.path); err == nil {
+		_ = json.Unmarshal(data, h) //nolint:errcheck // a corrupt history is just forgotten
+	}
+	if h.Hooks == nil {
+		h.Hooks = make(map[string]map[string]Entry)
+	}
+	return h
+}
+
+// Lookup returns the entry for a hook.
+func (h *History) Lookup(hookType, name string) (Entry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.Hooks[hookType][name]
+	return e, ok
+}
+
+// Estimate returns the expected duration of a hook, based on its average
+// over previous runs.
+func (h *History) Estimate(hookType, name string) (time.Duration, bool) {
+	e, ok := h.Lookup(hookType, name)
+	if !ok || e.Runs == 0 {
+		return 0, false
+	}
+	return time.Duration(e.AvgMs) * time.Millisecond, true
+}
+
+// Record stores the outcome of a hook run. For passed and failed hooks the
+// duration is folded into the running average, weighting recent runs more.
+func (h *History) Record(hookType, name, status string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hooks := h.Hooks[hookType]
+	if hooks == nil {
+		hooks = make(map[string]Entry)
+		h.Hooks[hookType] = hooks
+	}
+
+	e := hooks[name]
+	e.Status = status
+	e.LastRun = time.Now()
+	if status == StatusPassed || status == StatusFailed {
+		ms := d.Milliseconds()
+		if e.Runs == 0 {
+			e.AvgMs = ms
+		} else {
+			e.AvgMs = (2*e.AvgMs + ms) / 3
+		}
+		e.LastMs = ms
+		e.Runs++
+	}
+	hooks[name] = e
+}
+
+// Save writes the history back to disk, keeping .hookrunner/ out of git
+// status.
+func (h *History) Save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	git.IgnoreHookRunnerDir(h.dir)
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	h := Load(t.TempDir())
+	if _, ok := h.Estimate("pre-commit", "lint"); ok {
+		t.Error("expected no estimate for an empty history")
+	}
+}
+
+func TestRecord_SaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	h := Load(dir)
+	h.Record("pre-commit", "lint", StatusPassed, 300*time.Millisecond)
+	h.Record("pre-commit", "lint", StatusFailed, 600*time.Millisecond)
+	h.Record("pre-commit", "test", StatusSkipped, time.Millisecond)
+	if err := h.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := Load(dir)
+	e, ok := loaded.Lookup("pre-commit", "lint")
+	if !ok {
+		t.Fatal("expected lint entry")
+	}
+	if e.Runs != 2 || e.LastMs != 600 || e.AvgMs != 400 || e.Status != StatusFailed {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if d, ok := loaded.Estimate("pre-commit", "lint"); !ok || d != 400*time.Millisecond {
+		t.Errorf("expected 400ms estimate, got %v", d)
+	}
+
+	if _, ok := loaded.Estimate("pre-commit", "test"); ok {
+		t.Error("skipped hooks should not get a duration estimate")
+	}
+	if e, _ := loaded.Lookup("pre-commit", "test"); e.Status != StatusSkipped {
+		t.Errorf("expected skipped status, got %q", e.Status)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".hookrunner", ".gitignore")); err != nil {
+		t.Errorf("Save should keep .hookrunner/ out of git status: %v", err)
+	}
+}
+
+func TestLoad_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".hookrunner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".hookrunner", "history.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h := Load(dir)
+	h.Record("pre-push", "test", StatusPassed, time.Second)
+	if _, ok := h.Estimate("pre-push", "test"); !ok {
+		t.Error("expected estimate after recording")
+	}
+}
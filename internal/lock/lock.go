@@ -0,0 +1,439 @@
+// Package lock reads and writes hooks.lock, which pins the content of
+// everything a config pulls from elsewhere: tool downloads for each
+// platform, remote policies and included config files.
+package lock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/policy"
+	"github.com/ashavijit/hookrunner/internal/tool"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the lockfile, kept next to the root config.
+const FileName = "hooks.lock"
+
+// Version is the lockfile format written by this version of HookRunner.
+const Version = 1
+
+const header = "# Generated by 'hookrunner lock'. Do not edit; run 'hookrunner lock --update' to refresh.\n"
+
+// arches are the architectures tool downloads are locked for on each OS
+// a tool can be installed on, besides the running one.
+var arches = []string{"amd64", "arm64"}
+
+// Lock is the content of hooks.lock.
+type Lock struct {
+	Version int `yaml:"version"`
+	// Tools are the managed tools by name.
+	Tools map[string]Tool `yaml:"tools,omitempty"`
+	// Policies are the SHA-256 sums of remote policies by URL.
+	Policies map[string]string `yaml:"policies,omitempty"`
+	// Includes are the included config files, by path relative to the
+	// repository root or repo@ref:path for files read from git.
+	Includes map[string]Include `yaml:"includes,omitempty"`
+}
+
+// Tool is a locked tool: its version and the download for each platform,
+// keyed by os/arch.
+type Tool struct {
+	Version   string              `yaml:"version"`
+	Platforms map[string]Artifact `yaml:"platforms"`
+}
+
+// Artifact is a tool download.
+type Artifact struct {
+	URL    string `yaml:"url"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// Include is a locked include. Commit is set for files read from git.
+type Include struct {
+	SHA256 string `yaml:"sha256"`
+	Commit string `yaml:"commit,omitempty"`
+}
+
+// Resolver computes the SHA-256 of tool downloads and remote policies.
+// ToolSum returns an error wrapping tool.ErrNotFound when a download does
+// not exist, which leaves that platform out of the lock.
+type Resolver interface {
+	ToolSum(url string) (string, error)
+	PolicySum(url string) (string, error)
+}
+
+type netResolver struct {
+	fetcher *policy.Fetcher
+}
+
+// NewResolver returns a Resolver that downloads tools and policies. The
+// policies are also stored in the policy cache under cacheDir, so runs
+// after locking do not fetch them again.
+func NewResolver(cacheDir string) Resolver {
+	return netResolver{fetcher: policy.NewFetcher(cacheDir)}
+}
+
+func (r netResolver) ToolSum(url string) (string, error) {
+	return tool.DownloadSum(url)
+}
+
+func (r netResolver) PolicySum(url string) (string, error) {
+	data, err := r.fetcher.Fetch(url)
+	if err != nil {
+		return "", err
+	}
+	return hash(data), nil
+}
+
+// Platform returns the os/arch key of the running platform.
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Read reads the lockfile in dir. It returns nil if there is none.
+func Read(dir string) (*Lock, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	var l Lock
+	if err := yaml.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	if l.Version > Version {
+		return nil, fmt.Errorf("%s has version %d, newer than this hookrunner supports (%d)", FileName, l.Version, Version)
+	}
+	return &l, nil
+}
+
+// Write writes the lockfile to dir.
+func (l *Lock) Write(dir string) error {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(l); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), buf.Bytes(), 0644); err != nil { //nolint:gosec // the lockfile is committed
+		return fmt.Errorf("failed to write %s: %w", FileName, err)
+	}
+	return nil
+}
+
+// Generate locks the tools, remote policies, including those of every
+// profile, and includes of cfg, whose root config is in root.
+func Generate(cfg *config.Config, root string, r Resolver) (*Lock, error) {
+	l := &Lock{Version: Version}
+
+	sums := make(map[string]string)
+	for _, name := range toolNames(cfg) {
+		t := cfg.Tools[name]
+		lt := Tool{Version: t.Version, Platforms: make(map[string]Artifact)}
+		for _, p := range platforms(t) {
+			url, err := toolURL(t, root, p)
+			if err != nil {
+				return nil, fmt.Errorf("tool %s: %w", name, err)
+			}
+			sum, ok := sums[url]
+			if !ok {
+				sum, err = r.ToolSum(url)
+				if errors.Is(err, tool.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("tool %s (%s): %w", name, p, err)
+				}
+				sums[url] = sum
+			}
+			lt.Platforms[p] = Artifact{URL: url, SHA256: sum}
+		}
+		if len(lt.Platforms) == 0 {
+			return nil, fmt.Errorf("tool %s: no download found for any platform", name)
+		}
+		if l.Tools == nil {
+			l.Tools = make(map[string]Tool)
+		}
+		l.Tools[name] = lt
+	}
+
+	for _, url := range policyURLs(cfg) {
+		sum, err := r.PolicySum(url)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", url, err)
+		}
+		if l.Policies == nil {
+			l.Policies = make(map[string]string)
+		}
+		l.Policies[url] = sum
+	}
+
+	for src, d := range cfg.Digests {
+		if l.Includes == nil {
+			l.Includes = make(map[string]Include)
+		}
+		l.Includes[includeKey(root, src)] = Include{SHA256: d.SHA256, Commit: d.Commit}
+	}
+	return l, nil
+}
+
+// Verify compares cfg with the lock without downloading anything and
+// returns what drifted: tools, policies and includes added, removed or
+// changed since the lock was written. The content of tools and policies
+// is checked when they are downloaded, against ToolSum and Policies.
+func (l *Lock) Verify(cfg *config.Config, root string) []string {
+	var drift []string
+
+	for _, name := range toolNames(cfg) {
+		t := cfg.Tools[name]
+		lt, ok := l.Tools[name]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("tool %s is not locked", name))
+		case lt.Version != t.Version:
+			drift = append(drift, fmt.Sprintf("tool %s is %s in the config but %s in %s", name, t.Version, lt.Version, FileName))
+		default:
+			if _, ok := t.Install[runtime.GOOS]; !ok {
+				continue
+			}
+			url, err := toolURL(t, root, Platform())
+			if err != nil {
+				drift = append(drift, fmt.Sprintf("tool %s: %v", name, err))
+				continue
+			}
+			if a, ok := lt.Platforms[Platform()]; !ok {
+				drift = append(drift, fmt.Sprintf("tool %s is not locked for %s", name, Platform()))
+			} else if a.URL != url {
+				drift = append(drift, fmt.Sprintf("tool %s download for %s changed to %s", name, Platform(), url))
+			}
+		}
+	}
+	for name := range l.Tools {
+		if t, ok := cfg.Tools[name]; !ok || len(t.Install) == 0 {
+			drift = append(drift, fmt.Sprintf("tool %s is locked but no longer configured", name))
+		}
+	}
+
+	urls := make(map[string]bool)
+	for _, url := range policyURLs(cfg) {
+		urls[url] = true
+		if _, ok := l.Policies[url]; !ok {
+			drift = append(drift, fmt.Sprintf("policy %s is not locked", url))
+		}
+	}
+	for url := range l.Policies {
+		if !urls[url] {
+			drift = append(drift, fmt.Sprintf("policy %s is locked but no longer configured", url))
+		}
+	}
+
+	includes := make(map[string]bool)
+	for src, d := range cfg.Digests {
+		key := includeKey(root, src)
+		includes[key] = true
+		li, ok := l.Includes[key]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("include %s is not locked", key))
+		case li.Commit != d.Commit:
+			drift = append(drift, fmt.Sprintf("include %s moved from commit %s to %s", key, short(li.Commit), short(d.Commit)))
+		case li.SHA256 != d.SHA256:
+			drift = append(drift, fmt.Sprintf("include %s changed", key))
+		}
+	}
+	for key := range l.Includes {
+		if !includes[key] {
+			drift = append(drift, fmt.Sprintf("include %s is locked but no longer included", key))
+		}
+	}
+
+	sort.Strings(drift)
+	return drift
+}
+
+// ToolSum returns the locked SHA-256 of the download of a tool for the
+// running platform, if it was locked with the download url.
+func (l *Lock) ToolSum(name, url string) (string, bool) {
+	a, ok := l.Tools[name].Platforms[Platform()]
+	if !ok || a.URL != url {
+		return "", false
+	}
+	return a.SHA256, true
+}
+
+// Diff returns what differs between an old and a new lock, one line per
+// tool platform, policy or include.
+func Diff(old, updated *Lock) []string {
+	before, after := old.entries(), updated.entries()
+	var changes []string
+	for key, v := range after {
+		if was, ok := before[key]; !ok {
+			changes = append(changes, "added "+key)
+		} else if was != v {
+			changes = append(changes, "changed "+key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, "removed "+key)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return entryKey(changes[i]) < entryKey(changes[j])
+	})
+	return changes
+}
+
+// entries flattens the lock into a value for each thing it locks.
+func (l *Lock) entries() map[string]string {
+	m := make(map[string]string)
+	if l == nil {
+		return m
+	}
+	for name, t := range l.Tools {
+		for p, a := range t.Platforms {
+			m[fmt.Sprintf("tool %s %s (%s)", name, t.Version, p)] = a.URL + " " + a.SHA256
+		}
+	}
+	for url, sum := range l.Policies {
+		m["policy "+url] = sum
+	}
+	for key, inc := range l.Includes {
+		m["include "+key] = inc.SHA256 + " " + inc.Commit
+	}
+	return m
+}
+
+// entryKey is a Diff line without its leading verb, to sort by.
+func entryKey(change string) string {
+	_, key, _ := strings.Cut(change, " ")
+	return key
+}
+
+func toolNames(cfg *config.Config) []string {
+	var names []string
+	for name, t := range cfg.Tools {
+		if len(t.Install) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// platforms returns the os/arch keys a tool is locked for: each OS it has
+// a download for, with each of arches and the running architecture.
+func platforms(t config.Tool) []string {
+	archs := arches
+	if !contains(archs, runtime.GOARCH) {
+		archs = append(append([]string{}, arches...), runtime.GOARCH)
+	}
+	var ps []string
+	for goos := range t.Install {
+		for _, arch := range archs {
+			ps = append(ps, goos+"/"+arch)
+		}
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// toolURL returns the download URL of a tool for an os/arch platform.
+func toolURL(t config.Tool, root, platform string) (string, error) {
+	goos, arch, _ := strings.Cut(platform, "/")
+	expanded, err := t.Interpolate(config.Vars{RepoRoot: root, OS: goos, Arch: arch})
+	if err != nil {
+		return "", err
+	}
+	return expanded.Install[goos], nil
+}
+
+// policyURLs returns the remote policies of cfg and of all its profiles.
+func policyURLs(cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	add := func(p *config.Policies) {
+		if p == nil {
+			return
+		}
+		for _, ref := range p.Policies {
+			if !seen[ref.URL] {
+				seen[ref.URL] = true
+				urls = append(urls, ref.URL)
+			}
+		}
+	}
+	add(cfg.Policies)
+	for _, name := range cfg.ProfileNames() {
+		add(cfg.Profiles[name].Policies)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// includeKey returns how an included source is named in the lock: working
+// tree files relative to root, so the lock is the same in every checkout.
+func includeKey(root, src string) string {
+	if !filepath.IsAbs(src) {
+		return src
+	}
+	if rel, ok := relPath(root, src); ok {
+		return rel
+	}
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		if s, err := filepath.EvalSymlinks(src); err == nil {
+			if rel, ok := relPath(r, s); ok {
+				return rel
+			}
+		}
+	}
+	return filepath.ToSlash(src)
+}
+
+func relPath(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func short(commit string) string {
+	if commit == "" {
+		return "(none)"
+	}
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"github.com/ashavijit/hookrunner/internal/tool"
+)
+
+// fakeResolver hashes URLs instead of downloading them. Tool URLs
+// containing "missing" are not found.
+type fakeResolver struct{}
+
+func (r fakeResolver) ToolSum(url string) (string, error) {
+	if strings.Contains(url, "missing") {
+		return "", fmt.Errorf("%s: %w", url, tool.ErrNotFound)
+	}
+	return hash([]byte(url)), nil
+}
+
+func (r fakeResolver) PolicySum(url string) (string, error) {
+	return hash([]byte(url)), nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func load(t *testing.T, dir string) *config.Config {
+	t.Helper()
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+const testConfig = `include: [shared.yaml]
+tools:
+  lint:
+    version: "1.0"
+    install:
+      linux: https://example.com/lint-${version}-linux-${arch}.tar.gz
+      windows: https://example.com/missing-${arch}.zip
+policies:
+  policies:
+    - url: https://example.com/team.yaml
+profiles:
+  ci:
+    policies:
+      policies:
+        - url: https://example.com/ci.yaml
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "hooks.yaml", testConfig)
+	writeFile(t, dir, "shared.yaml", "hooks:\n  pre-commit:\n    - name: fmt\n      run: gofmt -l .\n")
+
+	l, err := Generate(load(t, dir), dir, fakeResolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lint := l.Tools["lint"]
+	if lint.Version != "1.0" {
+		t.Errorf("lint version = %q", lint.Version)
+	}
+	a, ok := lint.Platforms["linux/arm64"]
+	if !ok || a.URL != "https://example.com/lint-1.0-linux-arm64.tar.gz" || len(a.SHA256) != 64 {
+		t.Errorf("linux/arm64 = %+v", a)
+	}
+	if _, ok := lint.Platforms["windows/amd64"]; ok {
+		t.Error("a download that was not found should not be locked")
+	}
+	if len(l.Policies) != 2 || l.Policies["https://example.com/ci.yaml"] == "" {
+		t.Errorf("policies = %v, want the config's and the ci profile's", l.Policies)
+	}
+	if inc, ok := l.Includes["shared.yaml"]; !ok || len(inc.SHA256) != 64 || inc.Commit != "" {
+		t.Errorf("includes = %+v", l.Includes)
+	}
+
+	if err := l.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Diff(l, read); len(changes) != 0 {
+		t.Errorf("lock changed when written and read back: %v", changes)
+	}
+}
+
+func TestRead_Missing(t *testing.T) {
+	l, err := Read(t.TempDir())
+	if l != nil || err != nil {
+		t.Errorf("Read without a lockfile = %v, %v", l, err)
+	}
+}
+
+func TestRead_NewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, FileName, "version: 99\n")
+	if _, err := Read(dir); err == nil || !strings.Contains(err.Error(), "newer than this hookrunner supports") {
+		t.Errorf("expected version error, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the test config downloads the tool on linux")
+	}
+	dir := t.TempDir()
+	writeFile(t, dir, "hooks.yaml", testConfig)
+	writeFile(t, dir, "shared.yaml", "hooks: {}\n")
+	l, err := Generate(load(t, dir), dir, fakeResolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift := l.Verify(load(t, dir), dir); len(drift) != 0 {
+		t.Errorf("unexpected drift: %v", drift)
+	}
+
+	writeFile(t, dir, "shared.yaml", "exclude_tags: [slow]\n")
+	cfg := strings.Replace(testConfig, `"1.0"`, `"1.1"`, 1)
+	cfg = strings.Replace(cfg, "team.yaml\n", "team.yaml\n    - url: https://example.com/extra.yaml\n", 1)
+	writeFile(t, dir, "hooks.yaml", cfg)
+	drift := l.Verify(load(t, dir), dir)
+	want := []string{
+		"include shared.yaml changed",
+		"policy https://example.com/extra.yaml is not locked",
+		"tool lint is 1.1 in the config but 1.0 in hooks.lock",
+	}
+	for _, w := range want {
+		if !contains(drift, w) {
+			t.Errorf("drift %v does not contain %q", drift, w)
+		}
+	}
+}
+
+func TestVerify_Removed(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "hooks.yaml", "hooks: {}\n")
+	l := &Lock{
+		Tools:    map[string]Tool{"lint": {Version: "1.0"}},
+		Policies: map[string]string{"https://example.com/team.yaml": "abc"},
+		Includes: map[string]Include{"shared.yaml": {SHA256: "abc"}},
+	}
+	drift := l.Verify(load(t, dir), dir)
+	want := []string{
+		"include shared.yaml is locked but no longer included",
+		"policy https://example.com/team.yaml is locked but no longer configured",
+		"tool lint is locked but no longer configured",
+	}
+	if strings.Join(drift, "\n") != strings.Join(want, "\n") {
+		t.Errorf("drift = %v, want %v", drift, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := &Lock{
+		Policies: map[string]string{"https://example.com/a.yaml": "1", "https://example.com/b.yaml": "1"},
+		Includes: map[string]Include{"shared.yaml": {SHA256: "1"}},
+	}
+	updated := &Lock{
+		Policies: map[string]string{"https://example.com/a.yaml": "2"},
+		Includes: map[string]Include{"shared.yaml": {SHA256: "1"}, "more.yaml": {SHA256: "1"}},
+	}
+	got := Diff(old, updated)
+	want := []string{
+		"added include more.yaml",
+		"changed policy https://example.com/a.yaml",
+		"removed policy https://example.com/b.yaml",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+}
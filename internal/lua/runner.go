@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/ashavijit/hookrunner/internal/git"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -15,14 +16,45 @@ type PolicyResult struct {
 	Line    int
 }
 
+// ContentReader supplies file contents to check(). The executor passes a
+// reader over staged or committed content; by default files are read from
+// workDir.
+type ContentReader interface {
+	ReadFile(path string) ([]byte, error)
+}
+
 type Runner struct {
 	workDir string
+	reader  ContentReader
+	changes git.ChangeSet
 }
 
 func NewRunner(workDir string) *Runner {
 	return &Runner{workDir: workDir}
 }
 
+// SetReader makes check() and read_file() see content from reader.
+// read_file() falls back to workDir for paths the reader doesn't have.
+func (r *Runner) SetReader(reader ContentReader) {
+	r.reader = reader
+}
+
+// SetChanges exposes the change set, including deletions and renames, to
+// scripts as the "changes" table. Without it every file is reported as
+// modified.
+func (r *Runner) SetChanges(changes git.ChangeSet) {
+	r.changes = changes
+}
+
+func (r *Runner) readFile(path string) ([]byte, error) {
+	if r.reader != nil {
+		if data, err := r.reader.ReadFile(path); err == nil {
+			return data, nil
+		}
+	}
+	return os.ReadFile(filepath.Join(r.workDir, path))
+}
+
 func (r *Runner) RunPolicy(scriptPath string, files []string) ([]PolicyResult, error) {
 	L := lua.NewState()
 	defer L.Close()
@@ -46,8 +78,7 @@ func (r *Runner) RunPolicy(scriptPath string, files []string) ([]PolicyResult, e
 
 	L.SetGlobal("read_file", L.NewFunction(func(L *lua.LState) int {
 		path := L.CheckString(1)
-		fullPath := filepath.Join(r.workDir, path)
-		data, err := os.ReadFile(fullPath)
+		data, err := r.readFile(path)
 		if err != nil {
 			L.Push(lua.LNil)
 			L.Push(lua.LString(err.Error()))
@@ -74,6 +105,25 @@ func (r *Runner) RunPolicy(scriptPath string, files []string) ([]PolicyResult, e
 		filesTable.Append(lua.LString(f))
 	}
 	L.SetGlobal("files", filesTable)
+
+	changes := r.changes
+	if changes == nil {
+		changes = git.ChangesFromPaths(files)
+	}
+	changesTable := L.NewTable()
+	for _, c := range changes {
+		entry := L.NewTable()
+		entry.RawSetString("path", lua.LString(c.Path))
+		entry.RawSetString("old_path", lua.LString(c.OldPath))
+		entry.RawSetString("status", lua.LString(string(c.Status)))
+		entry.RawSetString("old_mode", lua.LString(c.OldMode))
+		entry.RawSetString("new_mode", lua.LString(c.NewMode))
+		entry.RawSetString("mode_changed", lua.LBool(c.ModeChanged()))
+		entry.RawSetString("symlink", lua.LBool(c.IsSymlink()))
+		entry.RawSetString("submodule", lua.LBool(c.IsSubmodule()))
+		changesTable.Append(entry)
+	}
+	L.SetGlobal("changes", changesTable)
 	L.SetGlobal("workdir", lua.LString(r.workDir))
 
 	if err := L.DoFile(scriptPath); err != nil {
@@ -83,7 +133,13 @@ func (r *Runner) RunPolicy(scriptPath string, files []string) ([]PolicyResult, e
 	checkFn := L.GetGlobal("check")
 	if checkFn.Type() == lua.LTFunction {
 		for _, file := range files {
-			content, err := os.ReadFile(filepath.Join(r.workDir, file))
+			var content []byte
+			var err error
+			if r.reader != nil {
+				content, err = r.reader.ReadFile(file)
+			} else {
+				content, err = os.ReadFile(filepath.Join(r.workDir, file))
+			}
 			if err != nil {
 				content = []byte{} // Empty content if file can't be read
 			}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/git"
 )
 
 func TestNewRunner(t *testing.T) {
@@ -149,3 +151,79 @@ func TestRunScript_Invalid(t *testing.T) {
 		t.Error("expected error for invalid script")
 	}
 }
+
+type staticReader map[string]string
+
+func (s staticReader) ReadFile(path string) ([]byte, error) {
+	if c, ok := s[path]; ok {
+		return []byte(c), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func TestRunPolicy_Reader(t *testing.T) {
+	tmpDir := t.TempDir()
+	r := NewRunner(tmpDir)
+	r.SetReader(staticReader{"secret.go": "TODO: staged"})
+
+	scriptPath := filepath.Join(tmpDir, "todo.lua")
+	if err := os.WriteFile(scriptPath, []byte(`
+function check(file, content)
+	if string.find(content, "TODO") then
+		return false, "TODO in " .. file
+	end
+	return true, ""
+end
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The working tree copy is clean; only the staged content has the TODO.
+	if err := os.WriteFile(filepath.Join(tmpDir, "secret.go"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := r.RunPolicy(scriptPath, []string{"secret.go"})
+	if err != nil {
+		t.Fatalf("RunPolicy failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Message != "TODO in secret.go" {
+		t.Errorf("expected violation from reader content, got %v", results)
+	}
+}
+
+func TestRunPolicy_Changes(t *testing.T) {
+	tmpDir := t.TempDir()
+	r := NewRunner(tmpDir)
+	r.SetChanges(git.ChangeSet{
+		{Path: "db/001.sql", Status: git.StatusDeleted, OldMode: "100644", NewMode: "000000"},
+		{Path: "b.go", OldPath: "api/b.go", Status: git.StatusRenamed, OldMode: "100644", NewMode: "100644"},
+	})
+
+	scriptPath := filepath.Join(tmpDir, "changes.lua")
+	if err := os.WriteFile(scriptPath, []byte(`
+for _, c in ipairs(changes) do
+	if c.status == "deleted" and match(c.path, "db/*.sql") then
+		block("deleted " .. c.path, c.path)
+	end
+	if c.status == "renamed" then
+		block("renamed " .. c.old_path .. " to " .. c.path, c.path)
+	end
+end
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := r.RunPolicy(scriptPath, []string{"b.go"})
+	if err != nil {
+		t.Fatalf("RunPolicy failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if results[0].Message != "deleted db/001.sql" || results[1].Message != "renamed api/b.go to b.go" {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
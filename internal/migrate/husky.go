@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// huskySetup matches the lines husky 5 to 8 put at the top of hooks.
+var huskySetup = regexp.MustCompile(`^(\.|source)\s+.*husky\.sh"?$`)
+
+// huskyComplex matches shell constructs that make a hook more than a list
+// of commands.
+var huskyComplex = regexp.MustCompile(`^(if|then|else|elif|fi|for|while|until|do|done|case|esac)\b|\(\)\s*\{|^\}|\\$|<<`)
+
+// huskyArgs matches uses of the git hook's arguments.
+var huskyArgs = regexp.MustCompile(`\$(\{?[1-9@*]\}?|\{?HUSKY_GIT_PARAMS\}?)`)
+
+// FromHusky translates the hooks in .husky, or the husky.hooks key of
+// package.json used by husky 4.
+func FromHusky(repoDir string) (*Result, error) {
+	scripts, source, err := huskyScripts(repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Result{Source: source}
+	hookTypes := make([]string, 0, len(scripts))
+	for hookType := range scripts {
+		hookTypes = append(hookTypes, hookType)
+	}
+	sort.Strings(hookTypes)
+
+	for _, hookType := range hookTypes {
+		if !gitHookTypes[hookType] {
+			r.warnf("%s: not a git hook; not translated", hookType)
+			continue
+		}
+		r.translateHuskyHook(hookType, scripts[hookType], source)
+	}
+	return r, nil
+}
+
+// huskyScripts returns the script of each hook and where they were found.
+func huskyScripts(repoDir string) (map[string]string, string, error) {
+	dir := filepath.Join(repoDir, ".husky")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	scripts := make(map[string]string)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, "", err
+		}
+		scripts[name] = string(data)
+	}
+	if len(scripts) > 0 {
+		return scripts, ".husky", nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(repoDir, "package.json"))
+	if os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("no config found (.husky, package.json)")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var pkg struct {
+		Husky struct {
+			Hooks map[string]string `json:"hooks"`
+		} `json:"husky"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, "", fmt.Errorf("package.json: %w", err)
+	}
+	if len(pkg.Husky.Hooks) == 0 {
+		return nil, "", fmt.Errorf("no config found (.husky, husky.hooks in package.json)")
+	}
+	return pkg.Husky.Hooks, "package.json", nil
+}
+
+// translateHuskyHook turns each command of a simple hook into a hook that
+// needs the one before, as the shell script stops at the first failure
+// under set -e. A script with control flow is run as it is.
+func (r *Result) translateHuskyHook(hookType, script, source string) {
+	var lines []string
+	complex := false
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || huskySetup.MatchString(line) {
+			continue
+		}
+		if huskyComplex.MatchString(line) {
+			complex = true
+		}
+		if huskyArgs.MatchString(line) {
+			r.warnf("%s: %q uses the hook's arguments, which are not passed to commands", hookType, line)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+	if complex && source == ".husky" {
+		r.warnf("%s: script has control flow; it is run as a whole", hookType)
+		r.addHook(hookType, Hook{Name: hookType, Run: "sh " + shellQuote(".husky/"+hookType)})
+		return
+	}
+
+	start := len(r.File.Hooks[hookType])
+	for _, line := range lines {
+		r.addHook(hookType, Hook{Name: huskyHookName(line), Run: line})
+	}
+	chain(r.File.Hooks[hookType][start:], true)
+}
+
+// huskyHookName names a hook after the command it runs, leaving out
+// package runners such as npx and npm run.
+func huskyHookName(line string) string {
+	fields := strings.Fields(line)
+	for len(fields) > 1 {
+		switch fields[0] {
+		case "npx", "pnpx", "bunx", "exec":
+			fields = fields[1:]
+			continue
+		case "npm", "pnpm", "yarn", "bun":
+			fields = fields[1:]
+			if len(fields) > 1 && (fields[0] == "run" || fields[0] == "exec" || fields[0] == "dlx") {
+				fields = fields[1:]
+			}
+			continue
+		}
+		break
+	}
+	if len(fields) == 0 {
+		return "hook"
+	}
+	return hookName(filepath.Base(fields[0]))
+}
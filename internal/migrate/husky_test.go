@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromHusky(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".husky/_/husky.sh", "# generated\n")
+	writeFile(t, dir, ".husky/pre-commit", `#!/usr/bin/env sh
+. "$(dirname -- "$0")/_/husky.sh"
+
+# lint first
+npx lint-staged
+npm run test
+`)
+	writeFile(t, dir, ".husky/commit-msg", `npx --no -- commitlint --edit "$1"
+`)
+	writeFile(t, dir, ".husky/pre-push", `if [ "$CI" = "" ]; then
+  npm run build
+fi
+`)
+
+	r, err := FromHusky(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Source != ".husky" {
+		t.Errorf("source = %q", r.Source)
+	}
+
+	cfg := load(t, r)
+	commit := cfg.GetHooks("pre-commit")
+	if len(commit) != 2 || commit[0].Name != "lint-staged" || commit[1].Name != "test" || commit[1].Run != "npm run test" {
+		t.Fatalf("pre-commit hooks = %+v", commit)
+	}
+	if len(commit[1].Needs) != 1 || commit[1].Needs[0] != "lint-staged" {
+		t.Errorf("test should need lint-staged: %+v", commit[1])
+	}
+
+	push := cfg.GetHooks("pre-push")
+	if len(push) != 1 || push[0].Run != "sh .husky/pre-push" {
+		t.Errorf("pre-push hooks = %+v", push)
+	}
+
+	for _, want := range []string{`commit-msg: "npx --no -- commitlint --edit \"$1\"" uses the hook's arguments`, "pre-push: script has control flow"} {
+		if !hasWarning(r, want) {
+			t.Errorf("missing warning %q in %v", want, r.Warnings)
+		}
+	}
+}
+
+func TestFromHusky_PackageJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"name": "app", "husky": {"hooks": {"pre-commit": "lint-staged", "pre-push": "yarn test"}}}`)
+
+	r, err := FromHusky(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := load(t, r)
+	if hooks := cfg.GetHooks("pre-push"); len(hooks) != 1 || hooks[0].Name != "test" || hooks[0].Run != "yarn test" {
+		t.Errorf("pre-push hooks = %+v", hooks)
+	}
+	if r.Source != "package.json" {
+		t.Errorf("source = %q", r.Source)
+	}
+}
+
+func TestFromHusky_NoConfig(t *testing.T) {
+	if _, err := FromHusky(t.TempDir()); err == nil {
+		t.Error("expected an error without husky hooks")
+	}
+}
@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type lefthookHook struct {
+	Parallel bool                       `yaml:"parallel"`
+	Piped    bool                       `yaml:"piped"`
+	Commands map[string]lefthookCommand `yaml:"commands"`
+	Scripts  map[string]lefthookCommand `yaml:"scripts"`
+}
+
+type lefthookCommand struct {
+	Run         string            `yaml:"run"`
+	Runner      string            `yaml:"runner"`
+	Glob        yaml.Node         `yaml:"glob"`
+	Exclude     yaml.Node         `yaml:"exclude"`
+	Files       string            `yaml:"files"`
+	Root        string            `yaml:"root"`
+	Tags        yaml.Node         `yaml:"tags"`
+	Env         map[string]string `yaml:"env"`
+	Skip        yaml.Node         `yaml:"skip"`
+	Only        yaml.Node         `yaml:"only"`
+	Priority    int               `yaml:"priority"`
+	Interactive bool              `yaml:"interactive"`
+	StageFixed  bool              `yaml:"stage_fixed"`
+	FailText    string            `yaml:"fail_text"`
+}
+
+// lefthookPlaceholders are the file list templates of lefthook commands.
+var lefthookPlaceholders = regexp.MustCompile(`\s*\{(staged_files|push_files|all_files|files|cmd|[0-9]+)\}`)
+
+// lefthookSettings are top-level keys that are settings, not hooks.
+var lefthookSettings = map[string]bool{
+	"min_version": true, "colors": true, "no_tty": true, "source_dir": true,
+	"source_dir_local": true, "rc": true, "skip_output": true, "output": true,
+	"assert_lefthook_installed": true, "lefthook": true, "skip_lfs": true,
+	"templates": true,
+}
+
+// FromLefthook translates a lefthook.yml. Commands and scripts become
+// hooks, ordered as lefthook runs them.
+func FromLefthook(data []byte) (*Result, error) {
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid lefthook config: %w", err)
+	}
+
+	r := &Result{Source: "lefthook.yml"}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		node := raw[key]
+		switch {
+		case key == "extends" || key == "remotes" || key == "remote":
+			r.warnf("%s: shared configs are not fetched; add them with include:", key)
+			continue
+		case lefthookSettings[key]:
+			continue
+		case !gitHookTypes[key]:
+			r.warnf("%s: not a git hook; not translated", key)
+			continue
+		}
+
+		var lh lefthookHook
+		if err := node.Decode(&lh); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		r.translateLefthookHook(key, lh)
+	}
+	return r, nil
+}
+
+func (r *Result) translateLefthookHook(hookType string, lh lefthookHook) {
+	type entry struct {
+		name     string
+		cmd      lefthookCommand
+		script   bool
+		priority int
+	}
+	var entries []entry
+	for name, cmd := range lh.Commands {
+		entries = append(entries, entry{name: name, cmd: cmd, priority: cmd.Priority})
+	}
+	for name, cmd := range lh.Scripts {
+		entries = append(entries, entry{name: name, cmd: cmd, script: true, priority: cmd.Priority})
+	}
+	// lefthook runs lower priorities first, 0 last, then by name.
+	sort.Slice(entries, func(i, j int) bool {
+		pi, pj := entries[i].priority, entries[j].priority
+		if (pi == 0) != (pj == 0) {
+			return pj == 0
+		}
+		if pi != pj {
+			return pi < pj
+		}
+		return entries[i].name < entries[j].name
+	})
+
+	start := len(r.File.Hooks[hookType])
+	for _, e := range entries {
+		label := hookType + "." + e.name
+		h := Hook{
+			Name:        hookName(e.name),
+			Root:        strings.TrimSuffix(e.cmd.Root, "/"),
+			Env:         e.cmd.Env,
+			Interactive: e.cmd.Interactive,
+			StageFixed:  e.cmd.StageFixed,
+			Tags:        stringOrList(e.cmd.Tags, true),
+		}
+
+		if e.script {
+			h.Name = hookName(strings.TrimSuffix(e.name, path.Ext(e.name)))
+			h.Script = path.Join(hookType, e.name)
+			h.Runner = e.cmd.Runner
+			r.File.ScriptsDir = ".lefthook"
+		} else {
+			h.Run = r.lefthookRun(e.cmd.Run, label)
+		}
+
+		r.lefthookFiles(&h, e.cmd, label)
+
+		switch e.cmd.Skip.Kind {
+		case 0:
+		case yaml.ScalarNode:
+			if e.cmd.Skip.Value == "true" {
+				h.Disabled = true
+			} else if e.cmd.Skip.Value != "false" {
+				r.warnf("%s: skip %q is not translated", label, e.cmd.Skip.Value)
+			}
+		default:
+			r.warnf("%s: skip conditions are not translated; use skip: with an environment variable", label)
+		}
+		if e.cmd.Only.Kind != 0 {
+			r.warnf("%s: only conditions are not translated; use only: with an environment variable", label)
+		}
+		if e.cmd.FailText != "" {
+			r.warnf("%s: fail_text is not supported", label)
+		}
+		r.addHook(hookType, h)
+	}
+
+	// Without parallel, lefthook runs commands one after the other; piped
+	// also stops at the first failure.
+	if !lh.Parallel || lh.Piped {
+		chain(r.File.Hooks[hookType][start:], lh.Piped)
+	}
+}
+
+// lefthookRun removes file list templates from a command, which HookRunner
+// does not fill in.
+func (r *Result) lefthookRun(run, label string) string {
+	if m := lefthookPlaceholders.FindAllStringSubmatch(run, -1); m != nil {
+		var names []string
+		for _, p := range m {
+			names = append(names, "{"+p[1]+"}")
+		}
+		r.warnf("%s: %s removed; the command runs once without file names", label, strings.Join(names, ", "))
+		run = lefthookPlaceholders.ReplaceAllString(run, "")
+	}
+	return strings.TrimSpace(run)
+}
+
+func (r *Result) lefthookFiles(h *Hook, cmd lefthookCommand, label string) {
+	globs := stringOrList(cmd.Glob, false)
+	switch {
+	case len(globs) == 1 && isSimpleGlob(globs[0]):
+		h.Glob = globs[0]
+	case len(globs) > 0:
+		res := make([]string, len(globs))
+		for i, g := range globs {
+			res[i] = globToRegex(g)
+		}
+		h.Files = strings.Join(res, "|")
+	}
+
+	// exclude is a regex, or a list of globs in newer versions.
+	switch cmd.Exclude.Kind {
+	case yaml.ScalarNode:
+		h.Exclude = cmd.Exclude.Value
+	case yaml.SequenceNode:
+		var res []string
+		for _, g := range stringOrList(cmd.Exclude, false) {
+			res = append(res, globToRegex(g))
+		}
+		h.Exclude = strings.Join(res, "|")
+	}
+
+	if cmd.Files != "" {
+		r.warnf("%s: files command %q is not supported; filter with files: or glob:", label, cmd.Files)
+	}
+}
+
+// stringOrList decodes a string or a list of strings. With split, a
+// string is split on spaces and commas, as lefthook tags are.
+func stringOrList(n yaml.Node, split bool) []string {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !split {
+			return []string{n.Value}
+		}
+		return strings.FieldsFunc(n.Value, func(r rune) bool { return r == ' ' || r == ',' })
+	case yaml.SequenceNode:
+		var out []string
+		for _, item := range n.Content {
+			out = append(out, item.Value)
+		}
+		return out
+	}
+	return nil
+}
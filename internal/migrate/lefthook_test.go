@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFromLefthook(t *testing.T) {
+	r, err := FromLefthook([]byte(`
+min_version: 1.5.0
+remotes:
+  - git_url: https://github.com/org/hooks
+pre-commit:
+  parallel: true
+  commands:
+    lint:
+      glob: "*.{js,ts}"
+      run: npx eslint {staged_files}
+      tags: frontend style
+      stage_fixed: true
+    test:
+      root: api/
+      glob: "*.go"
+      run: go test ./...
+      env:
+        CGO_ENABLED: "0"
+    docs:
+      run: make docs
+      skip: true
+      exclude: [docs/generated/**]
+    e2e:
+      run: make e2e
+      skip: [merge, rebase]
+pre-push:
+  piped: true
+  commands:
+    build:
+      priority: 2
+      run: go build ./...
+    audit:
+      run: npm audit
+    deps:
+      priority: 1
+      run: go mod verify
+  scripts:
+    "check.sh":
+      runner: bash
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := load(t, r)
+	if cfg.ScriptsDir != ".lefthook" {
+		t.Errorf("scripts_dir = %q", cfg.ScriptsDir)
+	}
+
+	commit := cfg.GetHooks("pre-commit")
+	if len(commit) != 4 {
+		t.Fatalf("pre-commit hooks = %+v", commit)
+	}
+	for _, h := range commit {
+		if len(h.After) > 0 || len(h.Needs) > 0 {
+			t.Errorf("parallel hook %s is ordered: %+v", h.Name, h)
+		}
+	}
+	docs, e2e, lint, test := commit[0], commit[1], commit[2], commit[3]
+	if !docs.Disabled || !matches(t, docs.Exclude, "docs/generated/api.md") {
+		t.Errorf("docs = %+v", docs)
+	}
+	if e2e.Disabled {
+		t.Errorf("e2e should not be disabled: %+v", e2e)
+	}
+	if lint.Run != "npx eslint" || !matches(t, lint.Files, "web/app.ts") || lint.Glob != "" || !lint.StageFixed || len(lint.Tags) != 2 {
+		t.Errorf("lint = %+v", lint)
+	}
+	if test.Glob != "*.go" || test.Root != "api" || test.Env["CGO_ENABLED"] != "0" {
+		t.Errorf("test = %+v", test)
+	}
+
+	push := cfg.GetHooks("pre-push")
+	var names []string
+	for _, h := range push {
+		names = append(names, h.Name)
+	}
+	if got := fmt.Sprint(names); got != "[deps build audit check]" {
+		t.Fatalf("pre-push order = %s", got)
+	}
+	if len(push[1].Needs) != 1 || push[1].Needs[0] != "deps" {
+		t.Errorf("piped hooks should need the previous one: %+v", push[1])
+	}
+	if push[3].Script != "pre-push/check.sh" || push[3].Runner != "bash" {
+		t.Errorf("check = %+v", push[3])
+	}
+
+	for _, want := range []string{"remotes:", "pre-commit.lint: {staged_files} removed", "pre-commit.e2e: skip conditions"} {
+		if !hasWarning(r, want) {
+			t.Errorf("missing warning %q in %v", want, r.Warnings)
+		}
+	}
+}
+
+func TestFromLefthook_SequentialUsesAfter(t *testing.T) {
+	r, err := FromLefthook([]byte(`
+commit-msg:
+  commands:
+    a: {run: echo a}
+    b: {run: echo b}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := load(t, r).GetHooks("commit-msg")
+	if len(hooks) != 2 || len(hooks[1].After) != 1 || hooks[1].After[0] != "a" || len(hooks[1].Needs) != 0 {
+		t.Errorf("hooks = %+v", hooks)
+	}
+}
@@ -0,0 +1,294 @@
+// Package migrate translates hook configs of other tools into hooks.yaml.
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Sources are the tools configs can be migrated from.
+var Sources = []string{"pre-commit", "lefthook", "husky"}
+
+// File is a migrated hooks.yaml. It mirrors config.Config, leaving out
+// empty fields so the result is short and reviewable.
+type File struct {
+	Version    int               `yaml:"version"`
+	ScriptsDir string            `yaml:"scripts_dir,omitempty"`
+	Policies   *Policies         `yaml:"policies,omitempty"`
+	Hooks      map[string][]Hook `yaml:"hooks"`
+}
+
+type Policies struct {
+	LocalPolicies []LocalPolicy `yaml:"localPolicies"`
+}
+
+type LocalPolicy struct {
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description,omitempty"`
+	Rules       PolicyRules `yaml:"rules"`
+}
+
+type PolicyRules struct {
+	MaxFileSizeKB     int              `yaml:"max_file_size_kb,omitempty"`
+	ForbidFileContent []ContentPattern `yaml:"forbid_file_content,omitempty"`
+}
+
+type ContentPattern struct {
+	Pattern     string `yaml:"pattern"`
+	Description string `yaml:"description,omitempty"`
+}
+
+type Hook struct {
+	Name        string            `yaml:"name"`
+	Tool        string            `yaml:"tool,omitempty"`
+	Run         string            `yaml:"run,omitempty"`
+	Script      string            `yaml:"script,omitempty"`
+	Runner      string            `yaml:"runner,omitempty"`
+	Args        []string          `yaml:"args,omitempty"`
+	Files       string            `yaml:"files,omitempty"`
+	Glob        string            `yaml:"glob,omitempty"`
+	Exclude     string            `yaml:"exclude,omitempty"`
+	Root        string            `yaml:"root,omitempty"`
+	After       []string          `yaml:"after,omitempty"`
+	Needs       []string          `yaml:"needs,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	Interactive bool              `yaml:"interactive,omitempty"`
+	StageFixed  bool              `yaml:"stage_fixed,omitempty"`
+	Disabled    bool              `yaml:"disabled,omitempty"`
+}
+
+// Result is a migrated config and what could not be translated.
+type Result struct {
+	// Source is the file or directory migrated from, relative to the
+	// repository.
+	Source   string
+	File     File
+	Warnings []string
+}
+
+func (r *Result) warnf(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// addHook appends h to the hooks of hookType, renaming it if the name is
+// taken.
+func (r *Result) addHook(hookType string, h Hook) string {
+	if r.File.Hooks == nil {
+		r.File.Hooks = make(map[string][]Hook)
+	}
+	base, name := h.Name, h.Name
+	for n := 2; r.hasHook(hookType, name); n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	h.Name = name
+	h.Run = escapeVars(h.Run)
+	h.Root = escapeVars(h.Root)
+	if h.Args != nil {
+		args := make([]string, len(h.Args))
+		for i, a := range h.Args {
+			args[i] = escapeVars(a)
+		}
+		h.Args = args
+	}
+	if h.Env != nil {
+		env := make(map[string]string, len(h.Env))
+		for k, v := range h.Env {
+			env[k] = escapeVars(v)
+		}
+		h.Env = env
+	}
+	r.File.Hooks[hookType] = append(r.File.Hooks[hookType], h)
+	return name
+}
+
+// escapeVars keeps shell ${VAR} references from being expanded by
+// HookRunner when the config is loaded.
+func escapeVars(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+func (r *Result) hasHook(hookType, name string) bool {
+	for _, h := range r.File.Hooks[hookType] {
+		if h.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// YAML renders the result as hooks.yaml, with the warnings as comments at
+// the top so they show up in review.
+func (r *Result) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Migrated from %s by hookrunner migrate.\n", r.Source)
+	if len(r.Warnings) > 0 {
+		buf.WriteString("#\n# Not translated, review before use:\n")
+		for _, w := range r.Warnings {
+			fmt.Fprintf(&buf, "#   - %s\n", strings.ReplaceAll(w, "\n", " "))
+		}
+	}
+	buf.WriteString("\n")
+
+	r.File.Version = config.CurrentVersion
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(r.File); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Migrate translates the config of the tool from found in repoDir.
+func Migrate(repoDir, from string) (*Result, error) {
+	switch from {
+	case "pre-commit":
+		return migrateFile(repoDir, []string{".pre-commit-config.yaml", ".pre-commit-config.yml"}, FromPreCommit)
+	case "lefthook":
+		return migrateFile(repoDir, []string{"lefthook.yml", "lefthook.yaml", ".lefthook.yml", ".lefthook.yaml"}, FromLefthook)
+	case "husky":
+		return FromHusky(repoDir)
+	}
+	return nil, fmt.Errorf("unknown source %q (use %s)", from, strings.Join(Sources, ", "))
+}
+
+func migrateFile(repoDir string, names []string, translate func([]byte) (*Result, error)) (*Result, error) {
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(repoDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		r, err := translate(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		r.Source = name
+		return r, nil
+	}
+	return nil, fmt.Errorf("no config found (%s)", strings.Join(names, ", "))
+}
+
+// gitHookTypes are the client-side git hooks.
+var gitHookTypes = map[string]bool{
+	"applypatch-msg": true, "pre-applypatch": true, "post-applypatch": true,
+	"pre-commit": true, "pre-merge-commit": true, "prepare-commit-msg": true,
+	"commit-msg": true, "post-commit": true, "pre-rebase": true,
+	"post-checkout": true, "post-merge": true, "pre-push": true,
+	"post-rewrite": true, "pre-auto-gc": true, "reference-transaction": true,
+	"push-to-checkout": true, "sendemail-validate": true, "fsmonitor-watchman": true,
+	"p4-changelist": true, "p4-prepare-changelist": true, "p4-post-changelist": true,
+	"p4-pre-submit": true, "post-index-change": true,
+}
+
+// shellJoin joins a command and its arguments, quoting arguments the
+// shell would split or expand.
+func shellJoin(parts ...string) string {
+	quoted := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		quoted = append(quoted, shellQuote(p))
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_\-./=:,+@%]+$`)
+
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// globToRegex translates a glob where * and ** match any characters,
+// including /, **/ also matches no directory, and {a,b} lists
+// alternatives, as lefthook uses them.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	depth := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*':
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteString(".")
+		case c == '{':
+			depth++
+			b.WriteString("(?:")
+		case c == '}' && depth > 0:
+			depth--
+			b.WriteString(")")
+		case c == ',' && depth > 0:
+			b.WriteString("|")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i : i+end+1]
+			if strings.HasPrefix(class, "[!") {
+				class = "[^" + class[2:]
+			}
+			b.WriteString(class)
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// isSimpleGlob reports whether glob can be used as a hook glob, which
+// matches file base names.
+func isSimpleGlob(glob string) bool {
+	return !strings.ContainsAny(glob, "/{}") && !strings.Contains(glob, "**")
+}
+
+// chain orders the hooks of each type as listed, with after, or with
+// needs when a failure must stop the rest.
+func chain(hooks []Hook, stopOnFailure bool) {
+	for i := 1; i < len(hooks); i++ {
+		prev := hooks[i-1].Name
+		if stopOnFailure {
+			hooks[i].Needs = append(hooks[i].Needs, prev)
+		} else {
+			hooks[i].After = append(hooks[i].After, prev)
+		}
+	}
+}
+
+// hookName turns s into a hook name.
+func hookName(s string) string {
+	name := strings.Trim(nonName.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if name == "" {
+		return "hook"
+	}
+	return name
+}
+
+var nonName = regexp.MustCompile(`[^a-z0-9_.-]+`)
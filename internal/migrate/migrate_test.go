@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/config"
+)
+
+// load writes the result as hooks.yaml and loads it, so tests check that
+// migrated configs are valid.
+func load(t *testing.T, r *Result) *config.Config {
+	t.Helper()
+	data, err := r.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("migrated config does not load: %v\n%s", err, data)
+	}
+	return cfg
+}
+
+func hasWarning(r *Result, substr string) bool {
+	for _, w := range r.Warnings {
+		if strings.Contains(w, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(t *testing.T, re, s string) bool {
+	t.Helper()
+	ok, err := regexp.MatchString(re, s)
+	if err != nil {
+		t.Fatalf("invalid regex %s: %v", re, err)
+	}
+	return ok
+}
+
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob  string
+		match []string
+		miss  []string
+	}{
+		{"*.go", []string{"main.go", "cmd/main.go"}, []string{"main.gox"}},
+		{"**/*.{js,ts}", []string{"a.js", "src/b.ts"}, []string{"c.css"}},
+		{"src/**/*.go", []string{"src/a/b.go"}, []string{"lib/a.go"}},
+		{"file?.[!a]", []string{"file1.b"}, []string{"file1.a"}},
+	}
+	for _, tt := range tests {
+		re := globToRegex(tt.glob)
+		for _, f := range tt.match {
+			if !matches(t, re, f) {
+				t.Errorf("%s (%s) should match %s", tt.glob, re, f)
+			}
+		}
+		for _, f := range tt.miss {
+			if matches(t, re, f) {
+				t.Errorf("%s (%s) should not match %s", tt.glob, re, f)
+			}
+		}
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin("eslint", "--max-warnings=0", "src dir", "it's")
+	want := `eslint --max-warnings=0 'src dir' 'it'\''s'`
+	if got != want {
+		t.Errorf("shellJoin = %s, want %s", got, want)
+	}
+}
+
+func TestAddHook_EscapesVariables(t *testing.T) {
+	r := &Result{Source: "test"}
+	r.addHook("pre-commit", Hook{Name: "echo", Run: `echo "${HOME}"`})
+	r.addHook("pre-commit", Hook{Name: "echo", Run: "echo again"})
+
+	cfg := load(t, r)
+	hooks := cfg.GetHooks("pre-commit")
+	if len(hooks) != 2 || hooks[1].Name != "echo-2" {
+		t.Fatalf("hooks = %+v", hooks)
+	}
+	h, err := hooks[0].Interpolate(config.Vars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Run != `echo "${HOME}"` {
+		t.Errorf("run = %s, want the shell reference kept", h.Run)
+	}
+}
+
+func TestMigrate_UnknownSource(t *testing.T) {
+	if _, err := Migrate(t.TempDir(), "overcommit"); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+	if _, err := Migrate(t.TempDir(), "lefthook"); err == nil || !strings.Contains(err.Error(), "no config found") {
+		t.Errorf("expected no config found, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type preCommitConfig struct {
+	Repos         []preCommitRepo `yaml:"repos"`
+	DefaultStages []string        `yaml:"default_stages"`
+	Files         string          `yaml:"files"`
+	Exclude       string          `yaml:"exclude"`
+	FailFast      bool            `yaml:"fail_fast"`
+}
+
+type preCommitRepo struct {
+	Repo  string          `yaml:"repo"`
+	Rev   string          `yaml:"rev"`
+	Hooks []preCommitHook `yaml:"hooks"`
+}
+
+type preCommitHook struct {
+	ID                     string   `yaml:"id"`
+	Name                   string   `yaml:"name"`
+	Entry                  string   `yaml:"entry"`
+	Language               string   `yaml:"language"`
+	Args                   []string `yaml:"args"`
+	Files                  string   `yaml:"files"`
+	Exclude                string   `yaml:"exclude"`
+	Types                  []string `yaml:"types"`
+	TypesOr                []string `yaml:"types_or"`
+	ExcludeTypes           []string `yaml:"exclude_types"`
+	Stages                 []string `yaml:"stages"`
+	PassFilenames          *bool    `yaml:"pass_filenames"`
+	AlwaysRun              bool     `yaml:"always_run"`
+	AdditionalDependencies []string `yaml:"additional_dependencies"`
+}
+
+// preCommitStages maps pre-commit stage names, including the legacy ones,
+// to git hook types.
+var preCommitStages = map[string]string{
+	"commit":       "pre-commit",
+	"push":         "pre-push",
+	"merge-commit": "pre-merge-commit",
+}
+
+// typeExtensions maps pre-commit file types to the extensions they cover.
+var typeExtensions = map[string][]string{
+	"python":     {"py", "pyi"},
+	"go":         {"go"},
+	"javascript": {"js", "mjs", "cjs"},
+	"jsx":        {"jsx"},
+	"ts":         {"ts"},
+	"tsx":        {"tsx"},
+	"json":       {"json"},
+	"yaml":       {"yaml", "yml"},
+	"toml":       {"toml"},
+	"markdown":   {"md", "markdown"},
+	"shell":      {"sh", "bash"},
+	"rust":       {"rs"},
+	"java":       {"java"},
+	"ruby":       {"rb"},
+	"css":        {"css"},
+	"scss":       {"scss"},
+	"html":       {"html", "htm"},
+	"proto":      {"proto"},
+	"terraform":  {"tf"},
+	"dockerfile": {"Dockerfile"},
+}
+
+// knownHook is a well-known hook from a remote repository, run as a
+// command over the whole project since hooks are not given file names.
+type knownHook struct {
+	tool  string
+	run   string
+	files string
+}
+
+var knownHooks = map[string]knownHook{
+	"black":         {run: "black .", files: `\.pyi?$`},
+	"isort":         {run: "isort .", files: `\.pyi?$`},
+	"flake8":        {run: "flake8", files: `\.py$`},
+	"ruff":          {run: "ruff check .", files: `\.pyi?$`},
+	"ruff-format":   {run: "ruff format .", files: `\.pyi?$`},
+	"mypy":          {run: "mypy .", files: `\.pyi?$`},
+	"prettier":      {run: "npx prettier --write ."},
+	"eslint":        {run: "npx eslint .", files: `\.[cm]?[jt]sx?$`},
+	"golangci-lint": {tool: "golangci-lint", files: `\.go$`},
+	"go-fmt":        {run: "gofmt -l -w .", files: `\.go$`},
+	"go-vet":        {run: "go vet ./...", files: `\.go$`},
+	"go-vet-mod":    {run: "go vet ./...", files: `\.go$`},
+	"go-unit-tests": {run: "go test ./...", files: `\.go$`},
+	"go-mod-tidy":   {run: "go mod tidy", files: `(^|/)go\.(mod|sum)$|\.go$`},
+	"cargo-check":   {run: "cargo check", files: `\.rs$`},
+	"fmt":           {run: "cargo fmt", files: `\.rs$`},
+	"clippy":        {run: "cargo clippy", files: `\.rs$`},
+}
+
+var maxKBArg = regexp.MustCompile(`^--maxkb=(\d+)$`)
+
+// FromPreCommit translates a .pre-commit-config.yaml. Local hooks become
+// run commands and a few well-known remote hooks are mapped to their
+// tools; other remote hooks are reported.
+func FromPreCommit(data []byte) (*Result, error) {
+	var cfg preCommitConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid pre-commit config: %w", err)
+	}
+
+	r := &Result{Source: ".pre-commit-config.yaml"}
+	defaultStages := cfg.DefaultStages
+	if len(defaultStages) == 0 {
+		defaultStages = []string{"pre-commit"}
+	}
+
+	for _, repo := range cfg.Repos {
+		for _, h := range repo.Hooks {
+			label := h.ID
+			if repo.Repo != "local" && repo.Repo != "meta" {
+				label = fmt.Sprintf("%s (%s@%s)", h.ID, repo.Repo, repo.Rev)
+			}
+
+			if repo.Repo == "meta" {
+				r.warnf("%s: meta hooks have no equivalent", label)
+				continue
+			}
+			if repo.Repo != "local" && r.addPolicy(h) {
+				continue
+			}
+
+			hook, ok := r.translatePreCommitHook(repo, h, label)
+			if !ok {
+				continue
+			}
+			r.preCommitFiles(&hook, h, cfg, label)
+
+			stages := h.Stages
+			if len(stages) == 0 {
+				stages = defaultStages
+			}
+			for _, stage := range stages {
+				hookType := stage
+				if t, ok := preCommitStages[stage]; ok {
+					hookType = t
+				}
+				if !gitHookTypes[hookType] {
+					r.warnf("%s: stage %q has no git hook equivalent", label, stage)
+					continue
+				}
+				r.addHook(hookType, hook)
+			}
+		}
+	}
+
+	// pre-commit runs hooks one at a time in order, and only stops early
+	// with fail_fast.
+	for _, hooks := range r.File.Hooks {
+		chain(hooks, cfg.FailFast)
+	}
+	return r, nil
+}
+
+func (r *Result) translatePreCommitHook(repo preCommitRepo, h preCommitHook, label string) (Hook, bool) {
+	hook := Hook{Name: hookName(h.ID)}
+
+	if repo.Repo != "local" {
+		known, ok := knownHooks[h.ID]
+		if !ok {
+			r.warnf("%s: remote hook; add a command that does the same", label)
+			return hook, false
+		}
+		hook.Tool, hook.Run, hook.Files = known.tool, known.run, known.files
+		if known.tool == "golangci-lint" {
+			hook.Args = append([]string{"run"}, h.Args...)
+		} else if len(h.Args) > 0 {
+			hook.Run = hook.Run + " " + shellJoin(h.Args...)
+		}
+		return hook, true
+	}
+
+	switch h.Language {
+	case "system", "script", "unsupported", "unsupported_script", "":
+	case "fail":
+		r.warnf("%s: language fail; use a policy such as forbid_files instead", label)
+		return hook, false
+	default:
+		if len(h.AdditionalDependencies) > 0 {
+			r.warnf("%s: additional_dependencies %v are not installed; install them yourself", label, h.AdditionalDependencies)
+		} else {
+			r.warnf("%s: language %s environment is not created; the command runs with the tools on PATH", label, h.Language)
+		}
+	}
+
+	entry := h.Entry
+	if (h.Language == "script" || h.Language == "unsupported_script") && !strings.HasPrefix(entry, "/") && !strings.HasPrefix(entry, "./") {
+		entry = "./" + entry
+	}
+	hook.Run = entry
+	if len(h.Args) > 0 {
+		hook.Run += " " + shellJoin(h.Args...)
+	}
+	if h.PassFilenames == nil || *h.PassFilenames {
+		r.warnf("%s: pass_filenames is not supported; the command runs once without file names", label)
+	}
+	return hook, true
+}
+
+// preCommitFiles sets the file filters of hook from the hook's and the
+// config's files, exclude and types.
+func (r *Result) preCommitFiles(hook *Hook, h preCommitHook, cfg preCommitConfig, label string) {
+	if h.AlwaysRun {
+		hook.Files = ""
+		return
+	}
+
+	files := h.Files
+	if files == "" {
+		files = hook.Files
+	}
+	typeRegex, ok := typesRegex(h.Types, h.TypesOr)
+	switch {
+	case !ok:
+		r.warnf("%s: types %v are not translated", label, append(h.Types, h.TypesOr...))
+	case typeRegex != "" && h.Files != "":
+		r.warnf("%s: types and files cannot both be used; kept files", label)
+	case typeRegex != "":
+		files = typeRegex
+	}
+	if cfg.Files != "" {
+		if files != "" {
+			r.warnf("%s: top-level files %q not combined with the hook's", label, cfg.Files)
+		} else {
+			files = cfg.Files
+		}
+	}
+	hook.Files = files
+
+	var excludes []string
+	for _, e := range []string{h.Exclude, cfg.Exclude} {
+		if e != "" {
+			excludes = append(excludes, e)
+		}
+	}
+	if len(h.ExcludeTypes) > 0 {
+		if re, ok := typesRegex(nil, h.ExcludeTypes); ok {
+			excludes = append(excludes, re)
+		} else {
+			r.warnf("%s: exclude_types %v are not translated", label, h.ExcludeTypes)
+		}
+	}
+	if len(excludes) == 1 {
+		hook.Exclude = excludes[0]
+	} else if len(excludes) > 1 {
+		hook.Exclude = "(?:" + strings.Join(excludes, ")|(?:") + ")"
+	}
+}
+
+// typesRegex returns a regex for files of all of types and any of
+// typesOr, or false if a type is unknown or they cannot be combined.
+func typesRegex(types, typesOr []string) (string, bool) {
+	var specific []string
+	for _, t := range types {
+		if t != "file" && t != "text" {
+			specific = append(specific, t)
+		}
+	}
+	if len(specific) > 1 || (len(specific) == 1 && len(typesOr) > 0) {
+		return "", false
+	}
+
+	var exts []string
+	for _, t := range append(specific, typesOr...) {
+		e, ok := typeExtensions[t]
+		if !ok {
+			return "", false
+		}
+		exts = append(exts, e...)
+	}
+	if len(exts) == 0 {
+		return "", true
+	}
+	sort.Strings(exts)
+
+	var names, suffixes []string
+	for _, e := range exts {
+		if e == "Dockerfile" {
+			names = append(names, regexp.QuoteMeta(e))
+		} else {
+			suffixes = append(suffixes, regexp.QuoteMeta(e))
+		}
+	}
+	var alts []string
+	if len(suffixes) > 0 {
+		alts = append(alts, `\.(`+strings.Join(suffixes, "|")+`)$`)
+	}
+	if len(names) > 0 {
+		alts = append(alts, `(^|/)(`+strings.Join(names, "|")+`)$`)
+	}
+	return strings.Join(alts, "|"), true
+}
+
+// addPolicy translates hooks of pre-commit-hooks that HookRunner covers
+// with policies.
+func (r *Result) addPolicy(h preCommitHook) bool {
+	var p LocalPolicy
+	switch h.ID {
+	case "check-added-large-files":
+		kb := 500
+		for _, a := range h.Args {
+			if m := maxKBArg.FindStringSubmatch(a); m != nil {
+				kb, _ = strconv.Atoi(m[1])
+			}
+		}
+		p = LocalPolicy{Name: "large-files", Description: "Migrated from check-added-large-files", Rules: PolicyRules{MaxFileSizeKB: kb}}
+	case "detect-private-key":
+		p = LocalPolicy{Name: "private-keys", Description: "Migrated from detect-private-key", Rules: PolicyRules{
+			ForbidFileContent: []ContentPattern{{Pattern: `-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`, Description: "Private key"}},
+		}}
+	default:
+		return false
+	}
+
+	if r.File.Policies == nil {
+		r.File.Policies = &Policies{}
+	}
+	r.File.Policies.LocalPolicies = append(r.File.Policies.LocalPolicies, p)
+	return true
+}
@@ -0,0 +1,107 @@
+package migrate
+
+import "testing"
+
+func TestFromPreCommit(t *testing.T) {
+	r, err := FromPreCommit([]byte(`
+default_stages: [commit]
+exclude: ^vendor/
+repos:
+  - repo: https://github.com/pre-commit/pre-commit-hooks
+    rev: v4.5.0
+    hooks:
+      - id: check-added-large-files
+        args: [--maxkb=1024]
+      - id: detect-private-key
+      - id: trailing-whitespace
+  - repo: https://github.com/psf/black
+    rev: 24.1.0
+    hooks:
+      - id: black
+        args: [--line-length=100]
+  - repo: local
+    hooks:
+      - id: unit-tests
+        name: unit tests
+        entry: go test ./...
+        language: system
+        pass_filenames: false
+        types: [go]
+      - id: lint-js
+        entry: npx eslint
+        language: node
+        args: [--max-warnings, "0"]
+        types_or: [javascript, ts]
+        exclude_types: [json]
+        pass_filenames: false
+      - id: push-check
+        entry: scripts/check.sh
+        language: script
+        pass_filenames: false
+        always_run: true
+        stages: [push]
+  - repo: meta
+    hooks:
+      - id: check-hooks-apply
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := load(t, r)
+
+	commit := cfg.GetHooks("pre-commit")
+	if len(commit) != 3 {
+		t.Fatalf("pre-commit hooks = %+v", commit)
+	}
+	black, tests, lint := commit[0], commit[1], commit[2]
+	if black.Run != "black . --line-length=100" || black.Files != `\.pyi?$` || black.Exclude != "^vendor/" {
+		t.Errorf("black = %+v", black)
+	}
+	if tests.Run != "go test ./..." || tests.Files != `\.(go)$` || len(tests.After) != 1 || tests.After[0] != "black" {
+		t.Errorf("unit-tests = %+v", tests)
+	}
+	if lint.Run != "npx eslint --max-warnings 0" || !matches(t, lint.Files, "a.mjs") || !matches(t, lint.Files, "b.ts") || !matches(t, lint.Exclude, "c.json") {
+		t.Errorf("lint-js = %+v", lint)
+	}
+
+	push := cfg.GetHooks("pre-push")
+	if len(push) != 1 || push[0].Run != "./scripts/check.sh" || push[0].Files != "" {
+		t.Errorf("pre-push hooks = %+v", push)
+	}
+
+	policies := cfg.Policies.LocalPolicies
+	if len(policies) != 2 || policies[0].Rules.MaxFileSizeKB != 1024 || len(policies[1].Rules.ForbidFileContent) != 1 {
+		t.Errorf("policies = %+v", policies)
+	}
+
+	for _, want := range []string{"trailing-whitespace (https://github.com/pre-commit/pre-commit-hooks@v4.5.0): remote hook", "lint-js: language node", "check-hooks-apply: meta"} {
+		if !hasWarning(r, want) {
+			t.Errorf("missing warning %q in %v", want, r.Warnings)
+		}
+	}
+	if hasWarning(r, "unit-tests: pass_filenames") {
+		t.Errorf("unexpected pass_filenames warning: %v", r.Warnings)
+	}
+}
+
+func TestFromPreCommit_FailFastUsesNeeds(t *testing.T) {
+	r, err := FromPreCommit([]byte(`
+fail_fast: true
+repos:
+  - repo: local
+    hooks:
+      - {id: fmt, entry: gofmt -l ., language: system}
+      - {id: vet, entry: go vet ./..., language: system}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := load(t, r).GetHooks("pre-commit")
+	if len(hooks) != 2 || len(hooks[1].Needs) != 1 || hooks[1].Needs[0] != "fmt" {
+		t.Errorf("hooks = %+v", hooks)
+	}
+	if !hasWarning(r, "fmt: pass_filenames") {
+		t.Errorf("missing pass_filenames warning: %v", r.Warnings)
+	}
+}
@@ -75,6 +75,11 @@ func (c *Cache) GetFromDisk(url string) (*RemotePolicy, *CacheMetadata, error) {
 	return policy, &meta, nil
 }
 
+// ReadData returns the cached content of the policy at url.
+func (c *Cache) ReadData(url string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.dir, "sha256_"+c.KeyFor(url), "policy.yaml"))
+}
+
 func (c *Cache) SaveToDisk(url string, policy *RemotePolicy, policyData []byte, etag string) error {
 	key := c.KeyFor(url)
 	cacheDir := filepath.Join(c.dir, "sha256_"+key)
@@ -2,15 +2,20 @@ package policy
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/ashavijit/hookrunner/internal/git"
 )
 
 type Violation struct {
 	Rule    string
 	Message string
+	// File and Line locate content violations. Line is zero when the
+	// match is not tied to a single added line.
+	File string
+	Line int
 }
 
 type EvalResult struct {
@@ -18,17 +23,35 @@ type EvalResult struct {
 	Violations []Violation
 }
 
+// Evaluate checks files against rules, reading contents from the working
+// tree relative to the current directory.
 func Evaluate(rules *PolicyRules, files []string, commitMsg string) EvalResult {
+	return EvaluateSource(rules, files, commitMsg, WorkTree(""))
+}
+
+// EvaluateSource checks files against rules, reading contents and sizes
+// from src. The files are treated as modified, so rules about deletions
+// and renames never match; use EvaluateChanges when the diff is known.
+func EvaluateSource(rules *PolicyRules, files []string, commitMsg string, src Source) EvalResult {
+	return EvaluateChanges(rules, git.ChangesFromPaths(files), commitMsg, src)
+}
+
+// EvaluateChanges checks a change set against rules. File and content
+// rules apply to the files that exist after the change; forbid_delete and
+// forbid_rename_from look at deletions and renames.
+func EvaluateChanges(rules *PolicyRules, changes git.ChangeSet, commitMsg string, src Source) EvalResult {
 	result := EvalResult{Passed: true}
 
 	if rules == nil {
 		return result
 	}
 
-	if rules.MaxFilesChanged > 0 && len(files) > rules.MaxFilesChanged {
+	files := changes.Files()
+
+	if rules.MaxFilesChanged > 0 && len(changes) > rules.MaxFilesChanged {
 		result.Violations = append(result.Violations, Violation{
 			Rule:    "max_files_changed",
-			Message: fmt.Sprintf("too many files: %d (max: %d)", len(files), rules.MaxFilesChanged),
+			Message: fmt.Sprintf("too many files: %d (max: %d)", len(changes), rules.MaxFilesChanged),
 		})
 	}
 
@@ -70,6 +93,40 @@ func Evaluate(rules *PolicyRules, files []string, commitMsg string) EvalResult {
 		}
 	}
 
+	for _, pattern := range rules.ForbidDelete {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		for _, c := range changes {
+			if c.Status == git.StatusDeleted && re.MatchString(c.Path) {
+				result.Violations = append(result.Violations, Violation{
+					Rule:    "forbid_delete",
+					Message: fmt.Sprintf("deleting %s is not allowed", c.Path),
+					File:    c.Path,
+				})
+			}
+		}
+	}
+
+	// A rename only counts when it moves the file out of the matched paths;
+	// renames that stay inside them are allowed.
+	for _, pattern := range rules.ForbidRenameFrom {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		for _, c := range changes {
+			if c.Status == git.StatusRenamed && re.MatchString(c.OldPath) && !re.MatchString(c.Path) {
+				result.Violations = append(result.Violations, Violation{
+					Rule:    "forbid_rename_from",
+					Message: fmt.Sprintf("renaming %s to %s is not allowed", c.OldPath, c.Path),
+					File:    c.Path,
+				})
+			}
+		}
+	}
+
 	for _, required := range rules.RequiredFiles {
 		found := false
 		for _, file := range files {
@@ -89,11 +146,11 @@ func Evaluate(rules *PolicyRules, files []string, commitMsg string) EvalResult {
 	if rules.MaxFileSizeKB > 0 {
 		maxBytes := int64(rules.MaxFileSizeKB * 1024)
 		for _, file := range files {
-			if info, err := os.Stat(file); err == nil {
-				if info.Size() > maxBytes {
+			if size, err := src.Size(file); err == nil {
+				if size > maxBytes {
 					result.Violations = append(result.Violations, Violation{
 						Rule:    "max_file_size_kb",
-						Message: fmt.Sprintf("file too large: %s (%d KB, max: %d KB)", file, info.Size()/1024, rules.MaxFileSizeKB),
+						Message: fmt.Sprintf("file too large: %s (%d KB, max: %d KB)", file, size/1024, rules.MaxFileSizeKB),
 					})
 				}
 			}
@@ -105,64 +162,81 @@ func Evaluate(rules *PolicyRules, files []string, commitMsg string) EvalResult {
 		if err != nil {
 			continue
 		}
+		desc := pattern.Description
+		if desc == "" {
+			desc = pattern.Pattern
+		}
 		for _, file := range files {
 			if isExcludedExtension(file, rules.ExcludeExtensions) {
 				continue
 			}
-			content, err := os.ReadFile(file)
-			if err != nil {
+			matched, line := matchContent(re, file, pattern.Scope, src)
+			if !matched {
 				continue
 			}
-			if re.Match(content) {
-				desc := pattern.Description
-				if desc == "" {
-					desc = pattern.Pattern
-				}
-				result.Violations = append(result.Violations, Violation{
-					Rule:    "forbid_file_content",
-					Message: fmt.Sprintf("forbidden content in %s: %s", filepath.Base(file), desc),
-				})
-			}
+			result.Violations = append(result.Violations, Violation{
+				Rule:    "forbid_file_content",
+				Message: fmt.Sprintf("forbidden content in %s: %s", location(file, line), desc),
+				File:    file,
+				Line:    line,
+			})
 		}
 	}
 
 	for _, pattern := range rules.RegexBlock {
-		re, err := regexp.Compile(pattern)
+		re, err := regexp.Compile(pattern.Pattern)
 		if err != nil {
 			continue
 		}
+		desc := describeSecretPattern(pattern.Pattern)
 		for _, file := range files {
 			if isExcludedExtension(file, rules.ExcludeExtensions) {
 				continue
 			}
-			content, err := os.ReadFile(file)
-			if err != nil {
+			matched, line := matchContent(re, file, pattern.Scope, src)
+			if !matched {
 				continue
 			}
-			if re.Match(content) {
-				desc := describeSecretPattern(pattern)
-				result.Violations = append(result.Violations, Violation{
-					Rule:    "secret_detected",
-					Message: fmt.Sprintf("%s in %s - remove before committing", desc, filepath.Base(file)),
-				})
-			}
+			result.Violations = append(result.Violations, Violation{
+				Rule:    "secret_detected",
+				Message: fmt.Sprintf("%s in %s - remove before committing", desc, location(file, line)),
+				File:    file,
+				Line:    line,
+			})
 		}
 	}
 
-	if commitMsg != "" && rules.CommitMessage != nil {
-		cm := rules.CommitMessage
-		if cm.Regex != "" {
-			re, err := regexp.Compile(cm.Regex)
-			if err == nil && !re.MatchString(commitMsg) {
-				errMsg := cm.Error
-				if errMsg == "" {
-					errMsg = fmt.Sprintf("does not match: %s", cm.Regex)
-				}
-				result.Violations = append(result.Violations, Violation{
-					Rule:    "commit_message",
-					Message: errMsg,
-				})
+	if v := checkCommitMessage(rules, commitMsg); v != nil {
+		result.Violations = append(result.Violations, *v)
+	}
+
+	result.Passed = len(result.Violations) == 0
+	return result
+}
+
+// Commit is a commit message to validate, identified by its SHA.
+type Commit struct {
+	SHA     string
+	Message string
+}
+
+// EvaluateCommits checks each commit message against the commit_message
+// rule, as done for the commits being pushed.
+func EvaluateCommits(rules *PolicyRules, commits []Commit) EvalResult {
+	result := EvalResult{Passed: true}
+
+	if rules == nil {
+		return result
+	}
+
+	for _, c := range commits {
+		if v := checkCommitMessage(rules, c.Message); v != nil {
+			short := c.SHA
+			if len(short) > 7 {
+				short = short[:7]
 			}
+			v.Message = fmt.Sprintf("commit %s: %s", short, v.Message)
+			result.Violations = append(result.Violations, *v)
 		}
 	}
 
@@ -170,6 +244,60 @@ func Evaluate(rules *PolicyRules, files []string, commitMsg string) EvalResult {
 	return result
 }
 
+func checkCommitMessage(rules *PolicyRules, commitMsg string) *Violation {
+	if commitMsg == "" || rules.CommitMessage == nil {
+		return nil
+	}
+	cm := rules.CommitMessage
+	if cm.Regex == "" {
+		return nil
+	}
+	re, err := regexp.Compile(cm.Regex)
+	if err != nil || re.MatchString(commitMsg) {
+		return nil
+	}
+	errMsg := cm.Error
+	if errMsg == "" {
+		errMsg = fmt.Sprintf("does not match: %s", cm.Regex)
+	}
+	return &Violation{
+		Rule:    "commit_message",
+		Message: errMsg,
+	}
+}
+
+// matchContent reports whether re matches file. With the added_lines scope
+// and a source that carries diff information only added lines are
+// checked, and the line of the first match is returned; otherwise the
+// whole file is matched and the line is zero.
+func matchContent(re *regexp.Regexp, file, scope string, src Source) (bool, int) {
+	if scope == ScopeAddedLines {
+		if ds, ok := src.(DiffSource); ok {
+			if lines, ok := ds.AddedLines(file); ok {
+				for _, l := range lines {
+					if re.MatchString(l.Text) {
+						return true, l.Number
+					}
+				}
+				return false, 0
+			}
+		}
+	}
+
+	content, err := src.ReadFile(file)
+	if err != nil {
+		return false, 0
+	}
+	return re.Match(content), 0
+}
+
+func location(file string, line int) string {
+	if line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return filepath.Base(file)
+}
+
 func isExcludedExtension(file string, excludeExtensions []string) bool {
 	for _, ext := range excludeExtensions {
 		if strings.HasSuffix(file, ext) || strings.HasSuffix(file, "."+ext) {
@@ -1,6 +1,8 @@
 package policy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,6 +17,8 @@ import (
 type Fetcher struct {
 	client *http.Client
 	cache  *Cache
+	// pins maps policy URLs to the SHA-256 their content must have.
+	pins map[string]string
 }
 
 func NewFetcher(cacheDir string) *Fetcher {
@@ -33,6 +37,10 @@ func (f *Fetcher) LoadPolicy(url string) (*RemotePolicy, error) {
 		return policy, nil
 	}
 
+	if sum, ok := f.pins[url]; ok {
+		return f.loadPinned(url, sum)
+	}
+
 	cached, meta, diskErr := f.cache.GetFromDisk(url)
 	_ = diskErr // Intentionally ignore - fallback to network if cache fails
 	if cached != nil && meta != nil {
@@ -63,6 +71,64 @@ func (f *Fetcher) LoadPolicy(url string) (*RemotePolicy, error) {
 	return policy, nil
 }
 
+// Pin requires the content of each policy URL in pins to have the given
+// SHA-256. A cached copy with that hash is used without asking the server
+// whether it changed.
+func (f *Fetcher) Pin(pins map[string]string) {
+	f.pins = pins
+}
+
+// loadPinned loads the policy at url, failing if its content does not
+// have the SHA-256 sum.
+func (f *Fetcher) loadPinned(url, sum string) (*RemotePolicy, error) {
+	if data, err := f.cache.ReadData(url); err == nil && hashData(data) == sum {
+		if policy, err := ParseRemotePolicy(data); err == nil && ValidatePolicy(policy) == nil {
+			f.cache.SetInMemory(url, policy)
+			return policy, nil
+		}
+	}
+
+	policy, data, etag, err := f.fetchFromNetwork(url)
+	if err != nil {
+		return nil, err
+	}
+	if got := hashData(data); got != sum {
+		return nil, fmt.Errorf("policy changed since it was locked (sha256 %s, now %s); run 'hookrunner lock --update' to accept it", sum, got)
+	}
+	if err := ValidatePolicy(policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	//nolint:errcheck // Best-effort cache, failure is acceptable
+	f.cache.SaveToDisk(url, policy, data, etag)
+	f.cache.SetInMemory(url, policy)
+	return policy, nil
+}
+
+// Fetch downloads and validates the policy at url, bypassing the cache,
+// and returns its content. The cache is updated with it.
+func (f *Fetcher) Fetch(url string) ([]byte, error) {
+	if err := f.validateURL(url); err != nil {
+		return nil, err
+	}
+	policy, data, etag, err := f.fetchFromNetwork(url)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePolicy(policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	//nolint:errcheck // Best-effort cache, failure is acceptable
+	f.cache.SaveToDisk(url, policy, data, etag)
+	return data, nil
+}
+
+func hashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (f *Fetcher) validateURL(url string) error {
 	if !strings.HasPrefix(url, "https://") {
 		return fmt.Errorf("HTTPS required: %s", url)
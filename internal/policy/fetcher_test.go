@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetcher_Pinned(t *testing.T) {
+	content := "name: team\nversion: \"1.0\"\n"
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+	url := srv.URL + "/team.yaml"
+
+	cacheDir := t.TempDir()
+	f := NewFetcher(cacheDir)
+	f.client = srv.Client()
+	data, err := f.Fetch(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := hashData(data)
+
+	// A matching cached copy is used without a request.
+	f = NewFetcher(cacheDir)
+	f.client = srv.Client()
+	f.Pin(map[string]string{url: sum})
+	requests = 0
+	p, err := f.LoadPolicy(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "team" || requests != 0 {
+		t.Errorf("policy = %+v after %d requests", p, requests)
+	}
+
+	// A changed policy fails, from the cache or the server.
+	content = "name: team\nversion: \"2.0\"\n"
+	f = NewFetcher(cacheDir)
+	f.client = srv.Client()
+	f.Pin(map[string]string{url: "0000"})
+	if _, err := f.LoadPolicy(url); err == nil || !strings.Contains(err.Error(), "changed since it was locked") {
+		t.Errorf("expected changed policy error, got %v", err)
+	}
+}
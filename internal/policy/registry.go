@@ -52,6 +52,11 @@ func (r *Registry) resolveRules(merged *MergedPolicy) PolicyRules {
 	return effective
 }
 
+// Pin requires the policies to have the SHA-256 sums in pins, by URL.
+func (r *Registry) Pin(pins map[string]string) {
+	r.fetcher.Pin(pins)
+}
+
 func (r *Registry) Refresh(config *UserConfig) error {
 	if err := r.fetcher.ClearCache(); err != nil {
 		return err
@@ -80,7 +85,7 @@ func ValidatePolicy(p *RemotePolicy) error {
 		}
 	}
 
-	return nil
+	return p.Rules.ValidateScopes()
 }
 
 func ValidateLocalPolicy(p *LocalPolicy) error {
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Source provides the contents of the files a policy is evaluated
+// against, so checks can see staged or committed content rather than
+// whatever is currently in the working tree.
+type Source interface {
+	ReadFile(path string) ([]byte, error)
+	Size(path string) (int64, error)
+}
+
+// WorkTree reads files from disk relative to root.
+type WorkTree string
+
+func (w WorkTree) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(w.resolve(path))
+}
+
+func (w WorkTree) Size(path string) (int64, error) {
+	info, err := os.Stat(w.resolve(path))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (w WorkTree) resolve(path string) string {
+	if filepath.IsAbs(path) || w == "" {
+		return path
+	}
+	return filepath.Join(string(w), path)
+}
+
+// AddedLine is a line added by the change under evaluation, numbered as in
+// the new version of the file.
+type AddedLine struct {
+	Number int
+	Text   string
+}
+
+// DiffSource is a Source that also knows which lines the change adds, so
+// content rules scoped to added_lines can ignore pre-existing content.
+type DiffSource interface {
+	Source
+	AddedLines(path string) ([]AddedLine, bool)
+}
+
+// WithAddedLines wraps src with the added lines returned by load. The diff
+// is only computed the first time an added_lines rule asks for it.
+func WithAddedLines(src Source, load func() (map[string][]AddedLine, error)) Source {
+	return &diffSource{Source: src, load: load}
+}
+
+type diffSource struct {
+	Source
+	load  func() (map[string][]AddedLine, error)
+	once  sync.Once
+	lines map[string][]AddedLine
+	err   error
+}
+
+// AddedLines reports the lines added to path. The second result is false
+// when the diff could not be computed, in which case callers should fall
+// back to checking the whole file.
+func (d *diffSource) AddedLines(path string) ([]AddedLine, bool) {
+	d.once.Do(func() {
+		d.lines, d.err = d.load()
+	})
+	if d.err != nil {
+		return nil, false
+	}
+	return d.lines[filepath.ToSlash(path)], true
+}
@@ -1,5 +1,12 @@
 package policy
 
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
 type PolicyRef struct {
 	URL string `json:"url" yaml:"url"`
 }
@@ -28,9 +35,76 @@ type RemotePolicy struct {
 	Metadata    map[string]string `json:"metadata" yaml:"metadata"`
 }
 
+// Content rule scopes. ScopeAddedLines only matches lines added by the
+// change being checked; ScopeFile, the default, matches the whole file.
+const (
+	ScopeFile       = "file"
+	ScopeAddedLines = "added_lines"
+)
+
+// ValidateScopes reports content rules with a scope other than file or
+// added_lines, which would otherwise silently match the whole file.
+func (r *PolicyRules) ValidateScopes() error {
+	for i, p := range r.ForbidFileContent {
+		if err := checkScope(p.Scope); err != nil {
+			return fmt.Errorf("forbid_file_content[%d]: %w", i, err)
+		}
+	}
+	for i, p := range r.RegexBlock {
+		if err := checkScope(p.Scope); err != nil {
+			return fmt.Errorf("regex_block[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func checkScope(scope string) error {
+	switch scope {
+	case "", ScopeFile, ScopeAddedLines:
+		return nil
+	}
+	return fmt.Errorf("unknown scope %q (use %s or %s)", scope, ScopeFile, ScopeAddedLines)
+}
+
 type ForbiddenContentPattern struct {
 	Pattern     string `json:"pattern" yaml:"pattern"`
 	Description string `json:"description" yaml:"description"`
+	Scope       string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+// RegexBlockPattern is a secret pattern of regex_block, written as the
+// pattern alone or as {pattern, scope}.
+type RegexBlockPattern struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Scope   string `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+func (p *RegexBlockPattern) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = RegexBlockPattern{Pattern: value.Value}
+		return nil
+	}
+	type plain RegexBlockPattern
+	return value.Decode((*plain)(p))
+}
+
+func (p *RegexBlockPattern) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = RegexBlockPattern{Pattern: s}
+		return nil
+	}
+	type plain RegexBlockPattern
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// MarshalYAML writes a pattern without a scope as the pattern alone.
+func (p RegexBlockPattern) MarshalYAML() (interface{}, error) {
+	if p.Scope == "" {
+		return p.Pattern, nil
+	}
+	type plain RegexBlockPattern
+	return plain(p), nil
 }
 
 type CommitMessageRule struct {
@@ -43,10 +117,12 @@ type PolicyRules struct {
 	ForbidDirectories    []string                  `json:"forbid_directories" yaml:"forbid_directories"`
 	ForbidFileExtensions []string                  `json:"forbid_file_extensions" yaml:"forbid_file_extensions"`
 	RequiredFiles        []string                  `json:"required_files" yaml:"required_files"`
+	ForbidDelete         []string                  `json:"forbid_delete,omitempty" yaml:"forbid_delete,omitempty"`
+	ForbidRenameFrom     []string                  `json:"forbid_rename_from,omitempty" yaml:"forbid_rename_from,omitempty"`
 	MaxFileSizeKB        int                       `json:"max_file_size_kb" yaml:"max_file_size_kb"`
 	MaxFilesChanged      int                       `json:"max_files_changed" yaml:"max_files_changed"`
 	ForbidFileContent    []ForbiddenContentPattern `json:"forbid_file_content" yaml:"forbid_file_content"`
-	RegexBlock           []string                  `json:"regex_block" yaml:"regex_block"`
+	RegexBlock           []RegexBlockPattern       `json:"regex_block" yaml:"regex_block"`
 	ExcludeExtensions    []string                  `json:"exclude_extensions" yaml:"exclude_extensions"`
 	CommitMessage        *CommitMessageRule        `json:"commit_message" yaml:"commit_message"`
 	EnforceHooks         []string                  `json:"enforce_hooks" yaml:"enforce_hooks"`
@@ -91,6 +167,8 @@ func (r *PolicyRules) Merge(other PolicyRules) PolicyRules {
 	result.ForbidFiles = appendUnique(result.ForbidFiles, other.ForbidFiles)
 	result.ForbidFileExtensions = appendUnique(result.ForbidFileExtensions, other.ForbidFileExtensions)
 	result.RequiredFiles = appendUnique(result.RequiredFiles, other.RequiredFiles)
+	result.ForbidDelete = appendUnique(result.ForbidDelete, other.ForbidDelete)
+	result.ForbidRenameFrom = appendUnique(result.ForbidRenameFrom, other.ForbidRenameFrom)
 	result.EnforceHooks = appendUnique(result.EnforceHooks, other.EnforceHooks)
 	result.RegexBlock = appendUnique(result.RegexBlock, other.RegexBlock)
 	result.ExcludeExtensions = appendUnique(result.ExcludeExtensions, other.ExcludeExtensions)
@@ -121,8 +199,8 @@ func (r *PolicyRules) Merge(other PolicyRules) PolicyRules {
 	return result
 }
 
-func appendUnique(base, items []string) []string {
-	seen := make(map[string]bool)
+func appendUnique[T comparable](base, items []T) []T {
+	seen := make(map[T]bool)
 	for _, s := range base {
 		seen[s] = true
 	}
@@ -10,7 +10,9 @@ var Languages = map[string]Preset{
 	"go": {
 		Name:        "Go",
 		Description: "Go language hooks (gofmt, govet, golangci-lint)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: gofmt
       tool: go
@@ -34,7 +36,9 @@ var Languages = map[string]Preset{
 	"nodejs": {
 		Name:        "Node.js",
 		Description: "Node.js hooks (eslint, prettier)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: eslint
       tool: npx
@@ -60,7 +64,9 @@ var Languages = map[string]Preset{
 	"python": {
 		Name:        "Python",
 		Description: "Python hooks (black, flake8, mypy, pytest)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: black
       tool: black
@@ -91,7 +97,9 @@ var Languages = map[string]Preset{
 	"java": {
 		Name:        "Java",
 		Description: "Java hooks (checkstyle, spotless, maven test)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: checkstyle
       tool: mvn
@@ -115,7 +123,9 @@ var Languages = map[string]Preset{
 	"ruby": {
 		Name:        "Ruby",
 		Description: "Ruby hooks (rubocop, rspec)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: rubocop
       tool: rubocop
@@ -134,7 +144,9 @@ var Languages = map[string]Preset{
 	"rust": {
 		Name:        "Rust",
 		Description: "Rust hooks (cargo fmt, clippy, test)",
-		Config: `hooks:
+		Config: `version: 2
+
+hooks:
   pre-commit:
     - name: cargo-fmt
       tool: cargo
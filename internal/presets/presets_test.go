@@ -1,7 +1,11 @@
 package presets
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/ashavijit/hookrunner/internal/config"
 )
 
 func TestList(t *testing.T) {
@@ -80,3 +84,17 @@ func TestPresetConfigs_Valid(t *testing.T) {
 		}
 	}
 }
+
+func TestPresets_LoadStrictly(t *testing.T) {
+	for _, lang := range AvailableLanguages() {
+		preset, _ := Get(lang)
+		dir := t.TempDir()
+		//nolint:gosec // G306: Test file, permissions not a concern
+		if err := os.WriteFile(filepath.Join(dir, "hooks.yaml"), []byte(preset.Config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := config.Load(dir); err != nil {
+			t.Errorf("preset %s does not load: %v", lang, err)
+		}
+	}
+}
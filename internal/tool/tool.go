@@ -6,6 +6,7 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -64,17 +65,51 @@ func (m *Manager) getCachedPath(name, version string) string {
 	return filepath.Join(m.CacheDir, fmt.Sprintf("%s-%s", name, version), binName)
 }
 
-func (m *Manager) downloadAndExtract(name, version, url, checksum string) error {
+// ErrNotFound is returned when a download URL does not exist, such as a
+// release without a build for some platform.
+var ErrNotFound = errors.New("not found")
+
+// download starts a download of url.
+func download(url string) (io.ReadCloser, error) {
 	//nolint:gosec // G107: URL is from trusted config file
 	resp, err := http.Get(url)
 	if err != nil {
-		return fmt.Errorf("download failed: %w", err)
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: %s: %w", url, ErrNotFound)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	}
+}
+
+// DownloadSum downloads url and returns its SHA-256, as checked against a
+// tool's checksum.
+func DownloadSum(url string) (string, error) {
+	body, err := download(url)
+	if err != nil {
+		return "", err
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: HTTP %d", resp.StatusCode)
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, body); err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (m *Manager) downloadAndExtract(name, version, url, checksum string) error {
+	body, err := download(url)
+	if err != nil {
+		return err
 	}
+	defer body.Close()
 
 	tmpFile, err := os.CreateTemp("", "hookrunner-*")
 	if err != nil {
@@ -85,7 +120,7 @@ func (m *Manager) downloadAndExtract(name, version, url, checksum string) error
 
 	hasher := sha256.New()
 	writer := io.MultiWriter(tmpFile, hasher)
-	if _, err := io.Copy(writer, resp.Body); err != nil {
+	if _, err := io.Copy(writer, body); err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 
@@ -1,6 +1,10 @@
 package tool
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"testing"
 
@@ -110,3 +114,26 @@ func TestExtractZip_InvalidFile(t *testing.T) {
 		t.Error("expected error for nonexistent file")
 	}
 }
+
+func TestDownloadSum(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tool.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "archive")
+	}))
+	defer srv.Close()
+
+	sum, err := DownloadSum(srv.URL + "/tool.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3" {
+		t.Errorf("sum = %q", sum)
+	}
+
+	if _, err := DownloadSum(srv.URL + "/missing.tar.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
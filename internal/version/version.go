@@ -1,5 +1,11 @@
 package version
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 var (
 	Version   = "0.19.0"
 	GitCommit = "dev"
@@ -13,3 +19,63 @@ func String() string {
 func Full() string {
 	return Version + " (" + GitCommit + ") built " + BuildDate
 }
+
+// Compare compares two versions, such as 1.2.3 or v1.2.3-rc.1, by their
+// numeric parts and returns -1, 0 or 1. Missing parts count as 0 and
+// pre-release suffixes are ignored.
+func Compare(a, b string) (int, error) {
+	pa, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// AtLeast reports whether this build is min or newer. Development builds,
+// whose Version is not a release number, satisfy any minimum.
+func AtLeast(min string) (bool, error) {
+	if _, err := parse(min); err != nil {
+		return false, err
+	}
+	c, err := Compare(Version, min)
+	if err != nil {
+		return true, nil
+	}
+	return c >= 0, nil
+}
+
+func parse(v string) ([]int, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	fields := strings.Split(s, ".")
+	parts := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
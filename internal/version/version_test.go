@@ -41,3 +41,51 @@ func TestVersionVariables(t *testing.T) {
 		t.Error("BuildDate should not be empty")
 	}
 }
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.19.0", "0.19.0", 0},
+		{"v0.19.0", "0.19", 0},
+		{"0.19.0", "0.20.0", -1},
+		{"1.0.0", "0.99.9", 1},
+		{"0.19.1-rc.1", "0.19.1", 0},
+		{"0.10.0", "0.9.0", 1},
+	}
+	for _, tt := range tests {
+		got, err := Compare(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Compare(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if _, err := Compare("latest", "1.0.0"); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	old := Version
+	defer func() { Version = old }()
+
+	Version = "0.19.0"
+	if ok, err := AtLeast("0.18.2"); err != nil || !ok {
+		t.Errorf("0.19.0 should satisfy 0.18.2, got %v, %v", ok, err)
+	}
+	if ok, _ := AtLeast("0.20.0"); ok {
+		t.Error("0.19.0 should not satisfy 0.20.0")
+	}
+	if _, err := AtLeast("soon"); err == nil {
+		t.Error("expected an error for an invalid minimum")
+	}
+
+	Version = "dev"
+	if ok, err := AtLeast("9.0.0"); err != nil || !ok {
+		t.Errorf("development builds should satisfy any minimum, got %v, %v", ok, err)
+	}
+}